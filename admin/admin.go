@@ -0,0 +1,138 @@
+// Package admin serves the health, readiness and status endpoints of the
+// aggregator and the operator.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	HealthPath = "/healthz"
+	ReadyPath  = "/readyz"
+	StatusPath = "/status"
+
+	// CheckTimeout bounds all readiness checks of one request
+	CheckTimeout = 10 * time.Second
+)
+
+// Check is one readiness condition, Run returns nil when it holds.
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+type ReadyResult struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Server answers /healthz as long as the process runs, /readyz when every
+// check passes and /status with the JSON returned by Status.
+type Server struct {
+	Address string
+	Checks  []Check
+	Status  func() interface{}
+	logger  *zap.Logger
+}
+
+func NewServer(logger *zap.Logger, address string, checks []Check, status func() interface{}) *Server {
+	return &Server{
+		Address: address,
+		Checks:  checks,
+		Status:  status,
+		logger:  logger,
+	}
+}
+
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc(ReadyPath, s.serveReady)
+	mux.HandleFunc(StatusPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, s.Status())
+	})
+
+	server := &http.Server{
+		Addr:    s.Address,
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	s.logger.Info("Starting admin server on address:", zap.String("address", s.Address))
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	result := s.Ready()
+	code := http.StatusOK
+	if !result.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJson(w, code, result)
+}
+
+// Ready runs the checks concurrently, a check that does not finish within
+// CheckTimeout fails.
+func (s *Server) Ready() ReadyResult {
+	var mu sync.Mutex
+	result := ReadyResult{Ready: true, Checks: make(map[string]string)}
+	for _, check := range s.Checks {
+		result.Checks[check.Name] = "timeout"
+	}
+
+	done := make(chan struct{}, len(s.Checks))
+	for _, check := range s.Checks {
+		go func(check Check) {
+			err := check.Run()
+			mu.Lock()
+			if err != nil {
+				result.Checks[check.Name] = err.Error()
+			} else {
+				result.Checks[check.Name] = "ok"
+			}
+			mu.Unlock()
+			done <- struct{}{}
+		}(check)
+	}
+
+	timeout := time.After(CheckTimeout)
+	for range s.Checks {
+		select {
+		case <-done:
+		case <-timeout:
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	checks := make(map[string]string, len(result.Checks))
+	for name, state := range result.Checks {
+		checks[name] = state
+		if state != "ok" {
+			result.Ready = false
+		}
+	}
+	result.Checks = checks
+	return result
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
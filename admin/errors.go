@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type LastError struct {
+	Message string    `json:"message"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ErrorRecorder keeps the last error logged through a logger wrapped with
+// Wrap, for the status endpoint.
+type ErrorRecorder struct {
+	mu   sync.Mutex
+	last *LastError
+}
+
+func NewErrorRecorder() *ErrorRecorder {
+	return &ErrorRecorder{}
+}
+
+// Wrap returns a logger that also records its error entries.
+func (r *ErrorRecorder) Wrap(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, &recorderCore{recorder: r})
+	}))
+}
+
+func (r *ErrorRecorder) Last() *LastError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return nil
+	}
+	last := *r.last
+	return &last
+}
+
+func (r *ErrorRecorder) record(entry zapcore.Entry, fields []zapcore.Field) {
+	last := &LastError{Message: entry.Message, Time: entry.Time}
+	// zap.Error and zap.Any with an error both make an error field
+	for _, field := range fields {
+		if field.Type == zapcore.ErrorType {
+			if err, ok := field.Interface.(error); ok {
+				last.Error = err.Error()
+			}
+		}
+	}
+	r.mu.Lock()
+	r.last = last
+	r.mu.Unlock()
+}
+
+type recorderCore struct {
+	recorder *ErrorRecorder
+	fields   []zapcore.Field
+}
+
+func (c *recorderCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel
+}
+
+func (c *recorderCore) With(fields []zapcore.Field) zapcore.Core {
+	return &recorderCore{
+		recorder: c.recorder,
+		fields:   append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+func (c *recorderCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *recorderCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.recorder.record(entry, append(append([]zapcore.Field(nil), c.fields...), fields...))
+	return nil
+}
+
+func (c *recorderCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// MoveAbortError is a transaction that aborted in one of the AVS modules.
+// Compare with errors.Is against the Err* values below, which match on
+// module and abort code.
+type MoveAbortError struct {
+	Module   string
+	Name     string
+	Code     uint64
+	VmStatus string
+}
+
+func (e *MoveAbortError) Error() string {
+	if e.VmStatus != "" {
+		return e.VmStatus
+	}
+	return fmt.Sprintf("Move abort in %s: %s(0x%x)", e.Module, e.Name, e.Code)
+}
+
+func (e *MoveAbortError) Is(target error) bool {
+	t, ok := target.(*MoveAbortError)
+	if !ok {
+		return false
+	}
+	return t.Module == e.Module && t.Code == e.Code
+}
+
+// service_manager
+var (
+	ErrTaskAlreadySubmitted          = &MoveAbortError{Module: "service_manager", Name: "ETASK_ALREADY_SUBMITTED", Code: 1300}
+	ErrTaskDoesNotExist              = &MoveAbortError{Module: "service_manager", Name: "ETASK_DOES_NOT_EXIST", Code: 1301}
+	ErrTaskAlreadyResponded          = &MoveAbortError{Module: "service_manager", Name: "ETASK_ALREADY_RESPONDED", Code: 1302}
+	ErrTaskHasNoBalance              = &MoveAbortError{Module: "service_manager", Name: "ETASK_HAS_NO_BALANCE", Code: 1303}
+	ErrInsufficientFunds             = &MoveAbortError{Module: "service_manager", Name: "EINSUFFICIENT_FUNDS", Code: 1304}
+	ErrThresholdNotMeet              = &MoveAbortError{Module: "service_manager", Name: "ETHRESHOLD_NOT_MEET", Code: 1305}
+	ErrInvalidTaskId                 = &MoveAbortError{Module: "service_manager", Name: "EINVALID_TASK_ID", Code: 1306}
+	ErrSignerSignatureAmountNotMatch = &MoveAbortError{Module: "service_manager", Name: "ESIGNER_SIGNATURE_AMOUNT_NOT_MATCH", Code: 1307}
+	ErrSignerResponseAmountNotMatch  = &MoveAbortError{Module: "service_manager", Name: "ESIGNER_RESPONSE_AMOUNT_NOT_MATCH", Code: 1308}
+)
+
+// bls_sig_checker
+var (
+	ErrEmptyQuorum               = &MoveAbortError{Module: "bls_sig_checker", Name: "EEMPTY_QUORUM", Code: 1110}
+	ErrInputQuorumLengthMismatch = &MoveAbortError{Module: "bls_sig_checker", Name: "EINPUT_QUORUM_LENGTH_MISMATCH", Code: 1111}
+	ErrNonsignerLengthMismatch   = &MoveAbortError{Module: "bls_sig_checker", Name: "ENONSIGNER_LENGTH_MISMATCH", Code: 1112}
+	ErrInvalidTimestamp          = &MoveAbortError{Module: "bls_sig_checker", Name: "EINVALID_TIMESTAMP", Code: 1113}
+	ErrQuorumApkHashMismatch     = &MoveAbortError{Module: "bls_sig_checker", Name: "EQUORUM_APK_HASH_MISMATCH", Code: 1114}
+	ErrSignatureValidateInvalid  = &MoveAbortError{Module: "bls_sig_checker", Name: "ESIGNATURE_VALIDATE_INVALID", Code: 1115}
+)
+
+var knownAborts = []*MoveAbortError{
+	ErrTaskAlreadySubmitted,
+	ErrTaskDoesNotExist,
+	ErrTaskAlreadyResponded,
+	ErrTaskHasNoBalance,
+	ErrInsufficientFunds,
+	ErrThresholdNotMeet,
+	ErrInvalidTaskId,
+	ErrSignerSignatureAmountNotMatch,
+	ErrSignerResponseAmountNotMatch,
+	ErrEmptyQuorum,
+	ErrInputQuorumLengthMismatch,
+	ErrNonsignerLengthMismatch,
+	ErrInvalidTimestamp,
+	ErrQuorumApkHashMismatch,
+	ErrSignatureValidateInvalid,
+}
+
+// "Move abort in 0x1::module: NAME(0x516): description", the name is missing
+// when the module was published without an error map.
+var moveAbortPattern = regexp.MustCompile(`Move abort in (?:0x[0-9a-fA-F]+)::(\w+): (?:(\w+)\()?0x([0-9a-fA-F]+)`)
+
+// ParseMoveAbort decodes the vm status of a failed transaction, it returns
+// nil when the status is not a Move abort.
+func ParseMoveAbort(vmStatus string) *MoveAbortError {
+	match := moveAbortPattern.FindStringSubmatch(vmStatus)
+	if match == nil {
+		return nil
+	}
+	code, err := strconv.ParseUint(match[3], 16, 64)
+	if err != nil {
+		return nil
+	}
+	abort := &MoveAbortError{
+		Module:   match[1],
+		Name:     match[2],
+		Code:     code,
+		VmStatus: vmStatus,
+	}
+	for _, known := range knownAborts {
+		if known.Is(abort) && abort.Name == "" {
+			abort.Name = known.Name
+		}
+	}
+	return abort
+}
+
+// vmStatusError turns the vm status of a failed transaction into an error,
+// a *MoveAbortError when it is an abort.
+func vmStatusError(vmStatus string) error {
+	if abort := ParseMoveAbort(vmStatus); abort != nil {
+		return abort
+	}
+	return fmt.Errorf("transaction failed: %s", vmStatus)
+}
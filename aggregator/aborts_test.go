@@ -0,0 +1,92 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseMoveAbort(t *testing.T) {
+	const avs = "0xd1ad4d5848b0e5d15691c0f3eb486c1a8d3c4a3c470822a2915a0e5efd1e352e"
+	for _, tc := range []struct {
+		vmStatus string
+		known    error
+		module   string
+		name     string
+		code     uint64
+	}{
+		{
+			vmStatus: "Move abort in " + avs + "::service_manager: ETASK_ALREADY_RESPONDED(0x516): ",
+			known:    ErrTaskAlreadyResponded,
+			module:   "service_manager", name: "ETASK_ALREADY_RESPONDED", code: 1302,
+		},
+		{
+			vmStatus: "Move abort in " + avs + "::service_manager: ETHRESHOLD_NOT_MEET(0x519): Threshold not met",
+			known:    ErrThresholdNotMeet,
+			module:   "service_manager", name: "ETHRESHOLD_NOT_MEET", code: 1305,
+		},
+		{
+			// published without an error map, the name comes from the code
+			vmStatus: "Move abort in " + avs + "::bls_sig_checker: 0x45b",
+			known:    ErrSignatureValidateInvalid,
+			module:   "bls_sig_checker", name: "ESIGNATURE_VALIDATE_INVALID", code: 1115,
+		},
+		{
+			vmStatus: "Move abort in 0x1::coin: EINSUFFICIENT_BALANCE(0x10006): Not enough coins to complete transaction",
+			module:   "coin", name: "EINSUFFICIENT_BALANCE", code: 0x10006,
+		},
+		{
+			// the same code in another module is another abort
+			vmStatus: "Move abort in 0x1::fungible_asset: 0x516",
+			module:   "fungible_asset", code: 1302,
+		},
+	} {
+		abort := ParseMoveAbort(tc.vmStatus)
+		if abort == nil {
+			t.Errorf("%q not parsed", tc.vmStatus)
+			continue
+		}
+		if abort.Module != tc.module || abort.Name != tc.name || abort.Code != tc.code {
+			t.Errorf("%q parsed as %s %s %d, want %s %s %d", tc.vmStatus, abort.Module, abort.Name, abort.Code, tc.module, tc.name, tc.code)
+		}
+		if abort.Error() != tc.vmStatus {
+			t.Errorf("error %q, want the vm status", abort.Error())
+		}
+		if tc.known != nil && !errors.Is(abort, tc.known) {
+			t.Errorf("%q is not %v", tc.vmStatus, tc.known)
+		}
+		if tc.known == nil {
+			for _, known := range knownAborts {
+				if errors.Is(abort, known) {
+					t.Errorf("%q is %v", tc.vmStatus, known)
+				}
+			}
+		}
+	}
+}
+
+func TestParseMoveAbortUnparseable(t *testing.T) {
+	for _, vmStatus := range []string{
+		"",
+		"Executed successfully",
+		"Out of gas",
+		"MISCELLANEOUS_ERROR",
+		"Execution failed in 0x1::service_manager::respond_to_task at code offset 12",
+		"Move abort in service_manager: 0x516",
+		"Move abort in 0x1::service_manager: ETASK_ALREADY_RESPONDED",
+		"Move abort in 0x1::service_manager: 0x1ffffffffffffffff",
+	} {
+		if abort := ParseMoveAbort(vmStatus); abort != nil {
+			t.Errorf("%q parsed as %+v", vmStatus, abort)
+		}
+	}
+
+	err := vmStatusError("Out of gas")
+	var abort *MoveAbortError
+	if errors.As(err, &abort) {
+		t.Errorf("vm status %q returned a move abort", "Out of gas")
+	}
+	if err == nil || err.Error() != "transaction failed: Out of gas" {
+		t.Errorf("vm status error %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package aggregator
+
+import (
+	"avs/admin"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+type AggregatorStatus struct {
+	AvsAddress   string              `json:"avs_address"`
+	Network      string              `json:"network"`
+	TaskCount    *uint64             `json:"task_count,omitempty"`
+	PendingTasks []PendingTaskStatus `json:"pending_tasks"`
+	LastChore    *time.Time          `json:"last_chore,omitempty"`
+	LastError    *admin.LastError    `json:"last_error,omitempty"`
+}
+
+type PendingTaskStatus struct {
+	TaskId      uint64     `json:"task_id"`
+	Status      TaskStatus `json:"status"`
+	Responses   int        `json:"responses"`
+	SignedStake uint64     `json:"signed_stake"`
+	TotalStake  uint64     `json:"total_stake"`
+}
+
+// ServeAdmin serves /healthz, /readyz and /status on AdminAddress.
+func (agg *Aggregator) ServeAdmin(ctx context.Context) error {
+	checks := []admin.Check{
+		{Name: "chain", Run: agg.checkChain},
+		{Name: "account_funded", Run: agg.checkFunded},
+		{Name: "operator_set", Run: agg.checkOperatorSet},
+	}
+	server := admin.NewServer(agg.logger, agg.AggregatorConfig.AdminAddress, checks, func() interface{} {
+		return agg.Status()
+	})
+	return server.ListenAndServe(ctx)
+}
+
+func (agg *Aggregator) checkChain() error {
+	_, err := agg.Client.Info()
+	return err
+}
+
+func (agg *Aggregator) checkFunded() error {
+	balance, err := agg.Client.AccountAPTBalance(agg.AggregatorAccount.Address)
+	if err != nil {
+		return err
+	}
+	if balance == 0 {
+		return fmt.Errorf("account %s has no APT to pay for transactions", agg.AggregatorAccount.Address.String())
+	}
+	return nil
+}
+
+func (agg *Aggregator) checkOperatorSet() error {
+	agg.OperatorSetMutex.RLock()
+	defer agg.OperatorSetMutex.RUnlock()
+	if agg.OperatorSet == nil {
+		return ErrOperatorSetNotReady
+	}
+	return nil
+}
+
+// Status reports the tasks the aggregator is working on, the task count is
+// left out when the chain can not be reached.
+func (agg *Aggregator) Status() AggregatorStatus {
+	status := AggregatorStatus{
+		AvsAddress:   agg.AvsAddress,
+		Network:      agg.Network.Name,
+		PendingTasks: []PendingTaskStatus{},
+		LastError:    agg.Errors.Last(),
+	}
+
+	avs := aptos.AccountAddress{}
+	if err := avs.ParseStringRelaxed(agg.AvsAddress); err == nil {
+		if count, err := LatestTaskCount(agg.Client, avs); err == nil {
+			status.TaskCount = &count
+		}
+	}
+
+	agg.TaskMutex.Lock()
+	for taskId, taskInfo := range agg.PendingTasks {
+		status.PendingTasks = append(status.PendingTasks, PendingTaskStatus{
+			TaskId:      taskId,
+			Status:      taskInfo.CurrentStatus(),
+			Responses:   len(taskInfo.Responses),
+			SignedStake: taskInfo.SignedStake,
+			TotalStake:  taskInfo.TotalStake,
+		})
+	}
+	agg.TaskMutex.Unlock()
+	sort.Slice(status.PendingTasks, func(i, j int) bool {
+		return status.PendingTasks[i].TaskId < status.PendingTasks[j].TaskId
+	})
+
+	if lastChore := agg.lastChore.Load(); lastChore != 0 {
+		t := time.Unix(lastChore, 0)
+		status.LastChore = &t
+	}
+	return status
+}
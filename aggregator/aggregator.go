@@ -1,6 +1,9 @@
 package aggregator
 
 import (
+	"avs/admin"
+	"avs/avsconfig"
+	"avs/metrics"
 	"context"
 	"fmt"
 	"os"
@@ -15,23 +18,54 @@ import (
 	"go.uber.org/zap"
 )
 
-const taskQueueSize = 100
-
 func NewAggregator(aggregatorConfig AggregatorConfig, logger *zap.Logger, network aptos.NetworkConfig) (*Aggregator, error) {
+	errorRecorder := admin.NewErrorRecorder()
+	logger = errorRecorder.Wrap(logger)
+
 	aggegator_account, err := SignerFromConfig(aggregatorConfig.AccountConfig.AccountPath, aggregatorConfig.AccountConfig.Profile)
 	if err != nil {
 		return &Aggregator{}, errors.Wrap(err, "Failed to create aggregator account")
 	}
 
+	err = ValidateResponsePolicy(aggregatorConfig.ResponsePolicy)
+	if err != nil {
+		return &Aggregator{}, err
+	}
+
+	client, err := avsconfig.NewClient(network)
+	if err != nil {
+		return &Aggregator{}, errors.Wrap(err, "Failed to create aptos client")
+	}
+
+	taskStore, err := OpenTaskStore(aggregatorConfig.DbPath)
+	if err != nil {
+		return &Aggregator{}, errors.Wrap(err, "Failed to open task store")
+	}
+
+	// Pick up the tasks that were still collecting responses before a restart
+	pendingTasks, err := taskStore.LoadTasks(func(taskInfo TaskInfo) bool {
+		return !taskInfo.CurrentStatus().Terminal()
+	})
+	if err != nil {
+		taskStore.Close()
+		return &Aggregator{}, errors.Wrap(err, "Failed to load pending tasks")
+	}
+
 	agg := Aggregator{
 		logger:            logger,
 		AvsAddress:        aggregatorConfig.AvsAddress,
 		AggregatorAccount: *aggegator_account,
 		AggregatorConfig:  aggregatorConfig,
-		TaskQueue:         make(chan Task, taskQueueSize),
-		PendingTasks:      make(map[uint64]TaskInfo),
+		PendingTasks:      pendingTasks,
+		TaskStore:         taskStore,
+		TxManager:         NewTxManager(logger, client, aggegator_account, aggregatorConfig.TxManager),
+		TaskFeed:          NewTaskFeed(),
+		Admission:         NewAdmission(aggregatorConfig.RateLimit),
+		TaskLocks:         NewTaskLocks(),
+		Errors:            errorRecorder,
 
 		Network: network,
+		Client:  client,
 	}
 	return &agg, nil
 }
@@ -49,6 +83,25 @@ func (agg *Aggregator) Start(ctx context.Context) error {
 		}
 	}()
 
+	agg.resumeSubmissions()
+
+	if agg.AggregatorConfig.MetricsAddress != "" {
+		go func() {
+			err := metrics.Serve(ctx, agg.logger, agg.AggregatorConfig.MetricsAddress)
+			if err != nil {
+				agg.logger.Fatal("Error starting metrics server", zap.Any("err", err))
+			}
+		}()
+	}
+	if agg.AggregatorConfig.AdminAddress != "" {
+		go func() {
+			err := agg.ServeAdmin(ctx)
+			if err != nil {
+				agg.logger.Fatal("Error starting admin server", zap.Any("err", err))
+			}
+		}()
+	}
+
 	go func() {
 		agg.logger.Info("Fetching tasks process started...")
 		err := agg.FetchTasks(ctx)
@@ -57,6 +110,14 @@ func (agg *Aggregator) Start(ctx context.Context) error {
 		}
 	}()
 
+	go func() {
+		agg.logger.Info("Task sweeper started...")
+		err := agg.SweepTasks(ctx)
+		if err != nil {
+			agg.logger.Fatal("Error sweeping tasks", zap.Any("err", err))
+		}
+	}()
+
 	go func() {
 		agg.logger.Info("Chore process started...")
 		err := agg.DoChore(ctx)
@@ -74,11 +135,11 @@ func (agg *Aggregator) Start(ctx context.Context) error {
 
 	cancel()
 
-	return nil
+	return agg.TaskStore.Close()
 }
 
 func (agg *Aggregator) FetchTasks(ctx context.Context) error {
-	client, err := aptos.NewClient(agg.Network)
+	client, err := avsconfig.NewClient(agg.Network)
 	if err != nil {
 		return fmt.Errorf("failed to create aptos client: %v", err)
 	}
@@ -89,55 +150,110 @@ func (agg *Aggregator) FetchTasks(ctx context.Context) error {
 		return fmt.Errorf("error parsing avs address: %v", err)
 	}
 
-	var taskCount uint64
+	mode := TaskSourceMode(agg.AggregatorConfig.TaskSource)
+	taskSource, err := NewTaskSource(mode, client, agg.Network.IndexerUrl, avs, agg.AggregatorConfig.TaskStartVersion)
+	if err != nil {
+		return err
+	}
+	checkpoint, found, err := agg.TaskStore.Checkpoint(mode)
+	if err != nil {
+		return fmt.Errorf("error loading task source checkpoint: %v", err)
+	}
+	if found {
+		agg.logger.Info("Resuming task source from checkpoint", zap.String("mode", mode), zap.Uint64("checkpoint", checkpoint))
+		taskSource.Resume(checkpoint)
+	} else {
+		checkpoint = taskSource.Checkpoint()
+	}
+
 	// looping
 	for {
-		previousTaskCount := taskCount
-		newTaskCount, err := LatestTaskCount(client, avs)
-		if err != nil {
-			agg.logger.Warn("Failed to subscribe to new tasks", zap.Any("err", err))
-			time.Sleep(RetryInterval)
-			continue
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
 		}
-		taskCount = newTaskCount
 
-		if taskCount > previousTaskCount {
-			err := agg.QueueTask(ctx, avs, client, previousTaskCount, taskCount)
+		tasks, err := taskSource.NewTasks(ctx)
+		if err != nil {
+			agg.logger.Warn("Failed to fetch new tasks", zap.Any("err", err))
+		}
+		// a source may return the tasks it read before failing
+		err = agg.QueueTasks(tasks)
+		if err != nil {
+			// the checkpoint only moves past tasks that were stored, the
+			// source reads the others again
+			agg.logger.Error("Failed to queue new tasks", zap.Any("err", err))
+			taskSource.Resume(checkpoint)
+		} else if taskSource.Checkpoint() != checkpoint {
+			err = agg.TaskStore.SaveCheckpoint(mode, taskSource.Checkpoint())
 			if err != nil {
-				return fmt.Errorf("error queuing task: %v", err)
+				agg.logger.Error("Failed to save task source checkpoint", zap.Any("err", err))
+			} else {
+				checkpoint = taskSource.Checkpoint()
 			}
 		}
-		time.Sleep(30 * time.Second)
+
+		time.Sleep(PollLatestBatchInterval)
 	}
 }
 
-func (agg *Aggregator) QueueTask(ctx context.Context, avs aptos.AccountAddress, client *aptos.Client, start uint64, end uint64) error {
-	for i := start + 1; i <= end; i++ {
-		task, err := LoadTaskById(client, avs, i)
-		if err != nil {
-			return fmt.Errorf("error loading task: %v", err)
-		}
-		responded := task["responded"].(bool)
+// QueueTasks stores and publishes the new tasks. It stops at the first task
+// that can not be stored, the tasks after it are not queued either.
+func (agg *Aggregator) QueueTasks(tasks []Task) error {
+	for _, task := range tasks {
+		tasksSeen.Inc()
+		responded, _ := task.Task["responded"].(bool)
 		if responded {
 			continue
 		}
-		agg.logger.Info("Loaded new task with id: %d", zap.Any("task id", i))
-		fmt.Println("task :", task)
-		agg.TaskQueue <- Task{
-			Id:   i,
-			Task: task,
-		}
-		agg.TaskMutex.Lock()
-		if _, exists := agg.PendingTasks[i]; !exists {
-			agg.PendingTasks[i] = TaskInfo{
-				State:     task,
-				Responses: make([]SignedTaskResponse, 0),
-			}
+		agg.logger.Info("Loaded new task with id: %d", zap.Any("task id", task.Id))
+		if err := agg.queueTask(task); err != nil {
+			return fmt.Errorf("can not queue task %d: %v", task.Id, err)
 		}
-		agg.TaskMutex.Unlock()
-		agg.logger.Info("Queued new task with id: %d", zap.Any("task id", i))
+		agg.logger.Info("Queued new task with id: %d", zap.Any("task id", task.Id))
+	}
+	return nil
+}
+
+func (agg *Aggregator) queueTask(task Task) error {
+	unlock := agg.TaskLocks.Lock(task.Id)
+	defer unlock()
+	return agg.queueLockedTask(task)
+}
+
+// queueLockedTask is queueTask for a caller holding the task lock.
+func (agg *Aggregator) queueLockedTask(task Task) error {
+	if _, exists := agg.pendingTask(task.Id); exists {
+		return nil
+	}
+	// tasks that already ended are only kept in the store
+	_, exists, err := agg.TaskStore.LoadTask(task.Id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
 	}
 
+	taskInfo := TaskInfo{
+		State:     task.Task,
+		Responses: make([]SignedTaskResponse, 0),
+		Status:    TaskCollecting,
+	}
+	expired := agg.isExpired(taskInfo, time.Now())
+	if expired {
+		agg.expireTask(task.Id, &taskInfo)
+	}
+	// the task is only kept in memory and published once it is stored
+	if err := agg.TaskStore.SaveTask(task.Id, taskInfo); err != nil {
+		return err
+	}
+	agg.setPendingTask(task.Id, taskInfo)
+	if !expired {
+		tasksQueued.Inc()
+		agg.TaskFeed.Publish(task)
+	}
 	return nil
 }
 
@@ -157,7 +273,7 @@ func LoadTaskById(client *aptos.Client, contract aptos.AccountAddress, taskId ui
 			taskIdBcs,
 		},
 	}
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return nil, fmt.Errorf("can not get task count: %v", err)
 	}
@@ -176,7 +292,7 @@ func LatestTaskCount(client *aptos.Client, contract aptos.AccountAddress) (uint6
 		Args:     [][]byte{},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return 0, fmt.Errorf("can not get task count: %v", err)
 	}
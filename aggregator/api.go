@@ -0,0 +1,378 @@
+package aggregator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version 1 of the operator API is JSON-RPC 2.0 over HTTP POST on ApiPath.
+// Byte strings are 0x prefixed hex and u128 values decimal strings, so that
+// operators do not need Go to take part.
+const (
+	ApiVersion = "v1"
+	ApiPath    = "/" + ApiVersion + "/rpc"
+
+	MethodSubmitResponse = "submitResponse"
+	MethodTaskStatus     = "taskStatus"
+	MethodOperatorSet    = "operatorSet"
+	MethodSubscribeTasks = "subscribeTasks"
+	MethodAuthChallenge  = "authChallenge"
+	MethodAuthLogin      = "authLogin"
+
+	maxRequestSize = 1 << 20
+)
+
+// JSON-RPC error codes
+const (
+	RpcParseError     = -32700
+	RpcInvalidRequest = -32600
+	RpcMethodNotFound = -32601
+	RpcInvalidParams  = -32602
+	RpcInternalError  = -32603
+	RpcUnauthorized   = -32001
+	RpcUnknownTask    = -32004
+	// RpcBusy and RpcRateLimited are sent instead of processing a request,
+	// it may be sent again after a short wait
+	RpcBusy        = -32005
+	RpcRateLimited = -32029
+)
+
+type ResponseStatus string
+
+const (
+	ResponseAccepted             ResponseStatus = "accepted"
+	ResponseDuplicate            ResponseStatus = "duplicate"
+	ResponseConflicting          ResponseStatus = "conflicting"
+	ResponseMalformed            ResponseStatus = "malformed"
+	ResponseUnknownOperator      ResponseStatus = "unknown-operator"
+	ResponseInvalidSignature     ResponseStatus = "invalid-signature"
+	ResponseUnknownTask          ResponseStatus = "unknown-task"
+	ResponseTaskExpired          ResponseStatus = "task-expired"
+	ResponseQuorumAlreadyReached ResponseStatus = "quorum-already-reached"
+)
+
+var statusErrors = map[ResponseStatus]error{
+	ResponseDuplicate:            ErrDuplicateResponse,
+	ResponseConflicting:          ErrConflictingResponse,
+	ResponseMalformed:            ErrMalformedResponse,
+	ResponseUnknownOperator:      ErrUnknownOperator,
+	ResponseInvalidSignature:     ErrInvalidSignature,
+	ResponseUnknownTask:          ErrUnknownTask,
+	ResponseTaskExpired:          ErrTaskExpired,
+	ResponseQuorumAlreadyReached: ErrQuorumAlreadyReached,
+}
+
+// responseStatus maps the result of processTaskResponse to a status, ok is
+// false for errors that are not about the response itself.
+func responseStatus(err error) (ResponseStatus, bool) {
+	if err == nil {
+		return ResponseAccepted, true
+	}
+	for status, statusErr := range statusErrors {
+		if errors.Is(err, statusErr) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+type HexBytes []byte
+
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0x" + hex.EncodeToString(b))
+}
+
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	bz, err := hex.DecodeString(strings.TrimPrefix(str, "0x"))
+	if err != nil {
+		return err
+	}
+	*b = bz
+	return nil
+}
+
+type RpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type RpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RpcError       `json:"error,omitempty"`
+}
+
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type SubmitResponseParams struct {
+	TaskId    uint64   `json:"task_id"`
+	Pubkey    HexBytes `json:"pubkey"`
+	Signature HexBytes `json:"signature"`
+	Response  string   `json:"response"`
+}
+
+type SubmitResponseResult struct {
+	Status ResponseStatus `json:"status"`
+}
+
+type TaskStatusParams struct {
+	TaskId uint64 `json:"task_id"`
+}
+
+type TaskStatusResult struct {
+	TaskId        uint64     `json:"task_id"`
+	Status        TaskStatus `json:"status"`
+	Responses     int        `json:"responses"`
+	LateResponses int        `json:"late_responses"`
+	Equivocations int        `json:"equivocations"`
+	SignedStake   uint64     `json:"signed_stake"`
+	TotalStake    uint64     `json:"total_stake"`
+	TxHashes      []string   `json:"tx_hashes"`
+}
+
+type OperatorSetResult struct {
+	Ready     bool           `json:"ready"`
+	Operators []OperatorInfo `json:"operators"`
+}
+
+type OperatorInfo struct {
+	Pubkey  HexBytes `json:"pubkey"`
+	Address string   `json:"address"`
+}
+
+type SubscribeTasksParams struct {
+	Cursor      uint64 `json:"cursor"`
+	WaitSeconds uint64 `json:"wait_seconds"`
+}
+
+type SubscribeTasksResult struct {
+	Cursor uint64    `json:"cursor"`
+	Tasks  []ApiTask `json:"tasks"`
+}
+
+type ApiTask struct {
+	Id   uint64                 `json:"id"`
+	Task map[string]interface{} `json:"task"`
+}
+
+// ServeHTTP answers the v1 JSON-RPC API.
+func (agg *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !agg.Admission.AllowPeer(r) {
+		writeRpcResponse(w, nil, nil, &RpcError{Code: RpcRateLimited, Message: ErrRateLimited.Error()})
+		return
+	}
+
+	var req RpcRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		writeRpcResponse(w, nil, nil, &RpcError{Code: RpcParseError, Message: err.Error()})
+		return
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeRpcResponse(w, nil, nil, &RpcError{Code: RpcParseError, Message: err.Error()})
+		return
+	}
+	if req.JsonRpc != "2.0" || req.Method == "" {
+		writeRpcResponse(w, req.Id, nil, &RpcError{Code: RpcInvalidRequest, Message: "expected a jsonrpc 2.0 request"})
+		return
+	}
+
+	// with challenge auth every other method needs a session
+	var s *session
+	if agg.Authenticator != nil && req.Method != MethodAuthChallenge && req.Method != MethodAuthLogin {
+		current, ok := agg.Authenticator.session(r)
+		if !ok {
+			writeRpcResponse(w, req.Id, nil, &RpcError{Code: RpcUnauthorized, Message: "missing or expired session, log in with authChallenge and authLogin"})
+			return
+		}
+		s = &current
+	}
+
+	result, rpcErr := agg.handleRpc(req.Method, req.Params, s)
+	writeRpcResponse(w, req.Id, result, rpcErr)
+}
+
+func (agg *Aggregator) handleRpc(method string, params json.RawMessage, s *session) (interface{}, *RpcError) {
+	switch method {
+	case MethodAuthChallenge, MethodAuthLogin:
+		if agg.Authenticator == nil {
+			return nil, &RpcError{Code: RpcMethodNotFound, Message: "authentication is not enabled"}
+		}
+		if method == MethodAuthChallenge {
+			result, err := agg.Authenticator.Challenge()
+			if err != nil {
+				return nil, &RpcError{Code: RpcInternalError, Message: err.Error()}
+			}
+			return result, nil
+		}
+		var p LoginParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RpcError{Code: RpcInvalidParams, Message: err.Error()}
+		}
+		result, err := agg.Authenticator.Login(p)
+		if errors.Is(err, ErrUnauthorized) {
+			return nil, &RpcError{Code: RpcUnauthorized, Message: err.Error()}
+		}
+		if err != nil {
+			return nil, &RpcError{Code: RpcInternalError, Message: err.Error()}
+		}
+		return result, nil
+
+	case MethodSubmitResponse:
+		var p SubmitResponseParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RpcError{Code: RpcInvalidParams, Message: err.Error()}
+		}
+		response, ok := new(big.Int).SetString(p.Response, 10)
+		if !ok {
+			return SubmitResponseResult{Status: ResponseMalformed}, nil
+		}
+		if s != nil && !s.owns(agg, p.Pubkey) {
+			return nil, &RpcError{Code: RpcUnauthorized, Message: "the session can not submit responses for this pubkey"}
+		}
+		status, err := agg.SubmitResponse(SignedTaskResponse{
+			TaskId:    p.TaskId,
+			Pubkey:    p.Pubkey,
+			Signature: p.Signature,
+			Response:  response,
+		})
+		if errors.Is(err, ErrRateLimited) {
+			return nil, &RpcError{Code: RpcRateLimited, Message: err.Error()}
+		}
+		if errors.Is(err, ErrBusy) {
+			return nil, &RpcError{Code: RpcBusy, Message: err.Error()}
+		}
+		if err != nil {
+			return nil, &RpcError{Code: RpcInternalError, Message: err.Error()}
+		}
+		return SubmitResponseResult{Status: status}, nil
+
+	case MethodTaskStatus:
+		var p TaskStatusParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RpcError{Code: RpcInvalidParams, Message: err.Error()}
+		}
+		result, found, err := agg.TaskStatus(p.TaskId)
+		if err != nil {
+			return nil, &RpcError{Code: RpcInternalError, Message: err.Error()}
+		}
+		if !found {
+			return nil, &RpcError{Code: RpcUnknownTask, Message: fmt.Sprintf("task %d is not known to the aggregator", p.TaskId)}
+		}
+		return result, nil
+
+	case MethodOperatorSet:
+		return agg.OperatorSetInfo(), nil
+
+	case MethodSubscribeTasks:
+		var p SubscribeTasksParams
+		if len(params) != 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, &RpcError{Code: RpcInvalidParams, Message: err.Error()}
+			}
+		}
+		var batch TaskBatch
+		agg.SubscribeTasks(TaskSubscription{Cursor: p.Cursor, WaitSeconds: p.WaitSeconds}, &batch)
+		result := SubscribeTasksResult{Cursor: batch.Cursor, Tasks: []ApiTask{}}
+		for _, task := range batch.Tasks {
+			result.Tasks = append(result.Tasks, ApiTask{Id: task.Id, Task: task.Task})
+		}
+		return result, nil
+
+	default:
+		return nil, &RpcError{Code: RpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+	}
+}
+
+func writeRpcResponse(w http.ResponseWriter, id json.RawMessage, result interface{}, rpcErr *RpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := RpcResponse{
+		JsonRpc: "2.0",
+		Id:      id,
+		Error:   rpcErr,
+	}
+	if rpcErr == nil {
+		bz, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &RpcError{Code: RpcInternalError, Message: err.Error()}
+		} else {
+			resp.Result = bz
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// TaskStatus reports a task the aggregator tracks, in memory or in the store.
+func (agg *Aggregator) TaskStatus(taskId uint64) (TaskStatusResult, bool, error) {
+	agg.TaskMutex.Lock()
+	taskInfo, found := agg.PendingTasks[taskId]
+	agg.TaskMutex.Unlock()
+	if !found {
+		var err error
+		taskInfo, found, err = agg.TaskStore.LoadTask(taskId)
+		if err != nil || !found {
+			return TaskStatusResult{}, false, err
+		}
+	}
+	return TaskStatusResult{
+		TaskId:        taskId,
+		Status:        taskInfo.CurrentStatus(),
+		Responses:     len(taskInfo.Responses),
+		LateResponses: len(taskInfo.LateResponses),
+		Equivocations: len(taskInfo.Equivocations),
+		SignedStake:   taskInfo.SignedStake,
+		TotalStake:    taskInfo.TotalStake,
+		TxHashes:      taskInfo.TxHashes,
+	}, true, nil
+}
+
+// OperatorSetInfo lists the operators found by the last chore.
+func (agg *Aggregator) OperatorSetInfo() OperatorSetResult {
+	agg.OperatorSetMutex.RLock()
+	defer agg.OperatorSetMutex.RUnlock()
+
+	result := OperatorSetResult{
+		Ready:     agg.OperatorSet != nil,
+		Operators: []OperatorInfo{},
+	}
+	for pubkeyHex, addr := range agg.OperatorSet {
+		pubkey, _ := hex.DecodeString(pubkeyHex)
+		result.Operators = append(result.Operators, OperatorInfo{
+			Pubkey:  pubkey,
+			Address: addr.String(),
+		})
+	}
+	sort.Slice(result.Operators, func(i, j int) bool {
+		return result.Operators[i].Address < result.Operators[j].Address
+	})
+	return result
+}
@@ -0,0 +1,216 @@
+package aggregator
+
+import (
+	"avs/msghash"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+// newTestApi serves the operator API of an aggregator on the fake node, with
+// one registered operator.
+func newTestApi(t *testing.T, auth string) (*Aggregator, *httptest.Server, *crypto.BlsPrivateKey) {
+	t.Helper()
+	node := newFakeNode(t)
+	node.taskCount.Store(3)
+	agg := newTestAggregator(t, node)
+	agg.TaskFeed = NewTaskFeed()
+	agg.Admission = NewAdmission(RateLimitConfig{})
+	agg.AggregatorConfig.Auth = auth
+
+	key, err := crypto.GenerateBlsPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg.OperatorSet = map[string]aptos.AccountAddress{hex.EncodeToString(key.Inner.PublicKey().Marshal()): aptos.AccountOne}
+
+	mux, err := agg.operatorMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return agg, server, key
+}
+
+func postRpc(t *testing.T, server *httptest.Server, body string) RpcResponse {
+	t.Helper()
+	resp, err := http.Post(server.URL+ApiPath, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var rpcResp RpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		t.Fatalf("decoding response to %s: %v", body, err)
+	}
+	return rpcResp
+}
+
+func callRpc(t *testing.T, server *httptest.Server, method string, params interface{}, result interface{}) *RpcError {
+	t.Helper()
+	bz, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := json.Marshal(RpcRequest{JsonRpc: "2.0", Id: json.RawMessage("7"), Method: method, Params: bz})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := postRpc(t, server, string(req))
+	if string(resp.Id) != "7" {
+		t.Errorf("%s answered with id %s, want 7", method, resp.Id)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		t.Fatalf("%s result %s: %v", method, resp.Result, err)
+	}
+	return nil
+}
+
+func TestApiMethods(t *testing.T) {
+	_, server, key := newTestApi(t, AuthNone)
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed("0xc0ffee"); err != nil {
+		t.Fatal(err)
+	}
+	msgHash, err := msghash.MsgHash(2, creator, big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := key.Sign(msgHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := SubmitResponseParams{
+		TaskId:    2,
+		Pubkey:    key.Inner.PublicKey().Marshal(),
+		Signature: signature.Signature().Bytes(),
+		Response:  "1000",
+	}
+
+	var operators OperatorSetResult
+	if rpcErr := callRpc(t, server, MethodOperatorSet, nil, &operators); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if !operators.Ready || len(operators.Operators) != 1 || !bytes.Equal(operators.Operators[0].Pubkey, params.Pubkey) {
+		t.Errorf("operator set %+v, want the registered operator", operators)
+	}
+
+	for _, want := range []ResponseStatus{ResponseAccepted, ResponseDuplicate} {
+		var result SubmitResponseResult
+		if rpcErr := callRpc(t, server, MethodSubmitResponse, params, &result); rpcErr != nil {
+			t.Fatal(rpcErr)
+		}
+		if result.Status != want {
+			t.Errorf("submitResponse status %s, want %s", result.Status, want)
+		}
+	}
+	malformed := params
+	malformed.Response = "a lot"
+	var result SubmitResponseResult
+	if rpcErr := callRpc(t, server, MethodSubmitResponse, malformed, &result); rpcErr != nil || result.Status != ResponseMalformed {
+		t.Errorf("submitResponse of a response that is not a number: %s %v, want %s", result.Status, rpcErr, ResponseMalformed)
+	}
+
+	var status TaskStatusResult
+	if rpcErr := callRpc(t, server, MethodTaskStatus, TaskStatusParams{TaskId: 2}, &status); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if status.TaskId != 2 || status.Status != TaskCollecting || status.Responses != 1 || status.SignedStake != 25 || status.TotalStake != 110 {
+		t.Errorf("task status %+v, want collecting with one response", status)
+	}
+	if rpcErr := callRpc(t, server, MethodTaskStatus, TaskStatusParams{TaskId: 9}, &status); rpcErr == nil || rpcErr.Code != RpcUnknownTask {
+		t.Errorf("taskStatus of an unknown task: %v, want code %d", rpcErr, RpcUnknownTask)
+	}
+
+	// the task loaded for the response was published
+	var tasks SubscribeTasksResult
+	if rpcErr := callRpc(t, server, MethodSubscribeTasks, SubscribeTasksParams{WaitSeconds: 1}, &tasks); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if len(tasks.Tasks) != 1 || tasks.Tasks[0].Id != 2 || tasks.Cursor == 0 {
+		t.Errorf("subscribeTasks %+v, want task 2", tasks)
+	}
+}
+
+func TestApiErrors(t *testing.T) {
+	_, server, _ := newTestApi(t, AuthNone)
+
+	for _, tc := range []struct {
+		name, body string
+		code       int
+		id         string
+	}{
+		{"parse error", `{"jsonrpc":"2.0",`, RpcParseError, "null"},
+		{"not a request", `{"id":1,"method":"taskStatus"}`, RpcInvalidRequest, "1"},
+		{"no method", `{"jsonrpc":"2.0","id":1}`, RpcInvalidRequest, "1"},
+		{"unknown method", `{"jsonrpc":"2.0","id":2,"method":"respondTask"}`, RpcMethodNotFound, "2"},
+		{"auth not enabled", `{"jsonrpc":"2.0","id":3,"method":"authChallenge"}`, RpcMethodNotFound, "3"},
+		{"bad task status params", `{"jsonrpc":"2.0","id":4,"method":"taskStatus","params":{"task_id":"two"}}`, RpcInvalidParams, "4"},
+		{"bad submit params", `{"jsonrpc":"2.0","id":"five","method":"submitResponse","params":{"task_id":2,"pubkey":"0xzz"}}`, RpcInvalidParams, `"five"`},
+		{"bad subscribe params", `{"jsonrpc":"2.0","id":6,"method":"subscribeTasks","params":[1]}`, RpcInvalidParams, "6"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := postRpc(t, server, tc.body)
+			if resp.Error == nil || resp.Error.Code != tc.code {
+				t.Errorf("error %v, want code %d", resp.Error, tc.code)
+			}
+			if string(resp.Id) != tc.id {
+				t.Errorf("id %s, want %s", resp.Id, tc.id)
+			}
+			if resp.Result != nil {
+				t.Errorf("result %s sent with the error", resp.Result)
+			}
+		})
+	}
+
+	resp, err := http.Get(server.URL + ApiPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestApiGobShim(t *testing.T) {
+	_, server, _ := newTestApi(t, AuthNone)
+	client, err := rpc.DialHTTP("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("gob API not served without auth: %v", err)
+	}
+	var batch TaskBatch
+	if err := client.Call("Aggregator.SubscribeTasks", TaskSubscription{WaitSeconds: 1}, &batch); err != nil {
+		t.Errorf("gob SubscribeTasks: %v", err)
+	}
+	client.Close()
+
+	// with challenge auth only the JSON-RPC API is served, and it needs a
+	// session
+	_, server, _ = newTestApi(t, AuthChallenge)
+	if client, err := rpc.DialHTTP("tcp", server.Listener.Addr().String()); err == nil {
+		client.Close()
+		t.Error("gob API served with challenge auth")
+	}
+	var operators OperatorSetResult
+	if rpcErr := callRpc(t, server, MethodOperatorSet, nil, &operators); rpcErr == nil || rpcErr.Code != RpcUnauthorized {
+		t.Errorf("operatorSet without a session: %v, want code %d", rpcErr, RpcUnauthorized)
+	}
+	var challenge ChallengeResult
+	if rpcErr := callRpc(t, server, MethodAuthChallenge, nil, &challenge); rpcErr != nil || len(challenge.Nonce) == 0 {
+		t.Errorf("authChallenge: %+v %v", challenge, rpcErr)
+	}
+}
@@ -0,0 +1,295 @@
+package aggregator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	AuthNone      = ""
+	AuthChallenge = "challenge"
+
+	AuthSchemeBls     = "bls"
+	AuthSchemeEd25519 = "ed25519"
+
+	// LoginPrefix is prepended to the challenge nonce before signing it, so
+	// that a login signature can not be replayed as anything else.
+	LoginPrefix = "AVS_AGGREGATOR_LOGIN"
+
+	ChallengeTtl   = 1 * time.Minute
+	SessionTtl     = 1 * time.Hour
+	challengeBytes = 32
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// TlsConfig enables TLS on the operator API. Setting ClientCaFile requires
+// operators to present a certificate signed by it.
+type TlsConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCaFile string `json:",omitempty"`
+}
+
+func (c *TlsConfig) serverConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCaFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(c.ClientCaFile)
+	if err != nil {
+		return nil, fmt.Errorf("can not read client ca file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", c.ClientCaFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// LoginMessage is what an operator signs to answer a challenge. It is a
+// 32 byte digest because BLS verification only takes 32 byte messages.
+func LoginMessage(nonce []byte) []byte {
+	digest := sha256.Sum256(append([]byte(LoginPrefix), nonce...))
+	return digest[:]
+}
+
+type ChallengeResult struct {
+	Nonce     HexBytes `json:"nonce"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+// LoginParams answers a challenge. With the bls scheme Pubkey is the
+// operator's BLS pubkey, with ed25519 it is the public key of the operator's
+// Aptos account and Address that account.
+type LoginParams struct {
+	Nonce     HexBytes `json:"nonce"`
+	Scheme    string   `json:"scheme"`
+	Pubkey    HexBytes `json:"pubkey"`
+	Address   string   `json:"address,omitempty"`
+	Signature HexBytes `json:"signature"`
+}
+
+type LoginResult struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// session is an authenticated operator, known by its BLS pubkey or its
+// account address depending on how it logged in.
+type session struct {
+	pubkey    []byte
+	address   aptos.AccountAddress
+	expiresAt time.Time
+}
+
+// Authenticator hands out login challenges and the session tokens operators
+// present in the Authorization header afterwards. Challenges are not stored:
+// the nonce carries its expiry and a MAC over both, so anyone may ask for as
+// many as they like. Only the nonces of successful logins are remembered, to
+// refuse replays until they expire.
+type Authenticator struct {
+	agg *Aggregator
+	key []byte
+
+	mu       sync.Mutex
+	used     map[string]time.Time
+	sessions map[string]session
+}
+
+func NewAuthenticator(agg *Aggregator) (*Authenticator, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("can not generate challenge key: %v", err)
+	}
+	return &Authenticator{
+		agg:      agg,
+		key:      key,
+		used:     make(map[string]time.Time),
+		sessions: make(map[string]session),
+	}, nil
+}
+
+// Challenge returns a nonce of challengeBytes random bytes, the big endian
+// unix time it expires at and the MAC of both.
+func (a *Authenticator) Challenge() (ChallengeResult, error) {
+	nonce := make([]byte, challengeBytes, challengeBytes+8+sha256.Size)
+	if _, err := rand.Read(nonce); err != nil {
+		return ChallengeResult{}, err
+	}
+	expiresAt := time.Now().Add(ChallengeTtl)
+	nonce = binary.BigEndian.AppendUint64(nonce, uint64(expiresAt.Unix()))
+	nonce = append(nonce, a.mac(nonce)...)
+	return ChallengeResult{Nonce: nonce, ExpiresAt: expiresAt.Unix()}, nil
+}
+
+// checkNonce returns when a nonce handed out by Challenge expires.
+func (a *Authenticator) checkNonce(nonce []byte) (time.Time, error) {
+	if len(nonce) != challengeBytes+8+sha256.Size {
+		return time.Time{}, errors.Wrap(ErrUnauthorized, "unknown challenge")
+	}
+	signed, mac := nonce[:challengeBytes+8], nonce[challengeBytes+8:]
+	if !hmac.Equal(mac, a.mac(signed)) {
+		return time.Time{}, errors.Wrap(ErrUnauthorized, "unknown challenge")
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(signed[challengeBytes:])), 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, errors.Wrap(ErrUnauthorized, "expired challenge")
+	}
+	return expiresAt, nil
+}
+
+func (a *Authenticator) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, a.key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (a *Authenticator) Login(params LoginParams) (LoginResult, error) {
+	nonceExpiresAt, err := a.checkNonce(params.Nonce)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	var s session
+	message := LoginMessage(params.Nonce)
+	switch params.Scheme {
+	case AuthSchemeBls:
+		var pubkey crypto.BlsPublicKey
+		var signature crypto.BlsSignature
+		if pubkey.FromBytes(params.Pubkey) != nil || signature.FromBytes(params.Signature) != nil {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "malformed bls key or signature")
+		}
+		if !pubkey.Verify(message, &signature) {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "invalid signature")
+		}
+		if err := a.agg.checkOperator(params.Pubkey); err != nil {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, err.Error())
+		}
+		s.pubkey = params.Pubkey
+
+	case AuthSchemeEd25519:
+		var pubkey crypto.Ed25519PublicKey
+		var signature crypto.Ed25519Signature
+		if pubkey.FromBytes(params.Pubkey) != nil || signature.FromBytes(params.Signature) != nil {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "malformed ed25519 key or signature")
+		}
+		if !pubkey.Verify(message, &signature) {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "invalid signature")
+		}
+		address := aptos.AccountAddress{}
+		if err := address.ParseStringRelaxed(params.Address); err != nil {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "invalid address")
+		}
+		if !a.agg.isOperatorAddress(address) {
+			return LoginResult{}, errors.Wrapf(ErrUnauthorized, "%s is not in the registered operator set", address)
+		}
+		// the key must be the one currently controlling the account
+		authKey, err := a.agg.authenticationKey(address)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		if !bytes.Equal(authKey, pubkey.AuthKey().Bytes()) {
+			return LoginResult{}, errors.Wrap(ErrUnauthorized, "key does not control the account")
+		}
+		s.address = address
+
+	default:
+		return LoginResult{}, errors.Wrapf(ErrUnauthorized, "unknown scheme %q", params.Scheme)
+	}
+
+	token := make([]byte, challengeBytes)
+	if _, err := rand.Read(token); err != nil {
+		return LoginResult{}, err
+	}
+	s.expiresAt = time.Now().Add(SessionTtl)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prune()
+	nonce := hex.EncodeToString(params.Nonce)
+	if _, ok := a.used[nonce]; ok {
+		return LoginResult{}, errors.Wrap(ErrUnauthorized, "challenge already used")
+	}
+	a.used[nonce] = nonceExpiresAt
+	a.sessions[hex.EncodeToString(token)] = s
+	return LoginResult{Token: hex.EncodeToString(token), ExpiresAt: s.expiresAt.Unix()}, nil
+}
+
+// session returns the session of the bearer token in r.
+func (a *Authenticator) session(r *http.Request) (session, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return session{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[token]
+	if !ok || time.Now().After(s.expiresAt) {
+		return session{}, false
+	}
+	return s, true
+}
+
+// prune drops expired nonces and sessions, callers hold mu.
+func (a *Authenticator) prune() {
+	now := time.Now()
+	for nonce, expiresAt := range a.used {
+		if now.After(expiresAt) {
+			delete(a.used, nonce)
+		}
+	}
+	for token, s := range a.sessions {
+		if now.After(s.expiresAt) {
+			delete(a.sessions, token)
+		}
+	}
+}
+
+// owns reports whether the session may submit responses signed by pubkey.
+func (s session) owns(agg *Aggregator, pubkey []byte) bool {
+	if s.pubkey != nil {
+		return bytes.Equal(s.pubkey, pubkey)
+	}
+	agg.OperatorSetMutex.RLock()
+	defer agg.OperatorSetMutex.RUnlock()
+	address, ok := agg.OperatorSet[hex.EncodeToString(pubkey)]
+	return ok && address == s.address
+}
+
+func (agg *Aggregator) isOperatorAddress(address aptos.AccountAddress) bool {
+	agg.OperatorSetMutex.RLock()
+	defer agg.OperatorSetMutex.RUnlock()
+	for _, operator := range agg.OperatorSet {
+		if operator == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (agg *Aggregator) authenticationKey(address aptos.AccountAddress) ([]byte, error) {
+	info, err := agg.Client.Account(address)
+	if err != nil {
+		return nil, fmt.Errorf("can not get account %s: %v", address, err)
+	}
+	return info.AuthenticationKey()
+}
@@ -0,0 +1,114 @@
+package aggregator
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *crypto.BlsPrivateKey) {
+	t.Helper()
+	key, err := crypto.GenerateBlsPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := &Aggregator{
+		logger:      zap.NewNop(),
+		OperatorSet: map[string]aptos.AccountAddress{hex.EncodeToString(key.Inner.PublicKey().Marshal()): {}},
+	}
+	authenticator, err := NewAuthenticator(agg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator, key
+}
+
+func blsLogin(t *testing.T, key *crypto.BlsPrivateKey, nonce []byte) LoginParams {
+	t.Helper()
+	signature, err := key.Sign(LoginMessage(nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoginParams{
+		Nonce:     nonce,
+		Scheme:    AuthSchemeBls,
+		Pubkey:    key.Inner.PublicKey().Marshal(),
+		Signature: signature.Signature().Bytes(),
+	}
+}
+
+func TestChallengeLogin(t *testing.T) {
+	authenticator, key := newTestAuthenticator(t)
+
+	challenge, err := authenticator.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := authenticator.Login(blsLogin(t, key, challenge.Nonce))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if result.Token == "" {
+		t.Error("login returned no token")
+	}
+
+	// a captured login can not be replayed
+	if _, err := authenticator.Login(blsLogin(t, key, challenge.Nonce)); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("replayed login: %v, want unauthorized", err)
+	}
+}
+
+func TestChallengeRejectsForgedNonce(t *testing.T) {
+	authenticator, key := newTestAuthenticator(t)
+
+	challenge, err := authenticator.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), challenge.Nonce...)
+	tampered[0] ^= 1
+	expired := append([]byte(nil), challenge.Nonce[:challengeBytes]...)
+	expired = binary.BigEndian.AppendUint64(expired, uint64(time.Now().Add(-time.Second).Unix()))
+	expired = append(expired, authenticator.mac(expired)...)
+
+	for name, nonce := range map[string][]byte{
+		"tampered":    tampered,
+		"expired":     expired,
+		"short":       challenge.Nonce[:challengeBytes],
+		"other key":   otherAuthenticatorNonce(t),
+		"not a nonce": make([]byte, len(challenge.Nonce)),
+	} {
+		if _, err := authenticator.Login(blsLogin(t, key, nonce)); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s nonce: %v, want unauthorized", name, err)
+		}
+	}
+}
+
+func TestChallengeKeepsNoState(t *testing.T) {
+	authenticator, _ := newTestAuthenticator(t)
+	for i := 0; i < 20000; i++ {
+		if _, err := authenticator.Challenge(); err != nil {
+			t.Fatalf("challenge %d: %v", i, err)
+		}
+	}
+	authenticator.mu.Lock()
+	defer authenticator.mu.Unlock()
+	if len(authenticator.used) != 0 || len(authenticator.sessions) != 0 {
+		t.Errorf("challenges left %d nonces and %d sessions behind", len(authenticator.used), len(authenticator.sessions))
+	}
+}
+
+func otherAuthenticatorNonce(t *testing.T) []byte {
+	other, _ := newTestAuthenticator(t)
+	challenge, err := other.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge.Nonce
+}
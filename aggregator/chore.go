@@ -1,6 +1,8 @@
 package aggregator
 
 import (
+	"avs/avsconfig"
+	"avs/metrics"
 	"context"
 	"encoding/hex"
 	"fmt"
@@ -9,12 +11,13 @@ import (
 
 	"github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"go.uber.org/zap"
 )
 
 const ChoreInterval = 1 * time.Minute
 
 func (agg *Aggregator) DoChore(ctx context.Context) error {
-	client, err := aptos.NewClient(agg.Network)
+	client, err := avsconfig.NewClient(agg.Network)
 	if err != nil {
 		return fmt.Errorf("failed to create aptos client: %v", err)
 	}
@@ -26,6 +29,7 @@ func (agg *Aggregator) DoChore(ctx context.Context) error {
 	}
 
 	for {
+		start := time.Now()
 		// Get quorum count
 		quorumCount, err := QuorumCount(client, avsAddress)
 		if err != nil {
@@ -71,12 +75,29 @@ func (agg *Aggregator) DoChore(ctx context.Context) error {
 			}
 		}
 
+		operatorSet := make(map[string]aptos.AccountAddress)
+		for _, addrs := range operatorAddresses {
+			for _, addr := range addrs {
+				pubkey, err := GetOperatorPubkey(client, avsAddress, addr)
+				if err != nil {
+					return fmt.Errorf("can not get operator pubkey %v", err)
+				}
+				operatorSet[hex.EncodeToString(pubkey)] = addr
+			}
+		}
+		agg.OperatorSetMutex.Lock()
+		agg.OperatorSet = operatorSet
+		agg.OperatorSetMutex.Unlock()
+
 		// Update quorums
-		err = UpdateOperatorsForQuorum(client, &agg.AggregatorAccount, agg.AvsAddress, quorumCount, operatorAddresses)
+		_, err = UpdateOperatorsForQuorum(agg.TxManager, agg.AvsAddress, quorumCount, operatorAddresses)
 		if err != nil {
-			return fmt.Errorf("can not update operators for quorum: %v", err)
+			agg.logger.Error("Can not update operators for quorum, retrying after 1 min", zap.Error(err))
+		} else {
+			agg.logger.Info("Done UpdateOperatorsForQuorum. Next update after 1 min")
 		}
-		agg.logger.Info("Done UpdateOperatorsForQuorum. Next update after 1 min")
+		agg.lastChore.Store(time.Now().Unix())
+		choreSeconds.Observe(time.Since(start).Seconds())
 		time.Sleep(ChoreInterval)
 	}
 }
@@ -92,7 +113,7 @@ func QuorumCount(client *aptos.Client, contract aptos.AccountAddress) (uint8, er
 		Args:     [][]byte{},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return 0, fmt.Errorf("no quorum found")
 	}
@@ -121,7 +142,7 @@ func GetOperatorListAtTimestamp(client *aptos.Client, contract aptos.AccountAddr
 		},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -146,7 +167,7 @@ func GetOperatorAddress(client *aptos.Client, contract aptos.AccountAddress, ope
 		},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return "", err
 	}
@@ -155,16 +176,15 @@ func GetOperatorAddress(client *aptos.Client, contract aptos.AccountAddress, ope
 }
 
 func UpdateOperatorsForQuorum(
-	client *aptos.Client,
-	aggregatorAccount *aptos.Account,
+	txManager *TxManager,
 	contractAddr string,
 	quorumNumbers uint8,
 	addresses [][]aptos.AccountAddress,
-) error {
+) (string, error) {
 	contract := aptos.AccountAddress{}
 	err := contract.ParseStringRelaxed(contractAddr)
 	if err != nil {
-		panic("Failed to parse address:" + err.Error())
+		return "", fmt.Errorf("failed to parse address: %v", err)
 	}
 
 	quorumSerializer := &bcs.Serializer{}
@@ -182,9 +202,8 @@ func UpdateOperatorsForQuorum(
 	}
 	addressesSerializer := &bcs.Serializer{}
 	bcs.SerializeSequence(vecAddrs, addressesSerializer)
-
-	if err != nil {
-		panic("Failed to serialize addresses:" + err.Error())
+	if err := addressesSerializer.Error(); err != nil {
+		return "", fmt.Errorf("failed to serialize addresses: %v", err)
 	}
 
 	payload := aptos.EntryFunction{
@@ -199,36 +218,5 @@ func UpdateOperatorsForQuorum(
 		},
 	}
 
-	// Build transaction
-	rawTxn, err := client.BuildTransaction(aggregatorAccount.AccountAddress(),
-		aptos.TransactionPayload{Payload: &payload})
-	if err != nil {
-		panic("Failed to build transaction:" + err.Error())
-	}
-
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(aggregatorAccount)
-	if err != nil {
-		panic("Failed to sign transaction:" + err.Error())
-	}
-
-	// Submit and wait for it to complete
-	submitResult, err := client.SubmitTransaction(signedTxn)
-	if err != nil {
-		panic("Failed to submit transaction:" + err.Error())
-	}
-	txnHash := submitResult.Hash
-
-	// Wait for the transaction
-	fmt.Printf("And we wait for the transaction %s to complete...\n", txnHash)
-	userTxn, err := client.WaitForTransaction(txnHash)
-	if err != nil {
-		panic("Failed to wait for transaction:" + err.Error())
-	}
-	fmt.Printf("The transaction completed with hash: %s and version %d\n", userTxn.Hash, userTxn.Version)
-	if !userTxn.Success {
-		// TODO: log something more
-		panic("Failed to update operators for quorum")
-	}
-	return nil
+	return txManager.Submit(aptos.TransactionPayload{Payload: &payload})
 }
@@ -28,6 +28,8 @@ func AggregatorCommand(zLogger *zap.Logger) *cobra.Command {
 		},
 	}
 
+	aggregatorCmd.PersistentFlags().String(flagConfig, "", "unified YAML config of the aggregator, replaces the JSON config and the network flag")
+
 	// // Add operator-specific subcommands here
 	aggregatorCmd.AddCommand(
 		Start(zLogger), // Example: 'operator start'
@@ -43,27 +45,12 @@ func Start(logger *zap.Logger) *cobra.Command {
 		Short: "start",
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Get all the flags
-			network, err := cmd.Flags().GetString(flagAptosNetwork)
-			if err != nil {
-				return errors.Wrap(err, flagAptosNetwork)
-			}
-			aggregatorConfigPath, err := cmd.Flags().GetString(flagAggregatorConfig)
-			if err != nil {
-				return errors.Wrap(err, flagAggregatorConfig)
-			}
-
-			aggregatorConfig, err := loadAggregatorConfig(aggregatorConfigPath)
-			if err != nil {
-				return fmt.Errorf("can not load aggregator config: %s", err)
-			}
-
-			networkConfig, err := extractNetwork(network)
+			nodeConfig, err := loadNodeConfig(cmd)
 			if err != nil {
-				return fmt.Errorf("wrong config: %s", err)
+				return err
 			}
 
-			aggregator, err := NewAggregator(*aggregatorConfig, logger, networkConfig)
+			aggregator, err := NewAggregator(nodeConfig.Aggregator, logger, nodeConfig.Network)
 			if err != nil {
 				logger.Error("Cannot create aggregator", zap.Any("err", err))
 				return err
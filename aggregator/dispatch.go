@@ -0,0 +1,109 @@
+package aggregator
+
+import (
+	"encoding/gob"
+	"sync"
+	"time"
+)
+
+const (
+	TaskFeedSize = 256
+
+	DefaultSubscribeWait = 30 * time.Second
+	MaxSubscribeWait     = 2 * time.Minute
+)
+
+func init() {
+	// task maps hold the decoded view or event json, which nests objects
+	// such as respond_fee_token
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// TaskSubscription asks for the tasks published after Cursor. The call
+// returns as soon as there are some, or after WaitSeconds with none.
+type TaskSubscription struct {
+	Cursor      uint64
+	WaitSeconds uint64
+}
+
+// TaskBatch answers a TaskSubscription, Cursor is the one to send next.
+type TaskBatch struct {
+	Tasks  []Task
+	Cursor uint64
+}
+
+// TaskFeed keeps the last TaskFeedSize tasks the aggregator picked up so that
+// operators long polling SubscribeTasks receive them without waiting for
+// their own chain polling. An operator that falls further behind than the
+// feed only gets the tasks still in it and relies on polling for the rest.
+type TaskFeed struct {
+	mu     sync.Mutex
+	tasks  []Task
+	next   uint64
+	notify chan struct{}
+}
+
+func NewTaskFeed() *TaskFeed {
+	return &TaskFeed{
+		notify: make(chan struct{}),
+	}
+}
+
+func (f *TaskFeed) Publish(task Task) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tasks = append(f.tasks, task)
+	if len(f.tasks) > TaskFeedSize {
+		f.tasks = f.tasks[len(f.tasks)-TaskFeedSize:]
+	}
+	f.next++
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// Since returns the tasks published from cursor on, waiting up to wait for
+// one when there is none yet.
+func (f *TaskFeed) Since(cursor uint64, wait time.Duration) ([]Task, uint64) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		f.mu.Lock()
+		if cursor > f.next {
+			// the aggregator restarted, start over
+			cursor = 0
+		}
+		if cursor < f.next {
+			first := f.next - uint64(len(f.tasks))
+			if cursor < first {
+				cursor = first
+			}
+			tasks := append([]Task(nil), f.tasks[cursor-first:]...)
+			next := f.next
+			f.mu.Unlock()
+			return tasks, next
+		}
+		notify := f.notify
+		f.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return nil, cursor
+		}
+	}
+}
+
+// SubscribeTasks is the RPC operators long poll for new tasks.
+func (agg *Aggregator) SubscribeTasks(subscription TaskSubscription, reply *TaskBatch) error {
+	wait := time.Duration(subscription.WaitSeconds) * time.Second
+	if wait == 0 {
+		wait = DefaultSubscribeWait
+	}
+	if wait > MaxSubscribeWait {
+		wait = MaxSubscribeWait
+	}
+	reply.Tasks, reply.Cursor = agg.TaskFeed.Since(subscription.Cursor, wait)
+	return nil
+}
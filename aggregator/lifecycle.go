@@ -0,0 +1,86 @@
+package aggregator
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// TaskStatus is where a task is in the aggregator's lifecycle:
+//
+//	collecting -> quorum-reached -> submitting -> confirmed
+//	                                           -> failed
+//	collecting -> expired
+//	quorum-reached -> collecting
+//
+// confirmed, failed and expired are terminal. A task in quorum-reached keeps
+// collecting responses until its collection window is over, responses arriving
+// after that are kept as late responses and never resubmitted. It goes back to
+// collecting when its stake is below quorum at the end of the window.
+type TaskStatus string
+
+const (
+	TaskCollecting    TaskStatus = "collecting"
+	TaskQuorumReached TaskStatus = "quorum-reached"
+	TaskSubmitting    TaskStatus = "submitting"
+	TaskConfirmed     TaskStatus = "confirmed"
+	TaskFailed        TaskStatus = "failed"
+	TaskExpired       TaskStatus = "expired"
+)
+
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	TaskCollecting:    {TaskQuorumReached, TaskExpired, TaskConfirmed},
+	TaskQuorumReached: {TaskSubmitting, TaskCollecting},
+	TaskSubmitting:    {TaskConfirmed, TaskFailed},
+}
+
+// CurrentStatus returns the task status, tasks stored before the status existed are
+// still collecting.
+func (t *TaskInfo) CurrentStatus() TaskStatus {
+	if t.Status == "" {
+		return TaskCollecting
+	}
+	return t.Status
+}
+
+// AcceptsResponses reports whether new responses still count towards the
+// submitted signatures.
+func (s TaskStatus) AcceptsResponses() bool {
+	return s == TaskCollecting || s == TaskQuorumReached
+}
+
+func (s TaskStatus) Terminal() bool {
+	return s == TaskConfirmed || s == TaskFailed || s == TaskExpired
+}
+
+// setTaskStatus moves the task to status, refusing transitions the lifecycle
+// does not allow.
+func (agg *Aggregator) setTaskStatus(taskId uint64, taskInfo *TaskInfo, status TaskStatus) error {
+	current := taskInfo.CurrentStatus()
+	for _, next := range taskTransitions[current] {
+		if next == status {
+			taskInfo.Status = status
+			agg.logger.Info("Task status changed", zap.Uint64("task id", taskId), zap.String("from", string(current)), zap.String("to", string(status)))
+			observeTaskStatus(*taskInfo, status)
+			return nil
+		}
+	}
+	return fmt.Errorf("task %d can not go from %s to %s", taskId, current, status)
+}
+
+// addLateResponse keeps a response that arrived after the task left
+// collecting, one per operator.
+func addLateResponse(taskInfo *TaskInfo, signedTaskResponse SignedTaskResponse) {
+	for _, response := range taskInfo.Responses {
+		if bytes.Equal(response.Pubkey, signedTaskResponse.Pubkey) {
+			return
+		}
+	}
+	for _, response := range taskInfo.LateResponses {
+		if bytes.Equal(response.Pubkey, signedTaskResponse.Pubkey) {
+			return
+		}
+	}
+	taskInfo.LateResponses = append(taskInfo.LateResponses, signedTaskResponse)
+}
@@ -0,0 +1,138 @@
+package aggregator
+
+import (
+	"avs/metrics"
+	"strconv"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsSubsystem = "aggregator"
+
+var (
+	tasksSeen = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "tasks_seen_total",
+		Help:      "Tasks read from the task source.",
+	})
+	tasksQueued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "tasks_queued_total",
+		Help:      "New tasks the aggregator started collecting responses for.",
+	})
+	responsesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_received_total",
+		Help:      "Signed task responses received from operators.",
+	})
+	responsesAccepted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_accepted_total",
+		Help:      "Signed task responses counted for their task.",
+	})
+	responsesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_rejected_total",
+		Help:      "Signed task responses not counted, by reason.",
+	}, []string{"reason"})
+	taskQuorumSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "task_quorum_seconds",
+		Help:      "Time from task_created_timestamp until the task reached quorum.",
+		Buckets:   taskDurationBuckets,
+	})
+	taskConfirmationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "task_confirmation_seconds",
+		Help:      "Time from task_created_timestamp until respond_to_task was confirmed on chain.",
+		Buckets:   taskDurationBuckets,
+	})
+	taskSignedStakePercent = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "task_signed_stake_percent",
+		Help:      "Percentage of the total stake that signed a task when it was submitted.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	})
+	transactionGasUsed = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "transaction_gas_used",
+		Help:      "Gas used by committed transactions, by entry function.",
+		Buckets:   prometheus.ExponentialBuckets(100, 2, 12),
+	}, []string{"function"})
+	choreSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "chore_duration_seconds",
+		Help:      "Duration of a chore run, loading the operator set and updating the quorums.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+)
+
+var taskDurationBuckets = []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+
+// sinceTaskCreated is the time since task_created_timestamp, ok is false when
+// the task has none.
+func sinceTaskCreated(taskInfo TaskInfo) (time.Duration, bool) {
+	timestampStr, ok := taskInfo.State["task_created_timestamp"].(string)
+	if !ok {
+		return 0, false
+	}
+	timestamp, err := strconv.ParseUint(timestampStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(time.Unix(int64(timestamp), 0)), true
+}
+
+// observeTaskStatus records the metrics of a task entering status.
+func observeTaskStatus(taskInfo TaskInfo, status TaskStatus) {
+	switch status {
+	case TaskQuorumReached:
+		if elapsed, ok := sinceTaskCreated(taskInfo); ok {
+			taskQuorumSeconds.Observe(elapsed.Seconds())
+		}
+	case TaskSubmitting:
+		if taskInfo.TotalStake != 0 {
+			taskSignedStakePercent.Observe(float64(taskInfo.SignedStake) * 100 / float64(taskInfo.TotalStake))
+		}
+	case TaskConfirmed:
+		if elapsed, ok := sinceTaskCreated(taskInfo); ok {
+			taskConfirmationSeconds.Observe(elapsed.Seconds())
+		}
+	}
+}
+
+func observeResponse(status ResponseStatus, err error) {
+	switch {
+	case status == ResponseAccepted:
+		responsesAccepted.Inc()
+	case status != "":
+		responsesRejected.WithLabelValues(string(status)).Inc()
+	case errors.Is(err, ErrBusy):
+		responsesRejected.WithLabelValues("busy").Inc()
+	case errors.Is(err, ErrRateLimited):
+		responsesRejected.WithLabelValues("rate-limited").Inc()
+	default:
+		responsesRejected.WithLabelValues("error").Inc()
+	}
+}
+
+func payloadFunction(payload aptos.TransactionPayload) string {
+	if entryFunction, ok := payload.Payload.(*aptos.EntryFunction); ok {
+		return entryFunction.Module.Name + "::" + entryFunction.Function
+	}
+	return "unknown"
+}
@@ -0,0 +1,148 @@
+package aggregator
+
+import (
+	"avs/avsconfig"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const flagConfig = "config"
+
+// NodeConfig is everything the aggregator runs with.
+type NodeConfig struct {
+	Network    aptos.NetworkConfig
+	Aggregator AggregatorConfig
+}
+
+// LoadNodeConfig reads and validates a unified config file of the aggregator
+// role, see avsconfig.File. Its Account replaces the AccountConfig of the
+// JSON config.
+func LoadNodeConfig(path string) (*NodeConfig, error) {
+	var aggregatorConfig AggregatorConfig
+	file, err := avsconfig.Load(path, avsconfig.RoleAggregator, &aggregatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	problems := &avsconfig.ValidationError{}
+	problems.Merge("", file.Network.Validate())
+	if aggregatorConfig.AccountConfig != (AccountConfig{}) {
+		problems.Add("Aggregator.AccountConfig", "set the account in the Account section instead")
+	}
+	aggregatorConfig.AccountConfig = AccountConfig{
+		AccountPath: file.Account.AptosConfig,
+		Profile:     file.Account.Profile,
+	}
+	if aggregatorConfig.AccountConfig.Profile == "" {
+		aggregatorConfig.AccountConfig.Profile = "aggregator"
+	}
+	problems.Merge("Aggregator", aggregatorConfig.Validate())
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
+
+	networkConfig, err := file.Network.NetworkConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &NodeConfig{Network: networkConfig, Aggregator: aggregatorConfig}, nil
+}
+
+// loadNodeConfig loads the unified config file given with --config, or else
+// the JSON aggregator config and the network flag.
+func loadNodeConfig(cmd *cobra.Command) (*NodeConfig, error) {
+	configPath, err := cmd.Flags().GetString(flagConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, flagConfig)
+	}
+	if configPath != "" {
+		for _, flag := range []string{flagAptosNetwork, flagAggregatorConfig} {
+			if cmd.Flags().Changed(flag) {
+				return nil, fmt.Errorf("--%s can not be combined with --%s, set it in the config file", flag, flagConfig)
+			}
+		}
+		return LoadNodeConfig(configPath)
+	}
+
+	network, err := cmd.Flags().GetString(flagAptosNetwork)
+	if err != nil {
+		return nil, errors.Wrap(err, flagAptosNetwork)
+	}
+	aggregatorConfigPath, err := cmd.Flags().GetString(flagAggregatorConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, flagAggregatorConfig)
+	}
+	aggregatorConfig, err := loadAggregatorConfig(aggregatorConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("can not load aggregator config: %s", err)
+	}
+	if err := aggregatorConfig.Validate(); err != nil {
+		return nil, err
+	}
+	networkConfig, err := extractNetwork(network)
+	if err != nil {
+		return nil, fmt.Errorf("wrong config: %s", err)
+	}
+	return &NodeConfig{Network: networkConfig, Aggregator: *aggregatorConfig}, nil
+}
+
+// Validate checks everything in the config that can be checked without the
+// chain, so a broken config fails at startup.
+func (c AggregatorConfig) Validate() error {
+	problems := &avsconfig.ValidationError{}
+	if c.ServerIpPortAddress == "" {
+		problems.Add("ServerIpPortAddress", "is required")
+	}
+	if c.AvsAddress == "" {
+		problems.Add("AvsAddress", "is required")
+	} else if err := (&aptos.AccountAddress{}).ParseStringRelaxed(c.AvsAddress); err != nil {
+		problems.Add("AvsAddress", "invalid address %q: %v", c.AvsAddress, err)
+	}
+	if c.AccountConfig.AccountPath == "" {
+		problems.Add("AccountConfig.AccountPath", "is required")
+	} else if err := avsconfig.CheckAptosProfile(c.AccountConfig.AccountPath, c.AccountConfig.Profile); err != nil {
+		problems.Add("AccountConfig", "%v", err)
+	}
+
+	switch TaskSourceMode(c.TaskSource) {
+	case TaskSourcePolling, TaskSourceEvents:
+	default:
+		problems.Add("TaskSource", "unknown task source %q, choose one of: %s, %s", c.TaskSource, TaskSourcePolling, TaskSourceEvents)
+	}
+	if err := ValidateResponsePolicy(c.ResponsePolicy); err != nil {
+		problems.Add("ResponsePolicy", "%v", err)
+	}
+	switch c.Auth {
+	case "", AuthChallenge:
+	default:
+		problems.Add("Auth", "unknown auth %q, choose %q or leave it empty", c.Auth, AuthChallenge)
+	}
+	if c.Tls != nil {
+		if _, err := tls.LoadX509KeyPair(c.Tls.CertFile, c.Tls.KeyFile); err != nil {
+			problems.Add("Tls", "can not load certificate: %v", err)
+		}
+		if _, err := c.Tls.serverConfig(); err != nil {
+			problems.Add("Tls.ClientCaFile", "%v", err)
+		}
+	}
+
+	if c.TxManager.MaxGasUnitPrice != 0 && c.TxManager.GasUnitPrice > c.TxManager.MaxGasUnitPrice {
+		problems.Add("TxManager.GasUnitPrice", "is above MaxGasUnitPrice %d", c.TxManager.MaxGasUnitPrice)
+	}
+	if c.TxManager.ExpirationSeconds < 0 {
+		problems.Add("TxManager.ExpirationSeconds", "can not be negative")
+	}
+	if c.TxManager.MaxAttempts < 0 {
+		problems.Add("TxManager.MaxAttempts", "can not be negative")
+	}
+	if c.RateLimit.PeerRequestsPerSecond < 0 || c.RateLimit.OperatorResponsesPerSecond < 0 {
+		problems.Add("RateLimit", "rates can not be negative")
+	}
+	if c.RateLimit.PeerBurst < 0 || c.RateLimit.OperatorBurst < 0 || c.RateLimit.MaxPendingResponses < 0 {
+		problems.Add("RateLimit", "bursts and MaxPendingResponses can not be negative")
+	}
+	return problems.Err()
+}
@@ -0,0 +1,169 @@
+package aggregator
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultPeerRequestsPerSecond      = 20
+	DefaultPeerBurst                  = 40
+	DefaultOperatorResponsesPerSecond = 5
+	DefaultOperatorBurst              = 10
+	DefaultMaxPendingResponses        = 64
+
+	// limiters idle for this long are forgotten
+	limiterIdleTimeout = 10 * time.Minute
+	maxLimiters        = 10000
+)
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded, retry later")
+	ErrBusy        = errors.New("aggregator is busy, retry later")
+)
+
+// RateLimitConfig bounds the work operators can put on the aggregator. Peers
+// are limited by IP address on every API call, operators by BLS pubkey on
+// submitted responses, and at most MaxPendingResponses responses are
+// processed at once, further ones are refused with ErrBusy.
+type RateLimitConfig struct {
+	PeerRequestsPerSecond      float64 `json:",omitempty"`
+	PeerBurst                  int     `json:",omitempty"`
+	OperatorResponsesPerSecond float64 `json:",omitempty"`
+	OperatorBurst              int     `json:",omitempty"`
+	MaxPendingResponses        int     `json:",omitempty"`
+}
+
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	if c.PeerRequestsPerSecond == 0 {
+		c.PeerRequestsPerSecond = DefaultPeerRequestsPerSecond
+	}
+	if c.PeerBurst == 0 {
+		c.PeerBurst = DefaultPeerBurst
+	}
+	if c.OperatorResponsesPerSecond == 0 {
+		c.OperatorResponsesPerSecond = DefaultOperatorResponsesPerSecond
+	}
+	if c.OperatorBurst == 0 {
+		c.OperatorBurst = DefaultOperatorBurst
+	}
+	if c.MaxPendingResponses == 0 {
+		c.MaxPendingResponses = DefaultMaxPendingResponses
+	}
+	return c
+}
+
+// Admission holds the rate limiters and the bounded work queue for
+// submitted responses.
+type Admission struct {
+	peers     *keyedLimiter
+	operators *keyedLimiter
+	pending   chan struct{}
+}
+
+func NewAdmission(config RateLimitConfig) *Admission {
+	config = config.withDefaults()
+	return &Admission{
+		peers:     newKeyedLimiter(config.PeerRequestsPerSecond, config.PeerBurst),
+		operators: newKeyedLimiter(config.OperatorResponsesPerSecond, config.OperatorBurst),
+		pending:   make(chan struct{}, config.MaxPendingResponses),
+	}
+}
+
+func (a *Admission) AllowPeer(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return a.peers.allow(host)
+}
+
+func (a *Admission) AllowOperator(pubkeyHex string) bool {
+	return a.operators.allow(pubkeyHex)
+}
+
+// Acquire takes a slot in the work queue without waiting, the returned
+// release must be called once the response is processed.
+func (a *Admission) Acquire() (release func(), ok bool) {
+	select {
+	case a.pending <- struct{}{}:
+		return func() { <-a.pending }, true
+	default:
+		return nil, false
+	}
+}
+
+// limitPeers wraps handler so that it is only called for peers within their
+// rate limit.
+func (a *Admission) limitPeers(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.AllowPeer(r) {
+			http.Error(w, ErrRateLimited.Error(), http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// tokenBucket refills rate tokens per second up to burst.
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type keyedLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newKeyedLimiter(rate float64, burst int) *keyedLimiter {
+	return &keyedLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *keyedLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxLimiters {
+			l.prune(now)
+		}
+		if len(l.buckets) >= maxLimiters {
+			return false
+		}
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.lastSeen = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func (l *keyedLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > limiterIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
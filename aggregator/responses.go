@@ -0,0 +1,112 @@
+package aggregator
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	ResponsePolicyFirstWins         = "first-wins"
+	ResponsePolicyLastWins          = "last-wins"
+	ResponsePolicyRejectConflicting = "reject-conflicting"
+)
+
+var (
+	ErrDuplicateResponse   = errors.New("duplicate task response")
+	ErrConflictingResponse = errors.New("conflicting task response")
+)
+
+func ValidateResponsePolicy(policy string) error {
+	switch policy {
+	case "", ResponsePolicyFirstWins, ResponsePolicyLastWins, ResponsePolicyRejectConflicting:
+		return nil
+	default:
+		return fmt.Errorf("unknown response policy %q, choose one of: %s, %s, %s", policy,
+			ResponsePolicyFirstWins, ResponsePolicyLastWins, ResponsePolicyRejectConflicting)
+	}
+}
+
+// addResponse records signedTaskResponse in taskInfo, keyed by operator
+// pubkey. A resent identical response returns ErrDuplicateResponse. When the
+// operator already signed a different response for the task the equivocation
+// is recorded and the response policy decides which one counts:
+//   - first-wins keeps the earlier response and returns ErrConflictingResponse
+//   - last-wins replaces the earlier response
+//   - reject-conflicting drops both and returns ErrConflictingResponse
+func (agg *Aggregator) addResponse(taskInfo *TaskInfo, signedTaskResponse SignedTaskResponse) error {
+	if agg.responsePolicy() == ResponsePolicyRejectConflicting {
+		for _, equivocation := range taskInfo.Equivocations {
+			if bytes.Equal(equivocation.Pubkey, signedTaskResponse.Pubkey) {
+				return errors.Wrapf(ErrConflictingResponse, "task %d, pubkey 0x%x already equivocated", signedTaskResponse.TaskId, signedTaskResponse.Pubkey)
+			}
+		}
+	}
+
+	index := -1
+	for i, response := range taskInfo.Responses {
+		if bytes.Equal(response.Pubkey, signedTaskResponse.Pubkey) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		taskInfo.Responses = append(taskInfo.Responses, signedTaskResponse)
+		return nil
+	}
+
+	previous := taskInfo.Responses[index]
+	if previous.Response.Cmp(signedTaskResponse.Response) == 0 {
+		return ErrDuplicateResponse
+	}
+
+	// a conflicting response sent again is only recorded once
+	if !hasEquivocation(taskInfo, signedTaskResponse) {
+		taskInfo.Equivocations = append(taskInfo.Equivocations, Equivocation{
+			Pubkey: signedTaskResponse.Pubkey,
+			First:  previous,
+			Second: signedTaskResponse,
+		})
+		agg.logger.Warn("Operator signed two different responses for the same task",
+			zap.Uint64("task id", signedTaskResponse.TaskId),
+			zap.String("pubkey", fmt.Sprintf("0x%x", signedTaskResponse.Pubkey)),
+			zap.String("first response", previous.Response.String()),
+			zap.String("second response", signedTaskResponse.Response.String()),
+			zap.String("policy", agg.responsePolicy()),
+		)
+	}
+
+	switch agg.responsePolicy() {
+	case ResponsePolicyLastWins:
+		taskInfo.Responses[index] = signedTaskResponse
+		return nil
+	case ResponsePolicyRejectConflicting:
+		taskInfo.Responses = append(taskInfo.Responses[:index], taskInfo.Responses[index+1:]...)
+		return errors.Wrapf(ErrConflictingResponse, "task %d, pubkey 0x%x, responses from this operator are dropped", signedTaskResponse.TaskId, signedTaskResponse.Pubkey)
+	default:
+		return errors.Wrapf(ErrConflictingResponse, "task %d, pubkey 0x%x, keeping the first response", signedTaskResponse.TaskId, signedTaskResponse.Pubkey)
+	}
+}
+
+// hasEquivocation reports whether an equivocation of the operator with the
+// response of signedTaskResponse is already recorded.
+func hasEquivocation(taskInfo *TaskInfo, signedTaskResponse SignedTaskResponse) bool {
+	for _, equivocation := range taskInfo.Equivocations {
+		if !bytes.Equal(equivocation.Pubkey, signedTaskResponse.Pubkey) {
+			continue
+		}
+		if equivocation.First.Response.Cmp(signedTaskResponse.Response) == 0 || equivocation.Second.Response.Cmp(signedTaskResponse.Response) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (agg *Aggregator) responsePolicy() string {
+	if agg.AggregatorConfig.ResponsePolicy == "" {
+		return ResponsePolicyFirstWins
+	}
+	return agg.AggregatorConfig.ResponsePolicy
+}
@@ -0,0 +1,41 @@
+package aggregator
+
+import (
+	"math/big"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAddResponseRecordsEquivocationOnce(t *testing.T) {
+	pubkey := []byte{1}
+	response := func(value int64) SignedTaskResponse {
+		return SignedTaskResponse{TaskId: 1, Pubkey: pubkey, Response: big.NewInt(value)}
+	}
+	for _, test := range []struct {
+		policy  string
+		counted []int64
+	}{
+		{ResponsePolicyFirstWins, []int64{1000}},
+		{ResponsePolicyLastWins, []int64{1001}},
+		{ResponsePolicyRejectConflicting, nil},
+	} {
+		agg := &Aggregator{logger: zap.NewNop(), AggregatorConfig: AggregatorConfig{ResponsePolicy: test.policy}}
+		taskInfo := TaskInfo{}
+		// an operator flipping between two responses and resending them
+		for _, value := range []int64{1000, 1001, 1001, 1000, 1001, 1001} {
+			agg.addResponse(&taskInfo, response(value))
+		}
+
+		if n := len(taskInfo.Equivocations); n != 1 {
+			t.Errorf("%s: %d equivocations recorded, want 1", test.policy, n)
+		}
+		var counted []int64
+		for _, response := range taskInfo.Responses {
+			counted = append(counted, response.Response.Int64())
+		}
+		if len(counted) != len(test.counted) || (len(counted) == 1 && counted[0] != test.counted[0]) {
+			t.Errorf("%s: counted responses %v, want %v", test.policy, counted, test.counted)
+		}
+	}
+}
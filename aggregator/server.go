@@ -1,36 +1,54 @@
 package aggregator
 
 import (
+	"avs/metrics"
+	"avs/msghash"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/rpc"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 const (
 	THRESHOLD_DENOMINATOR       uint64 = 100
 	QUORUM_THRESHOLD_PERCENTAGE uint64 = 67
+	// MaxRespondedTasks bounds the tasks remembered as responded on chain
+	MaxRespondedTasks = 10000
 )
 
 func (agg *Aggregator) ServeOperators() error {
-	// Registers a new RPC server
-	err := rpc.Register(agg)
+	mux, err := agg.operatorMux()
 	if err != nil {
 		return err
 	}
 
-	// Registers an HTTP handler for RPC messages
-	rpc.HandleHTTP()
+	server := &http.Server{
+		Addr:    agg.AggregatorConfig.ServerIpPortAddress,
+		Handler: mux,
+	}
+
+	if agg.AggregatorConfig.Tls != nil {
+		tlsConfig, err := agg.AggregatorConfig.Tls.serverConfig()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+		agg.logger.Info("Starting RPC server with TLS on address:", zap.String("address", agg.AggregatorConfig.ServerIpPortAddress), zap.Bool("client certificates", tlsConfig.ClientCAs != nil))
+		return server.ListenAndServeTLS(agg.AggregatorConfig.Tls.CertFile, agg.AggregatorConfig.Tls.KeyFile)
+	}
 
 	agg.logger.Info("Starting RPC server on address:", zap.String("address", agg.AggregatorConfig.ServerIpPortAddress))
 
-	err = http.ListenAndServe(agg.AggregatorConfig.ServerIpPortAddress, nil)
+	err = server.ListenAndServe()
 	if err != nil {
 		return err
 	}
@@ -38,131 +56,482 @@ func (agg *Aggregator) ServeOperators() error {
 	return nil
 }
 
-// Define the RespondTask method for handling incoming RPC calls
+// operatorMux serves the JSON-RPC API and, without challenge auth, the gob
+// API of older operators.
+func (agg *Aggregator) operatorMux() (*http.ServeMux, error) {
+	mux := http.NewServeMux()
+	mux.Handle(ApiPath, agg)
+
+	switch agg.AggregatorConfig.Auth {
+	case AuthNone:
+		// Registers a new RPC server for the gob API of older operators, it
+		// has no way to log in so it is only served without challenge auth
+		gobServer := rpc.NewServer()
+		err := gobServer.RegisterName("Aggregator", &GobApi{agg: agg})
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(rpc.DefaultRPCPath, agg.Admission.limitPeers(gobServer))
+	case AuthChallenge:
+		authenticator, err := NewAuthenticator(agg)
+		if err != nil {
+			return nil, err
+		}
+		agg.Authenticator = authenticator
+	default:
+		return nil, fmt.Errorf("unknown auth %q, choose %q or leave it empty", agg.AggregatorConfig.Auth, AuthChallenge)
+	}
+	return mux, nil
+}
+
+// GobApi only exposes the gob RPC methods of the aggregator, registering the
+// Aggregator itself would publish every method with a matching signature.
+type GobApi struct {
+	agg *Aggregator
+}
+
+func (g *GobApi) RespondTask(signedTaskResponse SignedTaskResponse, reply *uint8) error {
+	return g.agg.RespondTask(signedTaskResponse, reply)
+}
+
+func (g *GobApi) SubscribeTasks(subscription TaskSubscription, reply *TaskBatch) error {
+	return g.agg.SubscribeTasks(subscription, reply)
+}
+
+// RespondTask is the gob RPC operators used before the v1 API, kept for
+// compatibility. Responses that were already counted or arrived after quorum
+// are acknowledged like accepted ones.
 func (agg *Aggregator) RespondTask(signedTaskResponse SignedTaskResponse, reply *uint8) error {
+	status, err := agg.SubmitResponse(signedTaskResponse)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case ResponseAccepted, ResponseDuplicate, ResponseQuorumAlreadyReached:
+		// Set reply to indicate success (e.g., 0 = success)
+		*reply = 0
+		return nil
+	default:
+		return statusErrors[status]
+	}
+}
+
+// SubmitResponse checks and records a signed task response. Rejections are
+// reported in the status, the error is only set when the response could not
+// be processed and may be sent again.
+func (agg *Aggregator) SubmitResponse(signedTaskResponse SignedTaskResponse) (status ResponseStatus, err error) {
 	agg.logger.Info("Received signed task response", zap.Any("response", signedTaskResponse))
+	responsesReceived.Inc()
+	defer func() {
+		observeResponse(status, err)
+	}()
+
+	// Reject what can be rejected without a chain call
+	if err := checkResponseFormat(signedTaskResponse); err != nil {
+		agg.logger.Warn("Rejected signed task response", zap.Error(err))
+		return ResponseMalformed, nil
+	}
+	if err := agg.checkOperator(signedTaskResponse.Pubkey); err != nil {
+		agg.logger.Warn("Rejected signed task response", zap.Error(err))
+		if errors.Is(err, ErrOperatorSetNotReady) {
+			return "", err
+		}
+		return ResponseUnknownOperator, nil
+	}
+	stored, err := agg.isStoredTask(signedTaskResponse.TaskId)
+	if err != nil {
+		agg.logger.Warn("Can not tell whether task exists", zap.Uint64("task id", signedTaskResponse.TaskId), zap.Error(err))
+		return "", err
+	}
+	if !stored && agg.isRespondedTask(signedTaskResponse.TaskId) {
+		agg.logger.Info("Rejected response for unknown task", zap.Uint64("task id", signedTaskResponse.TaskId))
+		return ResponseUnknownTask, nil
+	}
 
-	// Process the signed task response
-	if err := agg.processTaskResponse(signedTaskResponse); err != nil {
-		agg.logger.Error("Failed to process signed task response", zap.Error(err))
-		return fmt.Errorf("failed to process task response: %v", err)
+	// Responses that are processed or need a chain call are charged to the
+	// operator
+	release, ok := agg.Admission.Acquire()
+	if !ok {
+		agg.logger.Warn("Too many pending responses, refusing signed task response")
+		return "", ErrBusy
+	}
+	defer release()
+	if !agg.Admission.AllowOperator(hex.EncodeToString(signedTaskResponse.Pubkey)) {
+		return "", errors.Wrapf(ErrRateLimited, "pubkey 0x%x", signedTaskResponse.Pubkey)
+	}
+	if !stored {
+		known, err := agg.isKnownTask(signedTaskResponse.TaskId)
+		if err != nil {
+			agg.logger.Warn("Can not tell whether task exists", zap.Uint64("task id", signedTaskResponse.TaskId), zap.Error(err))
+			return "", err
+		}
+		if !known {
+			agg.logger.Info("Rejected response for unknown task", zap.Uint64("task id", signedTaskResponse.TaskId))
+			return ResponseUnknownTask, nil
+		}
 	}
 
-	// Set reply to indicate success (e.g., 0 = success)
-	*reply = 0
-	agg.logger.Info("Successfully processed signed task response")
-	return nil
+	// Process the signed task response
+	err = agg.processTaskResponse(signedTaskResponse)
+	if status, ok := responseStatus(err); ok {
+		if status != ResponseAccepted {
+			agg.logger.Info("Signed task response not counted", zap.String("status", string(status)), zap.Error(err))
+		} else {
+			agg.logger.Info("Successfully processed signed task response")
+		}
+		return status, nil
+	}
+	agg.logger.Error("Failed to process signed task response", zap.Error(err))
+	return "", fmt.Errorf("failed to process task response: %v", err)
 }
 
-func (agg *Aggregator) processTaskResponse(signedTaskResponse SignedTaskResponse) error {
-	fmt.Println("test")
-	client, err := aptos.NewClient(agg.Network)
+// isKnownTask reports whether the task was fetched by the aggregator. A task
+// the task source has not returned yet is loaded from the chain when its id is
+// within the on-chain task count, so that a response is not refused because
+// the operator saw the task first. It is queued like a fetched one, or
+// remembered as responded when it already was, so each task is loaded at most
+// once.
+func (agg *Aggregator) isKnownTask(taskId uint64) (bool, error) {
+	unlock := agg.TaskLocks.Lock(taskId)
+	defer unlock()
+
+	known, err := agg.isStoredTask(taskId)
+	if known || err != nil {
+		return known, err
+	}
+	if agg.isRespondedTask(taskId) {
+		return false, nil
+	}
+	taskCount, err := agg.chainTaskCount(taskId)
+	if err != nil {
+		return false, err
+	}
+	if taskId == 0 || taskId > taskCount {
+		return false, nil
+	}
+	avs := aptos.AccountAddress{}
+	if err := avs.ParseStringRelaxed(agg.AvsAddress); err != nil {
+		return false, fmt.Errorf("error parsing avs address: %v", err)
+	}
+	task, err := LoadTaskById(agg.Client, avs, taskId)
 	if err != nil {
-		return fmt.Errorf("failed to create aptos client: %v", err)
+		return false, fmt.Errorf("error loading task: %v", err)
+	}
+	if responded, _ := task["responded"].(bool); responded {
+		agg.setRespondedTask(taskId)
+		return false, nil
+	}
+	agg.logger.Info("Loaded task of a response before the task source returned it", zap.Uint64("task id", taskId))
+	if err := agg.queueLockedTask(Task{Id: taskId, Task: task}); err != nil {
+		return false, fmt.Errorf("can not queue task %d: %v", taskId, err)
+	}
+	return true, nil
+}
+
+// isRespondedTask reports whether isKnownTask found the task responded on
+// chain.
+func (agg *Aggregator) isRespondedTask(taskId uint64) bool {
+	agg.respondedTasks.mu.Lock()
+	defer agg.respondedTasks.mu.Unlock()
+	_, responded := agg.respondedTasks.ids[taskId]
+	return responded
+}
+
+// setRespondedTask remembers a task responded on chain, dropping an arbitrary
+// one when MaxRespondedTasks are remembered.
+func (agg *Aggregator) setRespondedTask(taskId uint64) {
+	agg.respondedTasks.mu.Lock()
+	defer agg.respondedTasks.mu.Unlock()
+	if agg.respondedTasks.ids == nil {
+		agg.respondedTasks.ids = make(map[uint64]struct{})
+	}
+	if len(agg.respondedTasks.ids) >= MaxRespondedTasks {
+		for id := range agg.respondedTasks.ids {
+			delete(agg.respondedTasks.ids, id)
+			break
+		}
 	}
+	agg.respondedTasks.ids[taskId] = struct{}{}
+}
 
-	var timestamp uint64
+func (agg *Aggregator) isStoredTask(taskId uint64) (bool, error) {
 	agg.TaskMutex.Lock()
-	taskInfo, exists := agg.PendingTasks[signedTaskResponse.TaskId]
+	_, exists := agg.PendingTasks[taskId]
+	agg.TaskMutex.Unlock()
 	if exists {
-		var timestampStr = taskInfo.State["task_created_timestamp"].(string)
-		timestamp, err = strconv.ParseUint(timestampStr, 10, 64) // base 10, 64-bit size
-		if err != nil {
-			return fmt.Errorf("error converting string to uint64: %v", err)
-		}
-	} else {
-		avs := aptos.AccountAddress{}
-		err := avs.ParseStringRelaxed(agg.AvsAddress)
-		if err != nil {
-			return fmt.Errorf("error parsing avs address while loading task: %v", err)
-		}
-		task, err := LoadTaskById(client, avs, signedTaskResponse.TaskId)
+		return true, nil
+	}
+	_, found, err := agg.TaskStore.LoadTask(taskId)
+	if err != nil {
+		return false, fmt.Errorf("error loading task: %v", err)
+	}
+	return found, nil
+}
+
+// chainTaskCount returns the last on-chain task count read, read again when
+// taskId is above it and it is older than PollLatestBatchInterval.
+func (agg *Aggregator) chainTaskCount(taskId uint64) (uint64, error) {
+	agg.taskCount.mu.Lock()
+	defer agg.taskCount.mu.Unlock()
+	if taskId <= agg.taskCount.count || time.Since(agg.taskCount.readAt) < PollLatestBatchInterval {
+		return agg.taskCount.count, nil
+	}
+	avs := aptos.AccountAddress{}
+	if err := avs.ParseStringRelaxed(agg.AvsAddress); err != nil {
+		return 0, fmt.Errorf("error parsing avs address: %v", err)
+	}
+	taskCount, err := LatestTaskCount(agg.Client, avs)
+	if err != nil {
+		return 0, err
+	}
+	agg.taskCount.count = taskCount
+	agg.taskCount.readAt = time.Now()
+	return taskCount, nil
+}
+
+func (agg *Aggregator) processTaskResponse(signedTaskResponse SignedTaskResponse) error {
+	taskId := signedTaskResponse.TaskId
+	unlock := agg.TaskLocks.Lock(taskId)
+	defer unlock()
+
+	taskInfo, exists := agg.pendingTask(taskId)
+	if !exists {
+		storedInfo, found, err := agg.TaskStore.LoadTask(taskId)
 		if err != nil {
 			return fmt.Errorf("error loading task: %v", err)
 		}
-		var timestampStr = task["task_created_timestamp"].(string)
-		timestamp, err = strconv.ParseUint(timestampStr, 10, 64) // base 10, 64-bit size
-		if err != nil {
-			return fmt.Errorf("error converting string to uint64: %v", err)
-		}
-		taskInfo = TaskInfo{
-			State:     task,
-			Responses: make([]SignedTaskResponse, 0),
+		if !found {
+			// known tasks are always stored, see isKnownTask
+			return errors.Wrapf(ErrUnknownTask, "task %d", taskId)
 		}
-		agg.PendingTasks[signedTaskResponse.TaskId] = taskInfo
+		taskInfo = storedInfo
+	}
+	// Verify the new signature on its own so that a bad one does not make
+	// check_signatures fail for the whole task
+	responseHashes, err := agg.msgHashes(agg.Client, taskId, taskInfo, []SignedTaskResponse{signedTaskResponse})
+	if err != nil {
+		return err
+	}
+	err = VerifyResponseSignature(signedTaskResponse, responseHashes[0])
+	if err != nil {
+		return err
 	}
 
-	taskInfo.Responses = append(taskInfo.Responses, signedTaskResponse)
-	resps := []U128Struct{}
-	pks := []BytesStruct{}
-	sigs := []BytesStruct{}
-	msgs := []BytesStruct{}
-	for _, response := range taskInfo.Responses {
-		pks = append(pks, BytesStruct{
-			Value: response.Pubkey,
-		})
-		sigs = append(sigs, BytesStruct{
-			Value: response.Signature,
-		})
-		resps = append(resps, U128Struct{
-			Value: response.Response,
-		})
+	if taskInfo.CurrentStatus() == TaskCollecting && agg.isExpired(taskInfo, time.Now()) {
+		agg.expireTask(taskId, &taskInfo)
+	}
+	if !taskInfo.CurrentStatus().AcceptsResponses() {
+		// the task is being submitted or done, keep the response but do not
+		// resubmit
+		addLateResponse(&taskInfo, signedTaskResponse)
+		agg.logger.Info("Recorded late response", zap.Uint64("task id", taskId), zap.String("status", string(taskInfo.CurrentStatus())))
+		agg.saveTask(taskId, taskInfo)
+		if taskInfo.CurrentStatus() == TaskExpired {
+			return errors.Wrapf(ErrTaskExpired, "task %d", taskId)
+		}
+		return errors.Wrapf(ErrQuorumAlreadyReached, "task %d is %s", taskId, taskInfo.CurrentStatus())
 	}
 
-	// GetMsgHashes
-	msgHashes, err := GetMsgHashes(client, agg.AvsAddress, signedTaskResponse.TaskId,
-		resps,
-		pks,
-	)
+	err = agg.addResponse(&taskInfo, signedTaskResponse)
+	if errors.Is(err, ErrDuplicateResponse) {
+		// an operator retrying a response that was already counted
+		return err
+	}
+	if errors.Is(err, ErrConflictingResponse) {
+		// keep the equivocation on record, the stake is recounted with the
+		// next response
+		agg.saveTask(taskId, taskInfo)
+		return err
+	}
+	signedStake, totalStake, err := agg.checkStake(taskId, taskInfo)
 	if err != nil {
-		return fmt.Errorf("failed to get msg hashes: %v", err)
+		return err
 	}
-	for _, hash := range msgHashes {
-		hexStr, ok := hash.(string)
-		if !ok {
-			return fmt.Errorf("data is not a string")
+
+	taskInfo.SignedStake = signedStake
+	taskInfo.TotalStake = totalStake
+	switch {
+	case !quorumReached(signedStake, totalStake):
+		agg.logger.Info("Quorum for task has not reached. Waiting for other operators", zap.Any("task_id", taskId), zap.Any("Consensus", float64(signedStake*THRESHOLD_DENOMINATOR)/float64(totalStake)))
+	case taskInfo.CurrentStatus() == TaskCollecting:
+		if err := agg.setTaskStatus(taskId, &taskInfo, TaskQuorumReached); err != nil {
+			return err
 		}
-		trimmedHexStr := strings.TrimPrefix(hexStr, "0x")
-		bytesMsgHash, err := hex.DecodeString(trimmedHexStr)
-		if err != nil {
-			return fmt.Errorf("can't decode string: %v", err)
+		taskInfo.QuorumReachedAt = time.Now().Unix()
+		window := agg.collectionWindow()
+		if window == 0 || signedStake == totalStake {
+			agg.logger.Info("Quorum for task has reached. Responding...", zap.Any("task_id", taskId))
+			agg.submitTask(taskId, &taskInfo)
+		} else {
+			agg.logger.Info("Quorum for task has reached. Collecting more responses before responding", zap.Any("task_id", taskId), zap.Duration("window", window))
+			agg.scheduleSubmission(taskId, window)
 		}
+	case signedStake == totalStake:
+		agg.logger.Info("All stake signed the task. Responding...", zap.Any("task_id", taskId))
+		agg.submitTask(taskId, &taskInfo)
+	}
+
+	agg.saveTask(taskId, taskInfo)
+	return nil
+}
+
+// checkStake asks check_signatures for the stake that signed the responses of
+// a task.
+func (agg *Aggregator) checkStake(taskId uint64, taskInfo TaskInfo) (uint64, uint64, error) {
+	timestampStr, _ := taskInfo.State["task_created_timestamp"].(string)
+	timestamp, err := strconv.ParseUint(timestampStr, 10, 64) // base 10, 64-bit size
+	if err != nil {
+		return 0, 0, fmt.Errorf("error converting string to uint64: %v", err)
+	}
+
+	_, pks, sigs := respondArgs(taskInfo.Responses)
+	msgs := []BytesStruct{}
+
+	msgHashes, err := agg.msgHashes(agg.Client, taskId, taskInfo, taskInfo.Responses)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, bytesMsgHash := range msgHashes {
 		msgs = append(msgs, BytesStruct{
 			Value: bytesMsgHash,
 		})
 	}
 
-	signedStake, totalStake, err := CheckSignatures(client, agg.AvsAddress, 1, timestamp,
+	signedStake, totalStake, err := CheckSignatures(agg.Client, agg.AvsAddress, 1, timestamp,
 		msgs,
 		pks,
 		sigs,
 	)
 	if err != nil {
-		return fmt.Errorf("can't check signature: %v", err)
+		return 0, 0, fmt.Errorf("can't check signature: %v", err)
 	}
+	return signedStake, totalStake, nil
+}
 
-	fmt.Println("signedStake: ", signedStake)
-	fmt.Println("totalStake: ", totalStake)
-	// (signed_stake * THRESHOLD_DENOMINATOR) >= (total_stake * QUORUM_THRESHOLD_PERCENTAGE)
-	if signedStake*THRESHOLD_DENOMINATOR >= totalStake*QUORUM_THRESHOLD_PERCENTAGE {
-		agg.logger.Info("Quorum for task has reached. Responding...", zap.Any("task_id", signedTaskResponse.TaskId))
+// (signed_stake * THRESHOLD_DENOMINATOR) >= (total_stake * QUORUM_THRESHOLD_PERCENTAGE)
+func quorumReached(signedStake, totalStake uint64) bool {
+	return signedStake*THRESHOLD_DENOMINATOR >= totalStake*QUORUM_THRESHOLD_PERCENTAGE
+}
 
-		err = RespondToAvs(client, &agg.AggregatorAccount, agg.AvsAddress, signedTaskResponse.TaskId,
-			sigs,
-			pks,
-			resps,
-		)
+// submitTask sends respond_to_task with every response collected for a task
+// in quorum-reached. Callers hold the task lock and save the task afterwards.
+func (agg *Aggregator) submitTask(taskId uint64, taskInfo *TaskInfo) {
+	if err := agg.setTaskStatus(taskId, taskInfo, TaskSubmitting); err != nil {
+		agg.logger.Error("Can not submit task", zap.Error(err))
+		return
+	}
+	// a crash while submitting leaves the task in submitting, never collecting
+	agg.saveTask(taskId, *taskInfo)
 
+	resps, pks, sigs := respondArgs(taskInfo.Responses)
+	txHash, err := RespondToAvs(agg.TxManager, agg.AvsAddress, taskId,
+		sigs,
+		pks,
+		resps,
+	)
+	if txHash != "" {
+		taskInfo.TxHashes = append(taskInfo.TxHashes, txHash)
+	}
+	switch {
+	case err == nil:
+		agg.setTaskStatus(taskId, taskInfo, TaskConfirmed)
+	case errors.Is(err, ErrTaskAlreadyResponded):
+		agg.logger.Info("Task was already responded on chain", zap.Uint64("task id", taskId))
+		agg.setTaskStatus(taskId, taskInfo, TaskConfirmed)
+	default:
+		agg.logger.Error("Failed to respond task", zap.Uint64("task id", taskId), zap.Error(err))
+		agg.setTaskStatus(taskId, taskInfo, TaskFailed)
+	}
+}
+
+// scheduleSubmission submits a task in quorum-reached once its collection
+// window is over, unless it was submitted in the meantime. The stake is
+// checked again first, operators may have left since quorum was reached: a
+// task below quorum goes back to collecting.
+func (agg *Aggregator) scheduleSubmission(taskId uint64, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		unlock := agg.TaskLocks.Lock(taskId)
+		defer unlock()
+		taskInfo, exists := agg.pendingTask(taskId)
+		if !exists || taskInfo.CurrentStatus() != TaskQuorumReached {
+			return
+		}
+
+		signedStake, totalStake, err := agg.checkStake(taskId, taskInfo)
 		if err != nil {
-			return fmt.Errorf("failed to respond task: %v", err)
+			agg.logger.Warn("Can not check stake before responding, retrying", zap.Uint64("task id", taskId), zap.Error(err))
+			agg.scheduleSubmission(taskId, PollLatestBatchInterval)
+			return
 		}
-	} else {
-		agg.logger.Info("Quorum for task has not reached. Waiting for other operators", zap.Any("task_id", signedTaskResponse.TaskId), zap.Any("Consensus", float64(signedStake*THRESHOLD_DENOMINATOR)/float64(totalStake)))
+		taskInfo.SignedStake = signedStake
+		taskInfo.TotalStake = totalStake
+		if !quorumReached(signedStake, totalStake) {
+			agg.logger.Warn("Quorum for task is lost. Waiting for other operators", zap.Uint64("task id", taskId), zap.Uint64("signed stake", signedStake), zap.Uint64("total stake", totalStake))
+			agg.setTaskStatus(taskId, &taskInfo, TaskCollecting)
+			agg.saveTask(taskId, taskInfo)
+			return
+		}
+
+		agg.logger.Info("Collection window for task is over. Responding...", zap.Uint64("task id", taskId), zap.Int("responses", len(taskInfo.Responses)))
+		agg.submitTask(taskId, &taskInfo)
+		agg.saveTask(taskId, taskInfo)
+	})
+}
+
+// resumeSubmissions reschedules the tasks that were waiting for their
+// collection window to end when the aggregator stopped.
+func (agg *Aggregator) resumeSubmissions() {
+	for _, taskId := range agg.pendingTaskIds() {
+		taskInfo, exists := agg.pendingTask(taskId)
+		if !exists || taskInfo.CurrentStatus() != TaskQuorumReached {
+			continue
+		}
+		deadline := time.Unix(taskInfo.QuorumReachedAt, 0).Add(agg.collectionWindow())
+		agg.scheduleSubmission(taskId, time.Until(deadline))
 	}
+}
 
-	agg.PendingTasks[signedTaskResponse.TaskId] = taskInfo
-	agg.TaskMutex.Unlock()
-	return nil
+func (agg *Aggregator) collectionWindow() time.Duration {
+	return time.Duration(agg.AggregatorConfig.CollectionWindowSeconds) * time.Second
+}
+
+func respondArgs(responses []SignedTaskResponse) ([]U128Struct, []BytesStruct, []BytesStruct) {
+	resps := []U128Struct{}
+	pks := []BytesStruct{}
+	sigs := []BytesStruct{}
+	for _, response := range responses {
+		pks = append(pks, BytesStruct{
+			Value: response.Pubkey,
+		})
+		sigs = append(sigs, BytesStruct{
+			Value: response.Signature,
+		})
+		resps = append(resps, U128Struct{
+			Value: response.Response,
+		})
+	}
+	return resps, pks, sigs
+}
+
+// saveTask updates the task in PendingTasks and the task store, tasks that
+// reached a terminal status only stay in the store. Callers hold the task
+// lock.
+func (agg *Aggregator) saveTask(taskId uint64, taskInfo TaskInfo) {
+	agg.setPendingTask(taskId, taskInfo)
+	err := agg.TaskStore.SaveTask(taskId, taskInfo)
+	if err != nil {
+		agg.logger.Error("Failed to persist task", zap.Uint64("task id", taskId), zap.Any("err", err))
+	}
+}
+
+func (agg *Aggregator) setPendingTask(taskId uint64, taskInfo TaskInfo) {
+	agg.TaskMutex.Lock()
+	defer agg.TaskMutex.Unlock()
+	if taskInfo.CurrentStatus().Terminal() {
+		delete(agg.PendingTasks, taskId)
+	} else {
+		agg.PendingTasks[taskId] = taskInfo.clone()
+	}
 }
 
 // aggregator: &signer,
@@ -172,22 +541,21 @@ func (agg *Aggregator) processTaskResponse(signedTaskResponse SignedTaskResponse
 // signer_sigs: vector<vector<u8>>,
 
 func RespondToAvs(
-	client *aptos.Client,
-	aggregatorAccount *aptos.Account,
+	txManager *TxManager,
 	contractAddr string,
 	taskId uint64,
 	signature []BytesStruct,
 	pubkey []BytesStruct,
 	responses []U128Struct,
-) error {
+) (string, error) {
 	contract := aptos.AccountAddress{}
 	err := contract.ParseStringRelaxed(contractAddr)
 	if err != nil {
-		panic("Failed to parse address:" + err.Error())
+		return "", fmt.Errorf("failed to parse address: %v", err)
 	}
 	taskIdBcs, err := bcs.SerializeU64(taskId)
 	if err != nil {
-		panic("Failed to bcs serialize task id:" + err.Error())
+		return "", fmt.Errorf("failed to bcs serialize task id: %v", err)
 	}
 
 	sigSerializer := bcs.Serializer{}
@@ -210,39 +578,7 @@ func RespondToAvs(
 		},
 	}
 
-	// Build transaction
-	rawTxn, err := client.BuildTransaction(aggregatorAccount.AccountAddress(),
-		aptos.TransactionPayload{Payload: &payload})
-	if err != nil {
-		panic("Failed to build transaction:" + err.Error())
-	}
-
-	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(aggregatorAccount)
-	if err != nil {
-		panic("Failed to sign transaction:" + err.Error())
-	}
-	fmt.Printf("Submit register operator for %s\n", aggregatorAccount.AccountAddress())
-
-	// Submit and wait for it to complete
-	submitResult, err := client.SubmitTransaction(signedTxn)
-	if err != nil {
-		panic("Failed to submit transaction:" + err.Error())
-	}
-	txnHash := submitResult.Hash
-
-	// Wait for the transaction
-	fmt.Printf("And we wait for the transaction %s to complete...\n", txnHash)
-	userTxn, err := client.WaitForTransaction(txnHash)
-	if err != nil {
-		panic("Failed to wait for transaction:" + err.Error())
-	}
-	fmt.Printf("The transaction completed with hash: %s and version %d\n", userTxn.Hash, userTxn.Version)
-	if !userTxn.Success {
-		// TODO: log something more
-		panic("Failed to respond to avs")
-	}
-	return nil
+	return txManager.Submit(aptos.TransactionPayload{Payload: &payload})
 }
 
 // quorum_numbers: vector<u8>,
@@ -262,7 +598,7 @@ func CheckSignatures(
 	contract := aptos.AccountAddress{}
 	err := contract.ParseStringRelaxed(contractAddr)
 	if err != nil {
-		panic("Failed to parse address:" + err.Error())
+		return 0, 0, fmt.Errorf("failed to parse address: %v", err)
 	}
 
 	quorumSerializer := &bcs.Serializer{}
@@ -274,7 +610,7 @@ func CheckSignatures(
 
 	timestampBcs, err := bcs.SerializeU64(referenceTimestamp)
 	if err != nil {
-		panic("Failed to SerializeU64:" + err.Error())
+		return 0, 0, fmt.Errorf("failed to SerializeU64: %v", err)
 	}
 
 	sigSerializer := bcs.Serializer{}
@@ -302,7 +638,7 @@ func CheckSignatures(
 		},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -329,12 +665,12 @@ func GetMsgHashes(
 	contract := aptos.AccountAddress{}
 	err := contract.ParseStringRelaxed(contractAddr)
 	if err != nil {
-		panic("Failed to parse address:" + err.Error())
+		return nil, fmt.Errorf("failed to parse address: %v", err)
 	}
 
 	taskIdBcs, err := bcs.SerializeU64(taskId)
 	if err != nil {
-		panic("Failed to bcs serialize task id:" + err.Error())
+		return nil, fmt.Errorf("failed to bcs serialize task id: %v", err)
 	}
 
 	pubkeySerializer := bcs.Serializer{}
@@ -357,7 +693,7 @@ func GetMsgHashes(
 		},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -365,3 +701,56 @@ func GetMsgHashes(
 	msgHashes := vals[0].([]interface{})
 	return msgHashes, nil
 }
+
+// msgHashes computes the message hash of every response locally when the
+// task creator is known, and asks the get_msg_hashes view otherwise.
+func (agg *Aggregator) msgHashes(client *aptos.Client, taskId uint64, taskInfo TaskInfo, responses []SignedTaskResponse) ([][]byte, error) {
+	if creator, ok := msghash.TaskCreator(taskInfo.State); ok {
+		values := make([]*big.Int, 0, len(responses))
+		for _, response := range responses {
+			values = append(values, response.Response)
+		}
+		msgHashes, err := msghash.MsgHashes(taskId, creator, values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute msg hashes: %v", err)
+		}
+		return msgHashes, nil
+	}
+
+	resps := []U128Struct{}
+	pks := []BytesStruct{}
+	for _, response := range responses {
+		resps = append(resps, U128Struct{
+			Value: response.Response,
+		})
+		pks = append(pks, BytesStruct{
+			Value: response.Pubkey,
+		})
+	}
+	hashes, err := GetMsgHashes(client, agg.AvsAddress, taskId, resps, pks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get msg hashes: %v", err)
+	}
+	msgHashes := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		bytesMsgHash, err := decodeMsgHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		msgHashes = append(msgHashes, bytesMsgHash)
+	}
+	return msgHashes, nil
+}
+
+func decodeMsgHash(hash interface{}) ([]byte, error) {
+	hexStr, ok := hash.(string)
+	if !ok {
+		return nil, fmt.Errorf("data is not a string")
+	}
+	trimmedHexStr := strings.TrimPrefix(hexStr, "0x")
+	bytesMsgHash, err := hex.DecodeString(trimmedHexStr)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode string: %v", err)
+	}
+	return bytesMsgHash, nil
+}
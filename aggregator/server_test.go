@@ -0,0 +1,155 @@
+package aggregator
+
+import (
+	"avs/msghash"
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+)
+
+func TestSubmitResponseAdmission(t *testing.T) {
+	node := newFakeNode(t)
+	node.taskCount.Store(3)
+	agg := newTestAggregator(t, node)
+	agg.TaskFeed = NewTaskFeed()
+	// three responses per operator until the bucket refills
+	agg.Admission = NewAdmission(RateLimitConfig{OperatorResponsesPerSecond: 0.001, OperatorBurst: 3})
+
+	key, err := crypto.GenerateBlsPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg.OperatorSet = map[string]aptos.AccountAddress{hex.EncodeToString(key.Inner.PublicKey().Marshal()): {}}
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed("0xc0ffee"); err != nil {
+		t.Fatal(err)
+	}
+	sign := func(taskId uint64) SignedTaskResponse {
+		msgHash, err := msghash.MsgHash(taskId, creator, big.NewInt(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := key.Sign(msgHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SignedTaskResponse{
+			TaskId:    taskId,
+			Pubkey:    key.Inner.PublicKey().Marshal(),
+			Signature: signature.Signature().Bytes(),
+			Response:  big.NewInt(1000),
+		}
+	}
+
+	// malformed responses are refused before they are charged
+	malformed := sign(4)
+	malformed.Signature = nil
+	for i := 0; i < 3; i++ {
+		if status, err := agg.SubmitResponse(malformed); err != nil || status != ResponseMalformed {
+			t.Errorf("malformed response: %s %v, want %s", status, err, ResponseMalformed)
+		}
+	}
+
+	// a response for a task not on chain costs a token and the task count
+	if status, err := agg.SubmitResponse(sign(4)); err != nil || status != ResponseUnknownTask {
+		t.Errorf("response for a task not on chain: %s %v, want %s", status, err, ResponseUnknownTask)
+	}
+	if n := node.countReads.Load(); n != 1 {
+		t.Errorf("task count read %d times, want once", n)
+	}
+
+	// a task on chain the task source did not return yet is loaded once
+	if status, err := agg.SubmitResponse(sign(2)); err != nil || status != ResponseAccepted {
+		t.Fatalf("response for a task not fetched yet: %s %v, want %s", status, err, ResponseAccepted)
+	}
+	if found, err := agg.isKnownTask(2); err != nil || !found {
+		t.Errorf("task 2 not known after its response: %v", err)
+	}
+	if n := node.taskLoads.Load(); n != 1 {
+		t.Errorf("task loaded %d times, want once", n)
+	}
+
+	// a task already responded on chain is loaded once and then refused
+	// without a chain call or a token
+	node.responded.Store(true)
+	for i := 0; i < 3; i++ {
+		if status, err := agg.SubmitResponse(sign(3)); err != nil || status != ResponseUnknownTask {
+			t.Errorf("response for a responded task: %s %v, want %s", status, err, ResponseUnknownTask)
+		}
+	}
+	if n := node.taskLoads.Load(); n != 2 {
+		t.Errorf("tasks loaded %d times, want twice", n)
+	}
+
+	// the three chain lookups used the tokens
+	if _, err := agg.SubmitResponse(sign(2)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("fourth charged response: %v, want rate limited", err)
+	}
+}
+
+func TestScheduledSubmissionChecksStakeAgain(t *testing.T) {
+	node := newFakeNode(t)
+	agg := newTestAggregator(t, node)
+	agg.AggregatorConfig.CollectionWindowSeconds = 60
+
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed("0xc0ffee"); err != nil {
+		t.Fatal(err)
+	}
+	agg.PendingTasks[1] = TaskInfo{State: map[string]interface{}{
+		"task_created_timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+		"creator":                creator.String(),
+	}}
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateBlsPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgHash, err := msghash.MsgHash(1, creator, big.NewInt(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := key.Sign(msgHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = agg.processTaskResponse(SignedTaskResponse{
+			TaskId:    1,
+			Pubkey:    key.Inner.PublicKey().Marshal(),
+			Signature: signature.Signature().Bytes(),
+			Response:  big.NewInt(1000),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if taskInfo, _ := agg.pendingTask(1); taskInfo.CurrentStatus() != TaskQuorumReached {
+		t.Fatalf("task is %s, want %s", taskInfo.CurrentStatus(), TaskQuorumReached)
+	}
+
+	// an operator left during the collection window
+	node.stakeLeft.Store(25)
+	agg.scheduleSubmission(1, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		unlock := agg.TaskLocks.Lock(1)
+		taskInfo, _ := agg.pendingTask(1)
+		unlock()
+		if taskInfo.CurrentStatus() == TaskCollecting {
+			if taskInfo.SignedStake != 50 {
+				t.Errorf("signed stake %d, want 50", taskInfo.SignedStake)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task is %s, want %s", taskInfo.CurrentStatus(), TaskCollecting)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,156 @@
+package aggregator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const DefaultDbPath = "data/aggregator.db"
+
+var (
+	tasksBucket = []byte("tasks")
+	metaBucket  = []byte("meta")
+
+	checkpointKeyPrefix = "checkpoint/"
+)
+
+// TaskStore persists the aggregator's tasks, the responses collected for
+// them and the task source checkpoint, so a restarted aggregator resumes
+// where it stopped.
+type TaskStore struct {
+	db *bolt.DB
+}
+
+func OpenTaskStore(path string) (*TaskStore, error) {
+	if path == "" {
+		path = DefaultDbPath
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("can not create directory for %s: %v", path, err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can not open task store at %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{tasksBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can not create buckets: %v", err)
+	}
+
+	return &TaskStore{db: db}, nil
+}
+
+func (s *TaskStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *TaskStore) SaveTask(taskId uint64, taskInfo TaskInfo) error {
+	bz, err := json.Marshal(taskInfo)
+	if err != nil {
+		return fmt.Errorf("can not marshal task %d: %v", taskId, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).Put(u64Key(taskId), bz)
+	})
+}
+
+func (s *TaskStore) LoadTask(taskId uint64) (TaskInfo, bool, error) {
+	var taskInfo TaskInfo
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bz := tx.Bucket(tasksBucket).Get(u64Key(taskId))
+		if bz == nil {
+			return nil
+		}
+		found = true
+		var err error
+		taskInfo, err = unmarshalTaskInfo(bz)
+		return err
+	})
+	if err != nil {
+		return TaskInfo{}, false, fmt.Errorf("can not load task %d: %v", taskId, err)
+	}
+	return taskInfo, found, nil
+}
+
+// unmarshalTaskInfo decodes a stored task. Tasks stored before Status existed
+// only have Responded, a responded task is confirmed.
+func unmarshalTaskInfo(bz []byte) (TaskInfo, error) {
+	var stored struct {
+		TaskInfo
+		Responded bool
+	}
+	if err := json.Unmarshal(bz, &stored); err != nil {
+		return TaskInfo{}, err
+	}
+	if stored.Status == "" && stored.Responded {
+		stored.Status = TaskConfirmed
+	}
+	return stored.TaskInfo, nil
+}
+
+// LoadTasks returns every stored task for which keep returns true.
+func (s *TaskStore) LoadTasks(keep func(TaskInfo) bool) (map[uint64]TaskInfo, error) {
+	tasks := make(map[uint64]TaskInfo)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			taskInfo, err := unmarshalTaskInfo(v)
+			if err != nil {
+				return fmt.Errorf("can not unmarshal task %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			if keep(taskInfo) {
+				tasks[binary.BigEndian.Uint64(k)] = taskInfo
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Checkpoint returns the last checkpoint saved for a task source mode.
+func (s *TaskStore) Checkpoint(mode string) (uint64, bool, error) {
+	var checkpoint uint64
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bz := tx.Bucket(metaBucket).Get([]byte(checkpointKeyPrefix + mode))
+		if bz == nil {
+			return nil
+		}
+		found = true
+		checkpoint = binary.BigEndian.Uint64(bz)
+		return nil
+	})
+	return checkpoint, found, err
+}
+
+func (s *TaskStore) SaveCheckpoint(mode string, checkpoint uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put([]byte(checkpointKeyPrefix+mode), u64Key(checkpoint))
+	})
+}
+
+func u64Key(value uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, value)
+	return key
+}
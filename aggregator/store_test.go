@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestLoadLegacyRespondedTask(t *testing.T) {
+	store, err := OpenTaskStore(filepath.Join(t.TempDir(), "aggregator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// tasks as stored before the task status existed
+	legacy := map[uint64]string{
+		1: `{"State":{"task_created_timestamp":"1"},"Responses":[],"SignedStake":80,"TotalStake":100,"TxHashes":["0xabc"],"Responded":true}`,
+		2: `{"State":{"task_created_timestamp":"2"},"Responses":[],"SignedStake":0,"TotalStake":0,"TxHashes":null,"Responded":false}`,
+	}
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		for taskId, bz := range legacy {
+			if err := tx.Bucket(tasksBucket).Put(u64Key(taskId), []byte(bz)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for taskId, want := range map[uint64]TaskStatus{1: TaskConfirmed, 2: TaskCollecting} {
+		taskInfo, found, err := store.LoadTask(taskId)
+		if err != nil || !found {
+			t.Fatalf("task %d not loaded: %v", taskId, err)
+		}
+		if status := taskInfo.CurrentStatus(); status != want {
+			t.Errorf("task %d is %s, want %s", taskId, status, want)
+		}
+	}
+
+	pending, err := store.LoadTasks(func(taskInfo TaskInfo) bool {
+		return !taskInfo.CurrentStatus().Terminal()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pending[1]; ok || len(pending) != 1 {
+		t.Errorf("pending tasks %v, want only task 2", pending)
+	}
+}
+
+func TestQueueTasksReportsUnstoredTask(t *testing.T) {
+	agg := newTestAggregator(t, newFakeNode(t))
+	agg.TaskFeed = NewTaskFeed()
+	task := func(taskId uint64) Task {
+		return Task{Id: taskId, Task: map[string]interface{}{"task_created_timestamp": "99999999999"}}
+	}
+
+	if err := agg.QueueTasks([]Task{task(1)}); err != nil {
+		t.Fatal(err)
+	}
+	agg.TaskStore.Close()
+	if err := agg.QueueTasks([]Task{task(2), task(3)}); err == nil {
+		t.Fatal("QueueTasks should fail when a task can not be stored")
+	}
+	if published, _ := agg.TaskFeed.Since(0, 0); len(published) != 1 || published[0].Id != 1 {
+		t.Errorf("published %v, want only task 1", published)
+	}
+
+	agg.TaskMutex.Lock()
+	defer agg.TaskMutex.Unlock()
+	if _, ok := agg.PendingTasks[1]; !ok {
+		t.Error("stored task 1 is not pending")
+	}
+	for _, taskId := range []uint64{2, 3} {
+		if _, ok := agg.PendingTasks[taskId]; ok {
+			t.Errorf("task %d is pending without being stored", taskId)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTaskTimeoutSeconds uint64 = 600
+	SweepInterval                    = 10 * time.Second
+)
+
+// taskDeadline is task_created_timestamp plus the configured task timeout.
+func (agg *Aggregator) taskDeadline(taskInfo TaskInfo) (time.Time, error) {
+	timestampStr, ok := taskInfo.State["task_created_timestamp"].(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("task has no task_created_timestamp")
+	}
+	timestamp, err := strconv.ParseUint(timestampStr, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error converting string to uint64: %v", err)
+	}
+	timeout := agg.AggregatorConfig.TaskTimeoutSeconds
+	if timeout == 0 {
+		timeout = DefaultTaskTimeoutSeconds
+	}
+	return time.Unix(int64(timestamp+timeout), 0), nil
+}
+
+func (agg *Aggregator) isExpired(taskInfo TaskInfo, now time.Time) bool {
+	deadline, err := agg.taskDeadline(taskInfo)
+	if err != nil {
+		return false
+	}
+	return now.After(deadline)
+}
+
+// SweepTasks periodically expires the tasks that are still collecting
+// responses past their deadline and settles the ones a previous run left in
+// submitting.
+func (agg *Aggregator) SweepTasks(ctx context.Context) error {
+	ticker := time.NewTicker(SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			agg.sweep(time.Now())
+		}
+	}
+}
+
+func (agg *Aggregator) sweep(now time.Time) {
+	for _, taskId := range agg.pendingTaskIds() {
+		// a busy task is looked at again with the next sweep
+		unlock, ok := agg.TaskLocks.TryLock(taskId)
+		if !ok {
+			continue
+		}
+		agg.sweepTask(taskId, now)
+		unlock()
+	}
+}
+
+func (agg *Aggregator) sweepTask(taskId uint64, now time.Time) {
+	taskInfo, exists := agg.pendingTask(taskId)
+	if !exists || !agg.isExpired(taskInfo, now) {
+		return
+	}
+	switch taskInfo.CurrentStatus() {
+	case TaskCollecting:
+		agg.expireTask(taskId, &taskInfo)
+		agg.saveTask(taskId, taskInfo)
+	case TaskSubmitting:
+		// only a restart leaves a task in submitting outside submitTask,
+		// ask the chain how the submission ended
+		agg.settleSubmission(taskId, &taskInfo)
+		agg.saveTask(taskId, taskInfo)
+	}
+}
+
+// expireTask moves a collecting task to expired, keeping the stake that had
+// signed it. Callers hold the task lock and save the task afterwards.
+func (agg *Aggregator) expireTask(taskId uint64, taskInfo *TaskInfo) {
+	if err := agg.setTaskStatus(taskId, taskInfo, TaskExpired); err != nil {
+		agg.logger.Error("Can not expire task", zap.Error(err))
+		return
+	}
+	agg.logger.Warn("Task expired before reaching quorum",
+		zap.Uint64("task id", taskId),
+		zap.Int("responses", len(taskInfo.Responses)),
+		zap.Uint64("signed stake", taskInfo.SignedStake),
+		zap.Uint64("total stake", taskInfo.TotalStake),
+	)
+}
+
+func (agg *Aggregator) settleSubmission(taskId uint64, taskInfo *TaskInfo) {
+	avs := aptos.AccountAddress{}
+	err := avs.ParseStringRelaxed(agg.AvsAddress)
+	if err != nil {
+		agg.logger.Error("Error parsing avs address", zap.Error(err))
+		return
+	}
+	task, err := LoadTaskById(agg.Client, avs, taskId)
+	if err != nil {
+		agg.logger.Warn("Can not check submitted task", zap.Uint64("task id", taskId), zap.Error(err))
+		return
+	}
+	if responded, _ := task["responded"].(bool); responded {
+		agg.setTaskStatus(taskId, taskInfo, TaskConfirmed)
+	} else {
+		agg.setTaskStatus(taskId, taskInfo, TaskFailed)
+	}
+}
@@ -0,0 +1,245 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+const (
+	TaskSourcePolling = "polling"
+	TaskSourceEvents  = "events"
+
+	TaskEventsPageSize = 100
+	IndexerTimeout     = 30 * time.Second
+)
+
+// TaskSource yields the tasks created on the service manager since the
+// previous call, in the order they were created. Checkpoint is the position
+// the next call starts from and can be handed back to Resume after a restart.
+type TaskSource interface {
+	NewTasks(ctx context.Context) ([]Task, error)
+	Checkpoint() uint64
+	Resume(checkpoint uint64)
+}
+
+// NewTaskSource creates the task source for mode. indexerUrl and startVersion
+// are only used by the events source: startVersion is the first ledger version
+// queried, 0 meaning the ledger version at the time of the first call.
+func NewTaskSource(mode string, client *aptos.Client, indexerUrl string, avs aptos.AccountAddress, startVersion uint64) (TaskSource, error) {
+	switch TaskSourceMode(mode) {
+	case TaskSourcePolling:
+		return NewPollingTaskSource(client, avs), nil
+	case TaskSourceEvents:
+		return NewEventTaskSource(client, indexerUrl, avs, startVersion)
+	default:
+		return nil, fmt.Errorf("unknown task source %q, choose one of: %s, %s", mode, TaskSourcePolling, TaskSourceEvents)
+	}
+}
+
+// TaskSourceMode resolves the empty mode to the default polling source.
+func TaskSourceMode(mode string) string {
+	if mode == "" {
+		return TaskSourcePolling
+	}
+	return mode
+}
+
+// PollingTaskSource compares service_manager::task_count with the last count
+// it saw and loads every new task with LoadTaskById.
+type PollingTaskSource struct {
+	client    *aptos.Client
+	avs       aptos.AccountAddress
+	taskCount uint64
+}
+
+func NewPollingTaskSource(client *aptos.Client, avs aptos.AccountAddress) *PollingTaskSource {
+	return &PollingTaskSource{
+		client: client,
+		avs:    avs,
+	}
+}
+
+// Checkpoint is the number of tasks already returned.
+func (s *PollingTaskSource) Checkpoint() uint64 {
+	return s.taskCount
+}
+
+func (s *PollingTaskSource) Resume(checkpoint uint64) {
+	s.taskCount = checkpoint
+}
+
+func (s *PollingTaskSource) NewTasks(ctx context.Context) ([]Task, error) {
+	taskCount, err := LatestTaskCount(s.client, s.avs)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	for i := s.taskCount + 1; i <= taskCount; i++ {
+		task, err := LoadTaskById(s.client, s.avs, i)
+		if err != nil {
+			return nil, fmt.Errorf("error loading task: %v", err)
+		}
+		tasks = append(tasks, Task{
+			Id:   i,
+			Task: task,
+		})
+	}
+	s.taskCount = taskCount
+	return tasks, nil
+}
+
+// EventTaskSource queries the indexer events table for the
+// service_manager::TaskCreated events emitted by create_new_task, by event
+// type, so only the transactions that created a task are read.
+type EventTaskSource struct {
+	indexer     *aptos.IndexerClient
+	client      *aptos.Client
+	avs         aptos.AccountAddress
+	nextVersion uint64
+}
+
+func NewEventTaskSource(client *aptos.Client, indexerUrl string, avs aptos.AccountAddress, startVersion uint64) (*EventTaskSource, error) {
+	if indexerUrl == "" {
+		return nil, fmt.Errorf("the %s task source needs an indexer, set Network.IndexerUrl", TaskSourceEvents)
+	}
+	return &EventTaskSource{
+		indexer:     aptos.NewIndexerClient(&http.Client{Timeout: IndexerTimeout}, indexerUrl),
+		client:      client,
+		avs:         avs,
+		nextVersion: startVersion,
+	}, nil
+}
+
+// Checkpoint is the first ledger version the next call will query.
+func (s *EventTaskSource) Checkpoint() uint64 {
+	return s.nextVersion
+}
+
+func (s *EventTaskSource) Resume(checkpoint uint64) {
+	s.nextVersion = checkpoint
+}
+
+func (s *EventTaskSource) NewTasks(ctx context.Context) ([]Task, error) {
+	if s.nextVersion == 0 {
+		info, err := s.client.Info()
+		if err != nil {
+			return nil, fmt.Errorf("can not get ledger info: %v", err)
+		}
+		s.nextVersion = info.LedgerVersion()
+	}
+
+	// from is the version of the last event read and offset the number of
+	// events of that version already read, a transaction may emit more events
+	// than fit in a page
+	var tasks []Task
+	from, offset := s.nextVersion, 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return tasks, err
+		}
+
+		events, err := s.taskCreatedEvents(from, offset)
+		if err != nil {
+			return tasks, fmt.Errorf("can not get TaskCreated events from version %d: %v", from, err)
+		}
+
+		for _, event := range events {
+			task, err := taskFromEvent(event.Data)
+			if err != nil {
+				return tasks, fmt.Errorf("can not decode TaskCreated event at version %d: %v", event.TransactionVersion, err)
+			}
+			tasks = append(tasks, task)
+			if event.TransactionVersion == from {
+				offset++
+			} else {
+				from, offset = event.TransactionVersion, 1
+			}
+		}
+
+		if len(events) < TaskEventsPageSize {
+			if offset > 0 {
+				from++
+			}
+			s.nextVersion = from
+			return tasks, nil
+		}
+		// the events of versions before from are all read
+		s.nextVersion = from
+	}
+}
+
+type taskCreatedEvent struct {
+	TransactionVersion uint64                 `graphql:"transaction_version"`
+	Data               map[string]interface{} `graphql:"data" scalar:"true"`
+}
+
+// indexerBigint is a variable of the indexer bigint type
+type indexerBigint uint64
+
+func (indexerBigint) GetGraphQLType() string {
+	return "bigint"
+}
+
+func (s *EventTaskSource) taskCreatedEvents(from uint64, offset int) ([]taskCreatedEvent, error) {
+	var q struct {
+		Events []taskCreatedEvent `graphql:"events(where: {indexed_type: {_in: $types}, transaction_version: {_gte: $from}}, order_by: [{transaction_version: asc}, {event_index: asc}], offset: $offset, limit: $limit)"`
+	}
+	variables := map[string]any{
+		"types":  s.eventTypes(),
+		"from":   indexerBigint(from),
+		"offset": offset,
+		"limit":  TaskEventsPageSize,
+	}
+	if err := s.indexer.Query(&q, variables); err != nil {
+		return nil, err
+	}
+	return q.Events, nil
+}
+
+// eventTypes lists the TaskCreated type with each way the indexer may
+// write the module address.
+func (s *EventTaskSource) eventTypes() []string {
+	types := []string{}
+	seen := map[string]bool{}
+	for _, addr := range []string{s.avs.String(), s.avs.StringLong(), "0x" + strings.TrimLeft(s.avs.StringLong()[2:], "0")} {
+		eventType := addr + "::service_manager::TaskCreated"
+		if !seen[eventType] {
+			seen[eventType] = true
+			types = append(types, eventType)
+		}
+	}
+	return types
+}
+
+// taskFromEvent builds the same task map task_by_id returns, plus the task
+// creator, so that consumers do not need a view call per task.
+func taskFromEvent(data map[string]interface{}) (Task, error) {
+	taskIdStr, ok := data["task_id"].(string)
+	if !ok {
+		return Task{}, fmt.Errorf("missing task_id")
+	}
+	taskId, err := strconv.ParseUint(taskIdStr, 10, 64)
+	if err != nil {
+		return Task{}, fmt.Errorf("error parsing task id: %v", err)
+	}
+
+	return Task{
+		Id: taskId,
+		Task: map[string]interface{}{
+			"task_created_timestamp": data["timestamp"],
+			"responded":              false,
+			"response":               "0",
+			"data_request":           data["data_request"],
+			"respond_fee_token":      data["respond_fee_token"],
+			"respond_fee_limit":      data["respond_fee_limit"],
+			"creator":                data["creator"],
+		},
+	}, nil
+}
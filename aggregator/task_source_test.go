@@ -0,0 +1,123 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+type indexedEvent struct {
+	version uint64
+	taskId  uint64
+}
+
+// newFakeIndexer serves the events query of EventTaskSource from events,
+// which are sorted by version.
+func newFakeIndexer(t *testing.T, events []indexedEvent) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string
+			Variables struct {
+				Types  []string
+				From   uint64
+				Offset int
+				Limit  int
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(request.Query, "indexed_type: {_in: $types}") || !strings.Contains(request.Query, "$from:bigint!") {
+			t.Errorf("unexpected query %s", request.Query)
+		}
+		found := false
+		for _, eventType := range request.Variables.Types {
+			found = found || eventType == "0x1::service_manager::TaskCreated"
+		}
+		if !found {
+			t.Errorf("query does not ask for the TaskCreated type: %v", request.Variables.Types)
+		}
+
+		page := []map[string]interface{}{}
+		skipped := 0
+		for _, event := range events {
+			if event.version < request.Variables.From {
+				continue
+			}
+			if skipped < request.Variables.Offset {
+				skipped++
+				continue
+			}
+			if len(page) == request.Variables.Limit {
+				break
+			}
+			page = append(page, map[string]interface{}{
+				"transaction_version": event.version,
+				"data": map[string]interface{}{
+					"task_id":   strconv.FormatUint(event.taskId, 10),
+					"creator":   "0xc0ffee",
+					"timestamp": "1",
+				},
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"events": page}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEventTaskSourcePages(t *testing.T) {
+	// a transaction creating more tasks than fit in a page, and pages ending
+	// in the middle of a transaction
+	var events []indexedEvent
+	taskId := uint64(1)
+	for _, txn := range []struct{ version, tasks uint64 }{{10, 1}, {11, 99}, {12, 2}, {20, 150}, {21, 1}, {35, 48}} {
+		for i := uint64(0); i < txn.tasks; i++ {
+			events = append(events, indexedEvent{txn.version, taskId})
+			taskId++
+		}
+	}
+	indexer := newFakeIndexer(t, events)
+
+	source, err := NewEventTaskSource(nil, indexer.URL, aptos.AccountOne, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := source.NewTasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != len(events) {
+		t.Fatalf("got %d tasks, want %d", len(tasks), len(events))
+	}
+	for i, task := range tasks {
+		if task.Id != uint64(i+1) {
+			t.Fatalf("task %d has id %d, want %d", i, task.Id, i+1)
+		}
+	}
+	if checkpoint := source.Checkpoint(); checkpoint != 36 {
+		t.Errorf("checkpoint %d, want 36", checkpoint)
+	}
+
+	tasks, err = source.NewTasks(context.Background())
+	if err != nil || len(tasks) != 0 {
+		t.Errorf("second call returned %d tasks, %v, want none", len(tasks), err)
+	}
+	if checkpoint := source.Checkpoint(); checkpoint != 36 {
+		t.Errorf("checkpoint moved to %d without new events", checkpoint)
+	}
+}
+
+func TestEventTaskSourceNeedsIndexer(t *testing.T) {
+	if _, err := NewTaskSource(TaskSourceEvents, nil, "", aptos.AccountOne, 0); err == nil {
+		t.Error("the events source should require an indexer url")
+	}
+}
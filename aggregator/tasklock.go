@@ -0,0 +1,125 @@
+package aggregator
+
+import "sync"
+
+// TaskLocks serializes the work on a single task, so that a slow chain call
+// for one task never holds up the responses of other tasks. TaskMutex only
+// guards the PendingTasks map and is never held across I/O.
+type TaskLocks struct {
+	mu    sync.Mutex
+	locks map[uint64]*taskLock
+}
+
+type taskLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewTaskLocks() *TaskLocks {
+	return &TaskLocks{locks: make(map[uint64]*taskLock)}
+}
+
+// Lock waits for the task to be free and returns the function releasing it.
+func (l *TaskLocks) Lock(taskId uint64) func() {
+	lock := l.acquire(taskId)
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.release(taskId)
+	}
+}
+
+// TryLock is Lock for callers that would rather come back later than wait,
+// ok is false when the task is busy.
+func (l *TaskLocks) TryLock(taskId uint64) (unlock func(), ok bool) {
+	lock := l.acquire(taskId)
+	if !lock.Mutex.TryLock() {
+		l.release(taskId)
+		return nil, false
+	}
+	return func() {
+		lock.Unlock()
+		l.release(taskId)
+	}, true
+}
+
+func (l *TaskLocks) acquire(taskId uint64) *taskLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock, ok := l.locks[taskId]
+	if !ok {
+		lock = &taskLock{}
+		l.locks[taskId] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// release forgets the lock of a task nobody holds or waits for
+func (l *TaskLocks) release(taskId uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock := l.locks[taskId]
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, taskId)
+	}
+}
+
+// pendingTask returns a copy of a task in PendingTasks that the caller holding
+// the task lock may change and save.
+func (agg *Aggregator) pendingTask(taskId uint64) (TaskInfo, bool) {
+	agg.TaskMutex.Lock()
+	defer agg.TaskMutex.Unlock()
+	taskInfo, exists := agg.PendingTasks[taskId]
+	if !exists {
+		return TaskInfo{}, false
+	}
+	return taskInfo.clone(), true
+}
+
+// pendingTaskIds lists the tasks in PendingTasks, for loops that lock every
+// task on its own.
+func (agg *Aggregator) pendingTaskIds() []uint64 {
+	agg.TaskMutex.Lock()
+	defer agg.TaskMutex.Unlock()
+	taskIds := make([]uint64, 0, len(agg.PendingTasks))
+	for taskId := range agg.PendingTasks {
+		taskIds = append(taskIds, taskId)
+	}
+	return taskIds
+}
+
+// clone copies the slices of the task, which are appended to and changed in
+// place while a response is processed, and its State. The responses
+// themselves are shared, they are never changed once received.
+func (t TaskInfo) clone() TaskInfo {
+	if t.State != nil {
+		t.State = cloneValue(t.State).(map[string]interface{})
+	}
+	t.Responses = append([]SignedTaskResponse(nil), t.Responses...)
+	t.TxHashes = append([]string(nil), t.TxHashes...)
+	t.LateResponses = append([]SignedTaskResponse(nil), t.LateResponses...)
+	t.Equivocations = append([]Equivocation(nil), t.Equivocations...)
+	return t
+}
+
+// cloneValue deep copies a value decoded from JSON.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = cloneValue(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = cloneValue(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,337 @@
+package aggregator
+
+import (
+	"avs/msghash"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"go.uber.org/zap"
+)
+
+func TestTaskLocksConcurrent(t *testing.T) {
+	locks := NewTaskLocks()
+	const tasks = 16
+	var inUse [tasks]atomic.Int32
+	var counters [tasks]int
+
+	var wg sync.WaitGroup
+	for g := 0; g < 64; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 500; i++ {
+				taskId := uint64(rng.Intn(tasks))
+				var unlock func()
+				if rng.Intn(2) == 0 {
+					unlock = locks.Lock(taskId)
+				} else {
+					var ok bool
+					if unlock, ok = locks.TryLock(taskId); !ok {
+						continue
+					}
+				}
+				if n := inUse[taskId].Add(1); n != 1 {
+					t.Errorf("task %d held %d times", taskId, n)
+				}
+				// not atomic, the race detector catches a lock that does not
+				// exclude
+				counters[taskId]++
+				inUse[taskId].Add(-1)
+				unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := lockCount(locks); n != 0 {
+		t.Errorf("%d locks left after every holder released", n)
+	}
+}
+
+func TestProcessResponsesWithSweepAndSubmission(t *testing.T) {
+	const (
+		tasks     = 8
+		operators = 4
+	)
+	node := newFakeNode(t)
+	agg := newTestAggregator(t, node)
+	agg.AggregatorConfig.CollectionWindowSeconds = 1
+
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed("0xc0ffee"); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]*crypto.BlsPrivateKey, operators)
+	for i := range keys {
+		key, err := crypto.GenerateBlsPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+
+	now := time.Now()
+	for taskId := uint64(1); taskId <= tasks; taskId++ {
+		created := now
+		if taskId%4 == 0 {
+			// past its deadline, expired by whichever of the sweep and a
+			// response gets the lock first
+			created = now.Add(-time.Hour)
+		}
+		agg.PendingTasks[taskId] = TaskInfo{State: map[string]interface{}{
+			"task_created_timestamp": strconv.FormatInt(created.Unix(), 10),
+			"creator":                creator.String(),
+		}}
+	}
+
+	sign := func(taskId uint64, key *crypto.BlsPrivateKey, response int64) SignedTaskResponse {
+		msgHash, err := msghash.MsgHash(taskId, creator, big.NewInt(response))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := key.Sign(msgHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SignedTaskResponse{
+			TaskId:    taskId,
+			Pubkey:    key.Inner.PublicKey().Marshal(),
+			Signature: signature.Signature().Bytes(),
+			Response:  big.NewInt(response),
+		}
+	}
+
+	var wg sync.WaitGroup
+	for taskId := uint64(1); taskId <= tasks; taskId++ {
+		for i, key := range keys {
+			wg.Add(1)
+			go func(taskId uint64, i int, key *crypto.BlsPrivateKey) {
+				defer wg.Done()
+				for attempt := 0; attempt < 3; attempt++ {
+					// resends, and a conflicting response from one operator
+					response := int64(1000)
+					if i == 0 && attempt == 2 {
+						response = 1001
+					}
+					agg.processTaskResponse(sign(taskId, key, response))
+				}
+			}(taskId, i, key)
+		}
+		wg.Add(1)
+		go func(taskId uint64) {
+			defer wg.Done()
+			agg.scheduleSubmission(taskId, time.Duration(taskId)*time.Millisecond)
+		}(taskId)
+	}
+	stop := make(chan struct{})
+	sweeping := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		sweeping.Add(1)
+		go func() {
+			defer sweeping.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					agg.sweep(time.Now())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// the collection window of the last task to reach quorum ends a second
+	// after it did
+	time.Sleep(1500 * time.Millisecond)
+	close(stop)
+	sweeping.Wait()
+
+	if n := lockCount(agg.TaskLocks); n != 0 {
+		t.Errorf("%d task locks left", n)
+	}
+	for taskId := uint64(1); taskId <= tasks; taskId++ {
+		taskInfo, found, err := agg.TaskStore.LoadTask(taskId)
+		if err != nil || !found {
+			t.Fatalf("task %d not stored: %v", taskId, err)
+		}
+		if !taskInfo.CurrentStatus().Terminal() {
+			t.Errorf("task %d is %s, want a terminal status", taskId, taskInfo.CurrentStatus())
+		}
+		seen := map[string]bool{}
+		for _, response := range taskInfo.Responses {
+			if seen[string(response.Pubkey)] {
+				t.Errorf("task %d counts pubkey 0x%x twice", taskId, response.Pubkey)
+			}
+			seen[string(response.Pubkey)] = true
+		}
+	}
+	agg.TaskMutex.Lock()
+	pending := len(agg.PendingTasks)
+	agg.TaskMutex.Unlock()
+	if pending != 0 {
+		t.Errorf("%d tasks still pending", pending)
+	}
+}
+
+func TestTaskInfoCloneCopiesState(t *testing.T) {
+	taskInfo := TaskInfo{State: map[string]interface{}{
+		"task_created_timestamp": "1",
+		"data_request":           map[string]interface{}{"symbols": []interface{}{"ETH"}},
+	}}
+	clone := taskInfo.clone()
+	clone.State["task_created_timestamp"] = "2"
+	clone.State["data_request"].(map[string]interface{})["symbols"].([]interface{})[0] = "BTC"
+
+	if taskInfo.State["task_created_timestamp"] != "1" {
+		t.Error("changing the clone changed the task state")
+	}
+	if symbol := taskInfo.State["data_request"].(map[string]interface{})["symbols"].([]interface{})[0]; symbol != "ETH" {
+		t.Errorf("changing the clone changed a nested value to %v", symbol)
+	}
+}
+
+func lockCount(locks *TaskLocks) int {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	return len(locks.locks)
+}
+
+func newTestAggregator(t *testing.T, node *fakeNode) *Aggregator {
+	t.Helper()
+	client, err := aptos.NewClient(aptos.NetworkConfig{Name: "test", NodeUrl: node.URL + "/v1", ChainId: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := aptos.NewEd25519Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenTaskStore(filepath.Join(t.TempDir(), "aggregator.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	logger := zap.NewNop()
+	return &Aggregator{
+		logger:       logger,
+		AvsAddress:   "0x1",
+		PendingTasks: make(map[uint64]TaskInfo),
+		TaskLocks:    NewTaskLocks(),
+		Client:       client,
+		TaskStore:    store,
+		TxManager:    NewTxManager(logger, client, account, TxManagerConfig{GasUnitPrice: 100, MaxAttempts: 1}),
+	}
+}
+
+// fakeNode answers the view functions the aggregator calls while processing
+// responses. check_signatures reports 25 of 110 stake per signer, so quorum is
+// reached with the third response and the task waits for its collection
+// window, never having all the stake. There are taskCount tasks, all created
+// now by 0xc0ffee, which get_msg_hashes computes the message hashes with as
+// task_by_id does not return it. Transactions are refused, a submitted task
+// ends up failed.
+type fakeNode struct {
+	*httptest.Server
+	taskCount  atomic.Uint64
+	taskLoads  atomic.Int64
+	countReads atomic.Int64
+	// stakeLeft is subtracted from the stake of the signers
+	stakeLeft atomic.Uint64
+	// responded is returned as the responded field of every task
+	responded atomic.Bool
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	t.Helper()
+	node := &fakeNode{}
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed("0xc0ffee"); err != nil {
+		t.Fatal(err)
+	}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/view" {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		function, args, err := decodeView(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch function {
+		case "check_signatures":
+			signers := bcs.NewDeserializer(args[3]).Uleb128()
+			signed := strconv.FormatUint(25*uint64(signers)-node.stakeLeft.Load(), 10)
+			json.NewEncoder(w).Encode([]interface{}{[]string{signed}, []string{"110"}})
+		case "task_count":
+			node.countReads.Add(1)
+			json.NewEncoder(w).Encode([]string{strconv.FormatUint(node.taskCount.Load(), 10)})
+		case "task_by_id":
+			node.taskLoads.Add(1)
+			json.NewEncoder(w).Encode([]interface{}{map[string]interface{}{
+				"task_created_timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+				"responded":              node.responded.Load(),
+				"response":               "0",
+				"data_request":           "ETH",
+			}})
+		case "get_msg_hashes":
+			taskId := bcs.NewDeserializer(args[0]).U64()
+			des := bcs.NewDeserializer(args[1])
+			responses := make([]*big.Int, des.Uleb128())
+			for i := range responses {
+				response := des.U128()
+				responses[i] = &response
+			}
+			hashes, err := msghash.MsgHashes(taskId, creator, responses)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			encoded := make([]string, len(hashes))
+			for i, hash := range hashes {
+				encoded[i] = "0x" + hex.EncodeToString(hash)
+			}
+			json.NewEncoder(w).Encode([]interface{}{encoded})
+		default:
+			http.Error(w, fmt.Sprintf(`{"message":"unexpected view %s"}`, function), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func decodeView(body []byte) (string, [][]byte, error) {
+	des := bcs.NewDeserializer(body)
+	des.ReadFixedBytes(32)
+	des.ReadString()
+	function := des.ReadString()
+	if des.Uleb128() != 0 {
+		return "", nil, fmt.Errorf("type arguments are not supported")
+	}
+	args := make([][]byte, des.Uleb128())
+	for i := range args {
+		args[i] = des.ReadBytes()
+	}
+	return function, args, des.Error()
+}
@@ -0,0 +1,233 @@
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/api"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTxExpirationSeconds int64  = 60
+	DefaultTxMaxAttempts              = 3
+	DefaultTxGasBumpPercent    uint64 = 20
+)
+
+// TxManagerConfig sets the gas of the aggregator's transactions. A zero
+// GasUnitPrice uses the node's gas estimate and a zero MaxGasAmount the
+// simulated gas use with some margin.
+type TxManagerConfig struct {
+	GasUnitPrice      uint64 `json:",omitempty"`
+	MaxGasUnitPrice   uint64 `json:",omitempty"`
+	MaxGasAmount      uint64 `json:",omitempty"`
+	ExpirationSeconds int64  `json:",omitempty"`
+	MaxAttempts       int    `json:",omitempty"`
+	GasBumpPercent    uint64 `json:",omitempty"`
+}
+
+// TxManager sends the aggregator account's transactions one at a time. It
+// keeps the account sequence number locally, simulates every transaction
+// before submitting it and resubmits with a higher gas price when a
+// transaction expires or is rejected by the mempool.
+type TxManager struct {
+	logger  *zap.Logger
+	client  *aptos.Client
+	account *aptos.Account
+	config  TxManagerConfig
+
+	mu             sync.Mutex
+	sequenceNumber uint64
+	synced         bool
+}
+
+func NewTxManager(logger *zap.Logger, client *aptos.Client, account *aptos.Account, config TxManagerConfig) *TxManager {
+	if config.ExpirationSeconds == 0 {
+		config.ExpirationSeconds = DefaultTxExpirationSeconds
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = DefaultTxMaxAttempts
+	}
+	if config.GasBumpPercent == 0 {
+		config.GasBumpPercent = DefaultTxGasBumpPercent
+	}
+	return &TxManager{
+		logger:  logger,
+		client:  client,
+		account: account,
+		config:  config,
+	}
+}
+
+// Submit sends payload and waits for it to be committed. It returns the hash
+// of the last transaction sent, and a *MoveAbortError when the transaction
+// aborts, either in simulation or on chain.
+func (tm *TxManager) Submit(payload aptos.TransactionPayload) (string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	gasUnitPrice, err := tm.gasUnitPrice()
+	if err != nil {
+		return "", err
+	}
+
+	var txnHash string
+	var lastErr error
+	for attempt := 1; attempt <= tm.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			gasUnitPrice = tm.bump(gasUnitPrice)
+		}
+		sequenceNumber, err := tm.nextSequenceNumber()
+		if err != nil {
+			return txnHash, err
+		}
+
+		maxGasAmount, err := tm.simulate(payload, sequenceNumber, gasUnitPrice)
+		if err != nil {
+			if isSequenceNumberError(err) {
+				tm.synced = false
+				lastErr = err
+				continue
+			}
+			return txnHash, err
+		}
+
+		rawTxn, err := tm.client.BuildTransaction(tm.account.AccountAddress(), payload,
+			aptos.SequenceNumber(sequenceNumber),
+			aptos.GasUnitPrice(gasUnitPrice),
+			aptos.MaxGasAmount(maxGasAmount),
+			aptos.ExpirationSeconds(tm.config.ExpirationSeconds),
+		)
+		if err != nil {
+			return txnHash, fmt.Errorf("failed to build transaction: %v", err)
+		}
+		signedTxn, err := rawTxn.SignedTransaction(tm.account)
+		if err != nil {
+			return txnHash, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+
+		submitResult, err := tm.client.SubmitTransaction(signedTxn)
+		if err != nil {
+			// rejected by the mempool, the sequence number was not used
+			tm.logger.Warn("Transaction rejected, retrying", zap.Int("attempt", attempt), zap.Uint64("gas unit price", gasUnitPrice), zap.Error(err))
+			if isSequenceNumberError(err) {
+				tm.synced = false
+			}
+			lastErr = fmt.Errorf("failed to submit transaction: %v", err)
+			continue
+		}
+		txnHash = submitResult.Hash
+
+		userTxn, err := tm.client.WaitForTransaction(txnHash, aptos.PollTimeout(time.Duration(tm.config.ExpirationSeconds)*time.Second+5*time.Second))
+		if err != nil {
+			// not committed before it expired, the same sequence number is
+			// used again with more gas
+			tm.logger.Warn("Transaction not committed before expiring, retrying", zap.String("hash", txnHash), zap.Int("attempt", attempt), zap.Error(err))
+			lastErr = fmt.Errorf("failed to wait for transaction %s: %v", txnHash, err)
+			continue
+		}
+
+		// a committed transaction uses its sequence number, aborted or not
+		tm.sequenceNumber = sequenceNumber + 1
+		tm.logger.Info("Transaction committed", zap.String("hash", userTxn.Hash), zap.Uint64("version", userTxn.Version), zap.Uint64("gas used", userTxn.GasUsed), zap.Bool("success", userTxn.Success))
+		transactionGasUsed.WithLabelValues(payloadFunction(payload)).Observe(float64(userTxn.GasUsed))
+		if !userTxn.Success {
+			return userTxn.Hash, vmStatusError(userTxn.VmStatus)
+		}
+		return userTxn.Hash, nil
+	}
+
+	// the last transaction may still land, read the sequence number again
+	tm.synced = false
+	return txnHash, fmt.Errorf("transaction not committed after %d attempts: %v", tm.config.MaxAttempts, lastErr)
+}
+
+func (tm *TxManager) nextSequenceNumber() (uint64, error) {
+	if tm.synced {
+		return tm.sequenceNumber, nil
+	}
+	info, err := tm.client.Account(tm.account.AccountAddress())
+	if err != nil {
+		return 0, fmt.Errorf("can not get account: %v", err)
+	}
+	sequenceNumber, err := info.SequenceNumber()
+	if err != nil {
+		return 0, fmt.Errorf("can not get sequence number: %v", err)
+	}
+	tm.sequenceNumber = sequenceNumber
+	tm.synced = true
+	return sequenceNumber, nil
+}
+
+func (tm *TxManager) gasUnitPrice() (uint64, error) {
+	if tm.config.GasUnitPrice != 0 {
+		return tm.config.GasUnitPrice, nil
+	}
+	estimate, err := tm.client.EstimateGasPrice()
+	if err != nil {
+		return 0, fmt.Errorf("can not estimate gas price: %v", err)
+	}
+	return tm.cap(estimate.GasEstimate), nil
+}
+
+func (tm *TxManager) bump(gasUnitPrice uint64) uint64 {
+	return tm.cap(gasUnitPrice + gasUnitPrice*tm.config.GasBumpPercent/100 + 1)
+}
+
+func (tm *TxManager) cap(gasUnitPrice uint64) uint64 {
+	if tm.config.MaxGasUnitPrice != 0 && gasUnitPrice > tm.config.MaxGasUnitPrice {
+		return tm.config.MaxGasUnitPrice
+	}
+	return gasUnitPrice
+}
+
+// simulate runs the transaction against the current state and returns the
+// max gas amount to send it with.
+func (tm *TxManager) simulate(payload aptos.TransactionPayload, sequenceNumber uint64, gasUnitPrice uint64) (uint64, error) {
+	maxGasAmount := tm.config.MaxGasAmount
+	if maxGasAmount == 0 {
+		maxGasAmount = aptos.DefaultMaxGasAmount
+	}
+	rawTxn, err := tm.client.BuildTransaction(tm.account.AccountAddress(), payload,
+		aptos.SequenceNumber(sequenceNumber),
+		aptos.GasUnitPrice(gasUnitPrice),
+		aptos.MaxGasAmount(maxGasAmount),
+		aptos.ExpirationSeconds(tm.config.ExpirationSeconds),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build transaction: %v", err)
+	}
+	simulated, err := tm.client.SimulateTransaction(rawTxn, tm.account)
+	if err != nil {
+		return 0, err
+	}
+	if len(simulated) == 0 {
+		return 0, fmt.Errorf("empty simulation result")
+	}
+	if !simulated[0].Success {
+		return 0, vmStatusError(simulated[0].VmStatus)
+	}
+	if tm.config.MaxGasAmount != 0 {
+		return tm.config.MaxGasAmount, nil
+	}
+	return simulatedMaxGas(simulated[0]), nil
+}
+
+// simulatedMaxGas leaves 50% on top of the simulated gas use.
+func simulatedMaxGas(simulated *api.UserTransaction) uint64 {
+	maxGasAmount := simulated.GasUsed + simulated.GasUsed/2
+	if maxGasAmount < aptos.DefaultMaxGasAmount/10 {
+		return aptos.DefaultMaxGasAmount / 10
+	}
+	return maxGasAmount
+}
+
+func isSequenceNumberError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SEQUENCE_NUMBER_TOO_OLD") ||
+		strings.Contains(msg, "SEQUENCE_NUMBER_TOO_NEW") ||
+		strings.Contains(msg, "invalid_transaction_update")
+}
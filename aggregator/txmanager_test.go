@@ -0,0 +1,230 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type sentTxn struct {
+	SequenceNumber uint64
+	GasUnitPrice   uint64
+}
+
+// fakeChain answers the account, simulation and transaction endpoints the
+// transaction manager uses. A submitted transaction with the account's
+// sequence number is committed right away; rejectSubmits submissions are
+// refused by the mempool first, and simulations fail with simulateStatus
+// when it is set.
+type fakeChain struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	sequenceNumber uint64
+	accountReads   int
+	rejectSubmits  int
+	simulateStatus string
+	simulated      []sentTxn
+	submitted      []sentTxn
+	committed      map[string]sentTxn
+}
+
+func newFakeChain(t *testing.T, sequenceNumber uint64) *fakeChain {
+	t.Helper()
+	chain := &fakeChain{sequenceNumber: sequenceNumber, committed: make(map[string]sentTxn)}
+	chain.Server = httptest.NewServer(http.HandlerFunc(chain.serve))
+	t.Cleanup(chain.Close)
+	return chain
+}
+
+func (c *fakeChain) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/accounts/"):
+		c.accountReads++
+		json.NewEncoder(w).Encode(map[string]string{
+			"sequence_number":    strconv.FormatUint(c.sequenceNumber, 10),
+			"authentication_key": "0x00",
+		})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transactions/simulate":
+		txn, err := readRawTxn(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.simulated = append(c.simulated, txn)
+		result := map[string]interface{}{"type": "user_transaction", "hash": "0x0", "success": true, "vm_status": "Executed successfully", "gas_used": "100"}
+		if c.simulateStatus != "" {
+			result["success"] = false
+			result["vm_status"] = c.simulateStatus
+		}
+		json.NewEncoder(w).Encode([]interface{}{result})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/transactions":
+		txn, err := readRawTxn(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.submitted = append(c.submitted, txn)
+		if c.rejectSubmits > 0 {
+			c.rejectSubmits--
+			http.Error(w, `{"message":"mempool is full","error_code":"mempool_is_full"}`, http.StatusServiceUnavailable)
+			return
+		}
+		if txn.SequenceNumber != c.sequenceNumber {
+			http.Error(w, `{"message":"SEQUENCE_NUMBER_TOO_OLD","error_code":"vm_error"}`, http.StatusBadRequest)
+			return
+		}
+		c.sequenceNumber++
+		hash := fmt.Sprintf("0x%064x", len(c.submitted))
+		c.committed[hash] = txn
+		json.NewEncoder(w).Encode(map[string]interface{}{"hash": hash, "sequence_number": strconv.FormatUint(txn.SequenceNumber, 10)})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/transactions/by_hash/"):
+		hash := strings.TrimPrefix(r.URL.Path, "/v1/transactions/by_hash/")
+		txn, ok := c.committed[hash]
+		if !ok {
+			http.Error(w, `{"message":"transaction not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type":            "user_transaction",
+			"hash":            hash,
+			"version":         "1",
+			"success":         true,
+			"vm_status":       "Executed successfully",
+			"gas_used":        "100",
+			"sequence_number": strconv.FormatUint(txn.SequenceNumber, 10),
+			"gas_unit_price":  strconv.FormatUint(txn.GasUnitPrice, 10),
+		})
+
+	default:
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}
+}
+
+// readRawTxn decodes the raw transaction the signed transaction in the body
+// starts with.
+func readRawTxn(r *http.Request) (sentTxn, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return sentTxn{}, err
+	}
+	var txn aptos.RawTransaction
+	des := bcs.NewDeserializer(body)
+	txn.UnmarshalBCS(des)
+	if err := des.Error(); err != nil {
+		return sentTxn{}, err
+	}
+	return sentTxn{SequenceNumber: txn.SequenceNumber, GasUnitPrice: txn.GasUnitPrice}, nil
+}
+
+func newTestTxManager(t *testing.T, chain *fakeChain, config TxManagerConfig) *TxManager {
+	t.Helper()
+	client, err := aptos.NewClient(aptos.NetworkConfig{Name: "test", NodeUrl: chain.URL + "/v1", ChainId: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := aptos.NewEd25519Account()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTxManager(zap.NewNop(), client, account, config)
+}
+
+func testPayload() aptos.TransactionPayload {
+	return aptos.TransactionPayload{Payload: &aptos.EntryFunction{
+		Module:   aptos.ModuleId{Address: aptos.AccountOne, Name: "service_manager"},
+		Function: "respond_to_task",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{},
+	}}
+}
+
+func TestTxManagerSequenceNumber(t *testing.T) {
+	chain := newFakeChain(t, 5)
+	tm := newTestTxManager(t, chain, TxManagerConfig{GasUnitPrice: 100, MaxAttempts: 1})
+
+	for i := 0; i < 3; i++ {
+		if _, err := tm.Submit(testPayload()); err != nil {
+			t.Fatalf("transaction %d: %v", i, err)
+		}
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	for i, txn := range chain.submitted {
+		if txn.SequenceNumber != uint64(5+i) {
+			t.Errorf("transaction %d sent with sequence number %d, want %d", i, txn.SequenceNumber, 5+i)
+		}
+	}
+	// the sequence number is read once and then kept locally
+	if chain.accountReads != 1 {
+		t.Errorf("account read %d times, want once", chain.accountReads)
+	}
+}
+
+func TestTxManagerGasBump(t *testing.T) {
+	chain := newFakeChain(t, 5)
+	chain.rejectSubmits = 2
+	tm := newTestTxManager(t, chain, TxManagerConfig{GasUnitPrice: 100, MaxAttempts: 3, GasBumpPercent: 20})
+
+	if _, err := tm.Submit(testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	chain.mu.Lock()
+	want := []sentTxn{{5, 100}, {5, 121}, {5, 146}}
+	if fmt.Sprint(chain.submitted) != fmt.Sprint(want) {
+		t.Errorf("sent %v, want %v: the same sequence number with a higher gas price", chain.submitted, want)
+	}
+	chain.rejectSubmits = 3
+	chain.mu.Unlock()
+
+	// giving up after MaxAttempts reads the sequence number again
+	if _, err := tm.Submit(testPayload()); err == nil {
+		t.Error("transaction rejected on every attempt was sent")
+	}
+	chain.mu.Lock()
+	reads := chain.accountReads
+	chain.mu.Unlock()
+	if _, err := tm.Submit(testPayload()); err != nil {
+		t.Fatal(err)
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if chain.accountReads != reads+1 {
+		t.Errorf("account read %d times after a failed submission, want %d", chain.accountReads, reads+1)
+	}
+	if last := chain.submitted[len(chain.submitted)-1]; last.SequenceNumber != 6 {
+		t.Errorf("transaction after the failed one sent with sequence number %d, want 6", last.SequenceNumber)
+	}
+}
+
+func TestTxManagerSimulationFailure(t *testing.T) {
+	chain := newFakeChain(t, 5)
+	chain.simulateStatus = "Move abort in 0x1::service_manager: ETASK_ALREADY_RESPONDED(0x516): "
+	tm := newTestTxManager(t, chain, TxManagerConfig{GasUnitPrice: 100, MaxAttempts: 3})
+
+	_, err := tm.Submit(testPayload())
+	if !errors.Is(err, ErrTaskAlreadyResponded) {
+		t.Errorf("simulation abort: %v, want %v", err, ErrTaskAlreadyResponded)
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	if len(chain.simulated) != 1 || len(chain.submitted) != 0 {
+		t.Errorf("%d simulated and %d sent after a failed simulation, want 1 and none", len(chain.simulated), len(chain.submitted))
+	}
+}
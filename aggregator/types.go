@@ -1,8 +1,11 @@
 package aggregator
 
 import (
+	"avs/admin"
 	"math/big"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	aptos "github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
@@ -13,6 +16,24 @@ type AggregatorConfig struct {
 	ServerIpPortAddress string
 	AvsAddress          string
 	AccountConfig       AccountConfig
+	TaskSource          string `json:",omitempty"`
+	TaskStartVersion    uint64 `json:",omitempty"`
+	DbPath              string `json:",omitempty"`
+	ResponsePolicy      string `json:",omitempty"`
+	// CollectionWindowSeconds is how long responses are still collected after
+	// quorum is reached, 0 submits right away.
+	CollectionWindowSeconds uint64 `json:",omitempty"`
+	// TaskTimeoutSeconds is how long after its creation a task may collect
+	// responses before it expires, DefaultTaskTimeoutSeconds when 0.
+	TaskTimeoutSeconds uint64 `json:",omitempty"`
+	TxManager          TxManagerConfig
+	Tls                *TlsConfig `json:",omitempty"`
+	Auth               string     `json:",omitempty"`
+	RateLimit          RateLimitConfig
+	// AdminAddress serves /healthz, /readyz and /status when set
+	AdminAddress string `json:",omitempty"`
+	// MetricsAddress serves the Prometheus metrics on /metrics when set
+	MetricsAddress string `json:",omitempty"`
 }
 
 type AccountConfig struct {
@@ -25,15 +46,57 @@ type Aggregator struct {
 	AvsAddress        string
 	AggregatorAccount aptos.Account
 	AggregatorConfig  AggregatorConfig
-	TaskQueue         chan Task
 	PendingTasks      map[uint64]TaskInfo
 	TaskMutex         sync.Mutex
+	TaskLocks         *TaskLocks
 	Network           aptos.NetworkConfig
+	Client            *aptos.Client
+	TaskStore         *TaskStore
+	TxManager         *TxManager
+	TaskFeed          *TaskFeed
+	Admission         *Admission
+	// Authenticator is set when operators must log in with a challenge
+	Authenticator *Authenticator
+	// OperatorSet maps the hex encoded BLS pubkey of every registered operator
+	// to its account, it is refreshed by the chore.
+	OperatorSet      map[string]aptos.AccountAddress
+	OperatorSetMutex sync.RWMutex
+	// Errors keeps the last error logged, lastChore is the unix time the
+	// chore last ran
+	Errors    *admin.ErrorRecorder
+	lastChore atomic.Int64
+	// taskCount caches the on-chain task count for isKnownTask
+	taskCount struct {
+		mu     sync.Mutex
+		count  uint64
+		readAt time.Time
+	}
+	// respondedTasks holds the ids isKnownTask found responded on chain
+	respondedTasks struct {
+		mu  sync.Mutex
+		ids map[uint64]struct{}
+	}
 }
 
 type TaskInfo struct {
-	State     map[string]interface{}
-	Responses []SignedTaskResponse
+	State       map[string]interface{}
+	Responses   []SignedTaskResponse
+	SignedStake uint64
+	TotalStake  uint64
+	TxHashes    []string
+	Status      TaskStatus
+	// QuorumReachedAt is the unix time the task reached quorum
+	QuorumReachedAt int64
+	// LateResponses arrived after quorum was reached and are not submitted
+	LateResponses []SignedTaskResponse
+	// Equivocations lists the operators that signed two different responses
+	Equivocations []Equivocation
+}
+
+type Equivocation struct {
+	Pubkey []byte
+	First  SignedTaskResponse
+	Second SignedTaskResponse
 }
 
 type Task struct {
@@ -0,0 +1,135 @@
+package aggregator
+
+import (
+	"avs/metrics"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	BlsPubkeyLength    = 48
+	BlsSignatureLength = 96
+)
+
+// Errors returned to operators when a signed task response is rejected.
+// net/rpc only carries the error message, use IsRejection on the client side.
+var (
+	ErrMalformedResponse    = errors.New("malformed task response")
+	ErrUnknownOperator      = errors.New("pubkey is not in the registered operator set")
+	ErrInvalidSignature     = errors.New("invalid signature for task response")
+	ErrOperatorSetNotReady  = errors.New("operator set is not loaded yet")
+	ErrUnknownTask          = errors.New("task does not exist")
+	ErrTaskExpired          = errors.New("task expired")
+	ErrQuorumAlreadyReached = errors.New("task already reached quorum")
+)
+
+var rejectionErrors = []error{
+	ErrMalformedResponse,
+	ErrUnknownOperator,
+	ErrInvalidSignature,
+	ErrDuplicateResponse,
+	ErrConflictingResponse,
+	ErrUnknownTask,
+	ErrTaskExpired,
+	ErrQuorumAlreadyReached,
+}
+
+// IsRejection reports whether err means the aggregator refused the response
+// itself, in which case resending the same response can not succeed.
+func IsRejection(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, rejection := range rejectionErrors {
+		if errors.Is(err, rejection) || strings.Contains(err.Error(), rejection.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResponseFormat validates a response without touching the chain.
+func checkResponseFormat(signedTaskResponse SignedTaskResponse) error {
+	if len(signedTaskResponse.Pubkey) != BlsPubkeyLength {
+		return errors.Wrapf(ErrMalformedResponse, "pubkey must be %d bytes, got %d", BlsPubkeyLength, len(signedTaskResponse.Pubkey))
+	}
+	if len(signedTaskResponse.Signature) != BlsSignatureLength {
+		return errors.Wrapf(ErrMalformedResponse, "signature must be %d bytes, got %d", BlsSignatureLength, len(signedTaskResponse.Signature))
+	}
+	if signedTaskResponse.Response == nil || signedTaskResponse.Response.Sign() < 0 || signedTaskResponse.Response.BitLen() > 128 {
+		return errors.Wrap(ErrMalformedResponse, "response must be a u128")
+	}
+	return nil
+}
+
+// checkOperator makes sure the pubkey belongs to an operator the last chore
+// found registered in one of the quorums.
+func (agg *Aggregator) checkOperator(pubkey []byte) error {
+	agg.OperatorSetMutex.RLock()
+	defer agg.OperatorSetMutex.RUnlock()
+
+	if agg.OperatorSet == nil {
+		return ErrOperatorSetNotReady
+	}
+	if _, ok := agg.OperatorSet[hex.EncodeToString(pubkey)]; !ok {
+		return errors.Wrapf(ErrUnknownOperator, "pubkey 0x%x", pubkey)
+	}
+	return nil
+}
+
+// VerifyResponseSignature checks the BLS signature of a response against the
+// message hash service_manager computes for it.
+func VerifyResponseSignature(signedTaskResponse SignedTaskResponse, msgHash []byte) error {
+	var pubkey crypto.BlsPublicKey
+	err := pubkey.FromBytes(signedTaskResponse.Pubkey)
+	if err != nil {
+		return errors.Wrap(ErrMalformedResponse, err.Error())
+	}
+	var signature crypto.BlsSignature
+	err = signature.FromBytes(signedTaskResponse.Signature)
+	if err != nil {
+		return errors.Wrap(ErrMalformedResponse, err.Error())
+	}
+	if !pubkey.Verify(msgHash, &signature) {
+		return errors.Wrapf(ErrInvalidSignature, "task %d, pubkey 0x%x", signedTaskResponse.TaskId, signedTaskResponse.Pubkey)
+	}
+	return nil
+}
+
+func GetOperatorPubkey(client *aptos.Client, contract aptos.AccountAddress, operator aptos.AccountAddress) ([]byte, error) {
+	operatorBcs, err := bcs.Serialize(&operator)
+	if err != nil {
+		return nil, err
+	}
+	payload := &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "bls_apk_registry",
+		},
+		Function: "get_operator_pk",
+		ArgTypes: []aptos.TypeTag{},
+		Args: [][]byte{
+			operatorBcs,
+		},
+	}
+
+	vals, err := metrics.View(client, payload)
+	if err != nil {
+		return nil, err
+	}
+	pubkey, ok := vals[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected operator pubkey format: %v", vals[0])
+	}
+	pubkeyHex, ok := pubkey["bytes"].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected operator pubkey format: %v", vals[0])
+	}
+	return hex.DecodeString(strings.TrimPrefix(pubkeyHex, "0x"))
+}
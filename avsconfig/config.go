@@ -0,0 +1,197 @@
+package avsconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the config file version this release reads.
+const Version = 1
+
+const (
+	RoleOperator   = "operator"
+	RoleAggregator = "aggregator"
+
+	// EnvPrefix starts the name of every environment override.
+	EnvPrefix = "AVS"
+
+	DefaultAptosConfig = ".aptos/config.yaml"
+)
+
+// File is the unified config of one node, read from YAML. Keys are the field
+// names, as in the JSON configs, and the section named after the role holds
+// what the role's JSON config held:
+//
+//	Version: 1
+//	Role: operator
+//	Network:
+//	  Name: custom
+//	  FullnodeUrl: https://fullnode.example.com/v1
+//	  ChainId: 2
+//	  Headers:
+//	    Authorization: Bearer <api key>
+//	Account:
+//	  AptosConfig: .aptos/config.yaml
+//	  Profile: default
+//	Operator:
+//	  AvsAddress: "0x..."
+//	  AggregatorIpPortAddr: 127.0.0.1:8090
+type File struct {
+	Version    int
+	Role       string
+	Network    Network
+	Account    Account
+	Operator   json.RawMessage `json:",omitempty"`
+	Aggregator json.RawMessage `json:",omitempty"`
+}
+
+// Account is the Aptos CLI profile the node sends transactions with.
+type Account struct {
+	AptosConfig string `json:",omitempty"`
+	Profile     string `json:",omitempty"`
+}
+
+// ReadRole returns the role of the config file at path, so the caller knows
+// which role to load it as.
+func ReadRole(path string) (string, error) {
+	file, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+	return file.Role, nil
+}
+
+// Load reads the config file at path for role. The role section is decoded
+// into section, a pointer to the role's config struct, then the AVS_*
+// environment variables are applied. Unknown keys are an error, validating
+// the network and section is left to the role.
+func Load(path string, role string, section interface{}) (*File, error) {
+	file, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported config version %d, this release reads version %d", path, file.Version, Version)
+	}
+	if file.Role != role {
+		return nil, fmt.Errorf("%s: config is for role %q, expected %q", path, file.Role, role)
+	}
+
+	raw, other, otherName := file.Operator, file.Aggregator, "Aggregator"
+	if role == RoleAggregator {
+		raw, other, otherName = file.Aggregator, file.Operator, "Operator"
+	}
+	if len(other) != 0 {
+		return nil, fmt.Errorf("%s: a %s config can not have an %s section", path, role, otherName)
+	}
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, fmt.Errorf("%s: missing %s section", path, sectionName(role))
+	}
+	if err := decodeStrict(raw, section); err != nil {
+		return nil, fmt.Errorf("%s: %s: %v", path, sectionName(role), err)
+	}
+
+	if err := ApplyEnv(EnvPrefix+"_NETWORK", &file.Network); err != nil {
+		return nil, err
+	}
+	if err := ApplyEnv(EnvPrefix+"_ACCOUNT", &file.Account); err != nil {
+		return nil, err
+	}
+	if err := ApplyEnv(EnvPrefix+"_"+strings.ToUpper(role), section); err != nil {
+		return nil, err
+	}
+	if file.Account.AptosConfig == "" {
+		file.Account.AptosConfig = DefaultAptosConfig
+	}
+	return file, nil
+}
+
+func readFile(path string) (*File, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(bz, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	// the YAML goes through JSON so the role sections decode with the json
+	// tags of the existing config structs
+	bz, err = json.Marshal(jsonValue(doc))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	var file File
+	if err := decodeStrict(bz, &file); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	return &file, nil
+}
+
+func decodeStrict(bz []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(bz))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// jsonValue turns the maps with non-string keys yaml decodes into ones
+// encoding/json accepts.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = jsonValue(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func sectionName(role string) string {
+	if role == "" {
+		return role
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// CheckAptosProfile makes sure the Aptos CLI config at path has a key for
+// profile, which the account would otherwise silently be created without.
+func CheckAptosProfile(path string, profile string) error {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var aptosConfig struct {
+		Profiles map[string]struct {
+			PrivateKey string `yaml:"private_key"`
+		} `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(bz, &aptosConfig); err != nil {
+		return fmt.Errorf("can not parse %s: %v", path, err)
+	}
+	p, ok := aptosConfig.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("%s has no profile %q", path, profile)
+	}
+	if p.PrivateKey == "" {
+		return fmt.Errorf("profile %q of %s has no private_key", profile, path)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package avsconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testSection struct {
+	AvsAddress   string
+	Port         int
+	Enabled      bool
+	PriceSources []testSource
+	RemoteSigner *testSigner
+}
+
+type testSource struct {
+	Type   string
+	ApiKey string `json:",omitempty"`
+}
+
+type testSigner struct {
+	Url string
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testConfig = `Version: 1
+Role: operator
+Network:
+  Name: custom
+  FullnodeUrl: http://127.0.0.1:8080/v1
+  ChainId: 4
+  Headers:
+    Authorization: Bearer key
+Account:
+  Profile: operator
+Operator:
+  AvsAddress: "0x1"
+  Port: 8090
+  Enabled: true
+  PriceSources:
+    - Type: binance
+    - Type: coinmarketcap
+      ApiKey: secret
+`
+
+func TestLoad(t *testing.T) {
+	var section testSection
+	file, err := Load(writeConfig(t, testConfig), RoleOperator, &section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Network.Name != NetworkCustom || file.Network.ChainId != 4 || file.Network.Headers["Authorization"] != "Bearer key" {
+		t.Errorf("network %+v", file.Network)
+	}
+	if file.Account.Profile != "operator" || file.Account.AptosConfig != DefaultAptosConfig {
+		t.Errorf("account %+v, want profile operator and the default aptos config", file.Account)
+	}
+	if section.AvsAddress != "0x1" || section.Port != 8090 || !section.Enabled {
+		t.Errorf("section %+v", section)
+	}
+	if len(section.PriceSources) != 2 || section.PriceSources[1].ApiKey != "secret" {
+		t.Errorf("price sources %+v", section.PriceSources)
+	}
+	if section.RemoteSigner != nil {
+		t.Errorf("remote signer %+v, want none", section.RemoteSigner)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	for _, tc := range []struct {
+		name, config, want string
+	}{
+		{"unknown top level key", testConfig + "Extra: 1\n", "unknown field"},
+		{"unknown network key", strings.Replace(testConfig, "  ChainId: 4", "  ChainNumber: 4", 1), "unknown field"},
+		{"unknown section key", strings.Replace(testConfig, "  Port: 8090", "  Prot: 8090", 1), "unknown field"},
+		{"wrong type", strings.Replace(testConfig, "  Port: 8090", "  Port: eighty", 1), "cannot unmarshal"},
+		{"invalid yaml", testConfig + "  - : :\n\t", "error parsing config file"},
+		{"version", strings.Replace(testConfig, "Version: 1", "Version: 2", 1), "unsupported config version"},
+		{"role", strings.Replace(testConfig, "Role: operator", "Role: aggregator", 1), "expected \"operator\""},
+		{"other section", testConfig + "Aggregator:\n  Port: 1\n", "can not have an Aggregator section"},
+		{"missing section", testConfig[:strings.Index(testConfig, "Operator:")], "missing Operator section"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var section testSection
+			_, err := Load(writeConfig(t, tc.config), RoleOperator, &section)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("AVS_NETWORK_FULLNODE_URL", "http://127.0.0.1:9090/v1")
+	t.Setenv("AVS_ACCOUNT_PROFILE", "other")
+	t.Setenv("AVS_OPERATOR_PORT", "9091")
+	t.Setenv("AVS_OPERATOR_REMOTE_SIGNER_URL", "https://signer:9000")
+
+	var section testSection
+	file, err := Load(writeConfig(t, testConfig), RoleOperator, &section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Network.FullnodeUrl != "http://127.0.0.1:9090/v1" {
+		t.Errorf("fullnode url %s, want the environment one", file.Network.FullnodeUrl)
+	}
+	if file.Account.Profile != "other" {
+		t.Errorf("profile %s, want the environment one", file.Account.Profile)
+	}
+	if section.Port != 9091 || section.AvsAddress != "0x1" {
+		t.Errorf("section %+v, want the port of the environment and the address of the file", section)
+	}
+	if section.RemoteSigner == nil || section.RemoteSigner.Url != "https://signer:9000" {
+		t.Errorf("remote signer %+v, want it created from the environment", section.RemoteSigner)
+	}
+
+	t.Setenv("AVS_OPERATOR_PORT", "many")
+	if _, err := Load(writeConfig(t, testConfig), RoleOperator, &testSection{}); err == nil || !strings.Contains(err.Error(), "AVS_OPERATOR_PORT") {
+		t.Errorf("invalid number in the environment: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package avsconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ApplyEnv overrides the fields of v, a pointer to a config struct, with the
+// environment. The variable of a field is prefix followed by the field path
+// in upper snake case, so the AvsAddress of the operator section is
+// AVS_OPERATOR_AVS_ADDRESS and the Url of its RemoteSigner
+// AVS_OPERATOR_REMOTE_SIGNER_URL. Fields that are not numbers, strings or
+// booleans, like lists and maps, take JSON.
+func ApplyEnv(prefix string, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("can not apply environment to %T", v)
+	}
+	return applyEnv(prefix, value.Elem())
+}
+
+func applyEnv(name string, value reflect.Value) error {
+	switch {
+	case value.Kind() == reflect.Struct:
+		t := value.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if err := applyEnv(name+"_"+envName(field), value.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct:
+		// a missing section is only created when one of its fields is set
+		if value.IsNil() {
+			if !hasEnvPrefix(name + "_") {
+				return nil
+			}
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return applyEnv(name, value.Elem())
+	}
+
+	env, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	if err := setFromString(value, env); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+func setFromString(value reflect.Value, s string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		value.SetFloat(f)
+	default:
+		target := reflect.New(value.Type())
+		if err := json.Unmarshal([]byte(s), target.Interface()); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		value.Set(target.Elem())
+	}
+	return nil
+}
+
+func hasEnvPrefix(prefix string) bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// envName is the json name of the field in upper snake case:
+// AggregatorIpPortAddr becomes AGGREGATOR_IP_PORT_ADDR.
+func envName(field reflect.StructField) string {
+	name := field.Name
+	if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+		name = tag
+	}
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
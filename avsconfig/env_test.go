@@ -0,0 +1,82 @@
+package avsconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	type names struct {
+		AggregatorIpPortAddr string
+		BlsKeystore          string
+		Ed25519Pubkey        string
+		TLSConfig            string
+		Tagged               string `json:"SignerUrl,omitempty"`
+	}
+	want := []string{"AGGREGATOR_IP_PORT_ADDR", "BLS_KEYSTORE", "ED25519_PUBKEY", "TLS_CONFIG", "SIGNER_URL"}
+	typ := reflect.TypeOf(names{})
+	for i, name := range want {
+		if got := envName(typ.Field(i)); got != name {
+			t.Errorf("env name of %s is %s, want %s", typ.Field(i).Name, got, name)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	type nested struct {
+		Url string
+	}
+	type config struct {
+		Name    string
+		Count   uint8
+		Ratio   float64
+		On      bool
+		List    []string
+		Map     map[string]int
+		Nested  *nested
+		Missing *nested
+		Skipped string `json:"-"`
+	}
+	t.Setenv("TEST_NAME", "name")
+	t.Setenv("TEST_COUNT", "7")
+	t.Setenv("TEST_RATIO", "0.5")
+	t.Setenv("TEST_ON", "true")
+	t.Setenv("TEST_LIST", `["a","b"]`)
+	t.Setenv("TEST_MAP", `{"a":1}`)
+	t.Setenv("TEST_NESTED_URL", "http://signer")
+	t.Setenv("TEST_SKIPPED", "set")
+
+	var c config
+	if err := ApplyEnv("TEST", &c); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Name:   "name",
+		Count:  7,
+		Ratio:  0.5,
+		On:     true,
+		List:   []string{"a", "b"},
+		Map:    map[string]int{"a": 1},
+		Nested: &nested{Url: "http://signer"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("applied %+v, want %+v", c, want)
+	}
+
+	for name, value := range map[string]string{
+		"TEST_COUNT": "300",
+		"TEST_ON":    "maybe",
+		"TEST_LIST":  "a,b",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if err := ApplyEnv("TEST", &config{}); err == nil {
+				t.Errorf("%s=%s accepted", name, value)
+			}
+		})
+	}
+
+	if err := ApplyEnv("TEST", c); err == nil {
+		t.Error("applied to a struct that is not a pointer")
+	}
+}
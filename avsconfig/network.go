@@ -0,0 +1,132 @@
+package avsconfig
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+)
+
+// NetworkCustom is the network name for endpoints that are not one of the
+// built-in networks.
+const NetworkCustom = "custom"
+
+// Network is the Aptos network a node talks to. Name is mainnet, testnet,
+// devnet, localnet or custom; the URLs and chain id replace those of a
+// built-in network and are required for a custom one, except ChainId which
+// is asked from the fullnode when 0. FullnodeUrl is the REST API root,
+// e.g. https://api.testnet.aptoslabs.com/v1. Headers are sent with every
+// fullnode request, e.g. an API key.
+type Network struct {
+	Name        string
+	FullnodeUrl string            `json:",omitempty"`
+	IndexerUrl  string            `json:",omitempty"`
+	FaucetUrl   string            `json:",omitempty"`
+	ChainId     uint8             `json:",omitempty"`
+	Headers     map[string]string `json:",omitempty"`
+}
+
+func (n Network) Validate() error {
+	problems := &ValidationError{}
+	_, builtin := aptos.NamedNetworks[n.Name]
+	switch {
+	case n.Name == "":
+		problems.Add("Network.Name", "is required, choose one of: mainnet, testnet, devnet, localnet, %s", NetworkCustom)
+	case n.Name == NetworkCustom:
+		if n.FullnodeUrl == "" {
+			problems.Add("Network.FullnodeUrl", "is required for a %s network", NetworkCustom)
+		}
+	case !builtin:
+		problems.Add("Network.Name", "unknown network %q, choose one of: mainnet, testnet, devnet, localnet, %s", n.Name, NetworkCustom)
+	}
+	for _, u := range []struct{ field, value string }{
+		{"Network.FullnodeUrl", n.FullnodeUrl},
+		{"Network.IndexerUrl", n.IndexerUrl},
+		{"Network.FaucetUrl", n.FaucetUrl},
+	} {
+		if u.value == "" {
+			continue
+		}
+		if err := checkUrl(u.value); err != nil {
+			problems.Add(u.field, "%v", err)
+		}
+	}
+	for name := range n.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			problems.Add("Network.Headers", "invalid header name %q", name)
+		}
+	}
+	return problems.Err()
+}
+
+func checkUrl(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", value, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %q must start with http:// or https://", value)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %q has no host", value)
+	}
+	return nil
+}
+
+// NetworkConfig resolves the network to the config the Aptos SDK takes. The
+// headers are remembered for the fullnode URL and sent by clients created
+// with NewClient.
+func (n Network) NetworkConfig() (aptos.NetworkConfig, error) {
+	if err := n.Validate(); err != nil {
+		return aptos.NetworkConfig{}, err
+	}
+	config := aptos.NetworkConfig{Name: NetworkCustom}
+	if builtin, ok := aptos.NamedNetworks[n.Name]; ok {
+		config = builtin
+	}
+	if n.FullnodeUrl != "" {
+		config.NodeUrl = n.FullnodeUrl
+	}
+	if n.IndexerUrl != "" {
+		config.IndexerUrl = n.IndexerUrl
+	}
+	if n.FaucetUrl != "" {
+		config.FaucetUrl = n.FaucetUrl
+	}
+	if n.ChainId != 0 {
+		config.ChainId = n.ChainId
+	}
+	if len(n.Headers) != 0 {
+		setHeaders(config.NodeUrl, n.Headers)
+	}
+	return config, nil
+}
+
+var (
+	headersMu sync.RWMutex
+	// headers maps a fullnode URL to the headers sent with its requests
+	headers = make(map[string]map[string]string)
+)
+
+func setHeaders(nodeUrl string, h map[string]string) {
+	headersMu.Lock()
+	defer headersMu.Unlock()
+	headers[nodeUrl] = h
+}
+
+// NewClient creates an Aptos client that sends the headers configured for
+// the fullnode of network with every request.
+func NewClient(network aptos.NetworkConfig) (*aptos.Client, error) {
+	client, err := aptos.NewClient(network)
+	if err != nil {
+		return nil, err
+	}
+	headersMu.RLock()
+	defer headersMu.RUnlock()
+	for key, value := range headers[network.NodeUrl] {
+		client.SetHeader(key, value)
+	}
+	return client, nil
+}
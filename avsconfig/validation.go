@@ -0,0 +1,61 @@
+package avsconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problem is one thing wrong with a config field.
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// ValidationError lists everything wrong with a config, so it can be fixed in
+// one go.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.String())
+	}
+	return "invalid config: " + strings.Join(problems, "; ")
+}
+
+// Add records a problem with field.
+func (e *ValidationError) Add(field string, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Merge adds the problems of err, a *ValidationError, with their fields
+// prefixed by section.
+func (e *ValidationError) Merge(section string, err error) {
+	other, ok := err.(*ValidationError)
+	if !ok {
+		if err != nil {
+			e.Add(section, "%v", err)
+		}
+		return
+	}
+	for _, problem := range other.Problems {
+		if section != "" {
+			problem.Field = section + "." + problem.Field
+		}
+		e.Problems = append(e.Problems, problem)
+	}
+}
+
+// Err returns e when it has problems and nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"avs/aggregator"
+	"avs/avsconfig"
+	"fmt"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	operator "avs/operator"
+)
+
+func ConfigCommand(zLogger *zap.Logger) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "work with the unified config files of the operator and aggregator",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	configCmd.AddCommand(validateConfig(zLogger))
+	return configCmd
+}
+
+func validateConfig(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "validate <file>",
+		Short:        "check a config file, with the AVS_* environment applied, without starting anything",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			role, err := avsconfig.ReadRole(path)
+			if err != nil {
+				return err
+			}
+
+			var network aptos.NetworkConfig
+			switch role {
+			case avsconfig.RoleOperator:
+				var config *operator.NodeConfig
+				if config, err = operator.LoadNodeConfig(path); err == nil {
+					network = config.Network
+				}
+			case avsconfig.RoleAggregator:
+				var config *aggregator.NodeConfig
+				if config, err = aggregator.LoadNodeConfig(path); err == nil {
+					network = config.Network
+				}
+			default:
+				return fmt.Errorf("%s: unknown role %q, choose %s or %s", path, role, avsconfig.RoleOperator, avsconfig.RoleAggregator)
+			}
+
+			var validationErr *avsconfig.ValidationError
+			if errors.As(err, &validationErr) {
+				for _, problem := range validationErr.Problems {
+					fmt.Printf("  %s\n", problem)
+				}
+				return fmt.Errorf("%s is invalid", path)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: valid %s config for network %s (%s)\n", path, role, network.Name, network.NodeUrl)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -25,6 +25,7 @@ func main() {
 	rootCmd.AddCommand(
 		operator.OperatorCommand(zLogger),
 		aggregator.AggregatorCommand(zLogger),
+		ConfigCommand(zLogger),
 	)
 
 	err := rootCmd.Execute()
@@ -0,0 +1,314 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	KdfScrypt = "scrypt"
+	KdfPbkdf2 = "pbkdf2"
+
+	Version = 4
+
+	cipherAes128Ctr = "aes-128-ctr"
+	checksumSha256  = "sha256"
+	prfHmacSha256   = "hmac-sha256"
+
+	scryptN  = 262144
+	scryptR  = 8
+	scryptP  = 1
+	pbkdf2C  = 262144
+	dkLength = 32
+
+	// the kdf params of a keystore file are capped so that loading one can
+	// not take gigabytes of memory or minutes of CPU: scrypt uses 128*n*r
+	// bytes, 1 GiB at most
+	maxScryptMemory = 1 << 30
+	maxScryptP      = 16
+	maxPbkdf2C      = 1 << 22
+	maxDkLength     = 64
+)
+
+var ErrWrongPassword = errors.New("wrong keystore password")
+
+// Keystore is an EIP-2335 keystore holding one encrypted secret, the BLS
+// private key of the operator.
+type Keystore struct {
+	Crypto      Crypto `json:"crypto"`
+	Description string `json:"description"`
+	Pubkey      string `json:"pubkey"`
+	Path        string `json:"path"`
+	Uuid        string `json:"uuid"`
+	Version     int    `json:"version"`
+}
+
+type Crypto struct {
+	Kdf      Module `json:"kdf"`
+	Checksum Module `json:"checksum"`
+	Cipher   Module `json:"cipher"`
+}
+
+type Module struct {
+	Function string                 `json:"function"`
+	Params   map[string]interface{} `json:"params"`
+	Message  string                 `json:"message"`
+}
+
+// Encrypt seals secret with password, deriving the key with kdf (scrypt or
+// pbkdf2). pubkey is stored in the clear so it can be shown without the
+// password.
+func Encrypt(secret []byte, pubkey []byte, password string, kdf string) (*Keystore, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	var kdfModule Module
+	switch kdf {
+	case KdfScrypt, "":
+		kdfModule = Module{
+			Function: KdfScrypt,
+			Params: map[string]interface{}{
+				"dklen": dkLength,
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"salt":  hex.EncodeToString(salt),
+			},
+		}
+	case KdfPbkdf2:
+		kdfModule = Module{
+			Function: KdfPbkdf2,
+			Params: map[string]interface{}{
+				"dklen": dkLength,
+				"c":     pbkdf2C,
+				"prf":   prfHmacSha256,
+				"salt":  hex.EncodeToString(salt),
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unknown kdf %q, use %s or %s", kdf, KdfScrypt, KdfPbkdf2)
+	}
+
+	decryptionKey, err := deriveKey(kdfModule, password)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := aes128Ctr(decryptionKey[:16], iv, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keystore{
+		Crypto: Crypto{
+			Kdf: kdfModule,
+			Checksum: Module{
+				Function: checksumSha256,
+				Params:   map[string]interface{}{},
+				Message:  hex.EncodeToString(checksum(decryptionKey, cipherText)),
+			},
+			Cipher: Module{
+				Function: cipherAes128Ctr,
+				Params: map[string]interface{}{
+					"iv": hex.EncodeToString(iv),
+				},
+				Message: hex.EncodeToString(cipherText),
+			},
+		},
+		Pubkey:  hex.EncodeToString(pubkey),
+		Uuid:    uuid.NewString(),
+		Version: Version,
+	}, nil
+}
+
+// Decrypt returns the secret, or ErrWrongPassword when the checksum does not
+// match.
+func (ks *Keystore) Decrypt(password string) ([]byte, error) {
+	if ks.Version != Version {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+	if ks.Crypto.Checksum.Function != checksumSha256 {
+		return nil, fmt.Errorf("unsupported checksum function %q", ks.Crypto.Checksum.Function)
+	}
+	if ks.Crypto.Cipher.Function != cipherAes128Ctr {
+		return nil, fmt.Errorf("unsupported cipher %q", ks.Crypto.Cipher.Function)
+	}
+	cipherText, err := hex.DecodeString(ks.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher message")
+	}
+	expected, err := hex.DecodeString(ks.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "checksum message")
+	}
+	iv, err := hexParam(ks.Crypto.Cipher.Params, "iv")
+	if err != nil {
+		return nil, err
+	}
+
+	decryptionKey, err := deriveKey(ks.Crypto.Kdf, password)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(checksum(decryptionKey, cipherText), expected) {
+		return nil, ErrWrongPassword
+	}
+	return aes128Ctr(decryptionKey[:16], iv, cipherText)
+}
+
+// PubkeyBytes returns the public key stored with the keystore.
+func (ks *Keystore) PubkeyBytes() ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(ks.Pubkey, "0x"))
+}
+
+func Load(path string) (*Keystore, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ks Keystore
+	if err := json.Unmarshal(bz, &ks); err != nil {
+		return nil, fmt.Errorf("can not parse keystore %s: %v", path, err)
+	}
+	return &ks, nil
+}
+
+// Save writes the keystore readable by its owner only. It refuses to replace
+// an existing file.
+func (ks *Keystore) Save(path string) error {
+	bz, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(bz); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func deriveKey(kdf Module, password string) ([]byte, error) {
+	salt, err := hexParam(kdf.Params, "salt")
+	if err != nil {
+		return nil, err
+	}
+	dklen, err := intParam(kdf.Params, "dklen", maxDkLength)
+	if err != nil {
+		return nil, err
+	}
+	if dklen < dkLength {
+		return nil, fmt.Errorf("kdf dklen must be at least %d, got %d", dkLength, dklen)
+	}
+	passwordBytes := normalizePassword(password)
+
+	switch kdf.Function {
+	case KdfScrypt:
+		n, err := intParam(kdf.Params, "n", maxScryptMemory/128)
+		if err != nil {
+			return nil, err
+		}
+		r, err := intParam(kdf.Params, "r", maxScryptMemory/128/n)
+		if err != nil {
+			return nil, err
+		}
+		p, err := intParam(kdf.Params, "p", maxScryptP)
+		if err != nil {
+			return nil, err
+		}
+		return scrypt.Key(passwordBytes, salt, n, r, p, dklen)
+	case KdfPbkdf2:
+		if prf, _ := kdf.Params["prf"].(string); prf != prfHmacSha256 {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %q", prf)
+		}
+		c, err := intParam(kdf.Params, "c", maxPbkdf2C)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.Key(passwordBytes, salt, c, dklen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", kdf.Function)
+	}
+}
+
+// normalizePassword applies the EIP-2335 rules: NFKD normalization, then
+// dropping the C0, C1 and Delete control codes.
+func normalizePassword(password string) []byte {
+	normalized := norm.NFKD.String(password)
+	return []byte(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, normalized))
+}
+
+func checksum(decryptionKey []byte, cipherText []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, decryptionKey[16:32]...), cipherText...))
+	return sum[:]
+}
+
+func aes128Ctr(key []byte, iv []byte, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+func hexParam(params map[string]interface{}, name string) ([]byte, error) {
+	s, ok := params[name].(string)
+	if !ok {
+		return nil, fmt.Errorf("keystore param %s is missing", name)
+	}
+	return hex.DecodeString(s)
+}
+
+// intParam returns a whole number param between 1 and max.
+func intParam(params map[string]interface{}, name string, max int) (int, error) {
+	var v float64
+	switch param := params[name].(type) {
+	case float64:
+		v = param
+	case int:
+		v = float64(param)
+	default:
+		return 0, fmt.Errorf("keystore param %s is missing", name)
+	}
+	if v != math.Trunc(v) || v < 1 || v > float64(max) {
+		return 0, fmt.Errorf("keystore param %s is %v, it must be a whole number from 1 to %d", name, v, max)
+	}
+	return int(v), nil
+}
@@ -0,0 +1,184 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// The test vectors of EIP-2335, the password normalizes to
+// 0x7465737470617373776f7264f09f9491.
+const (
+	vectorPassword = "𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑"
+	vectorSecret   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+	scryptVector = `{
+    "crypto": {
+        "kdf": {
+            "function": "scrypt",
+            "params": {
+                "dklen": 32,
+                "n": 262144,
+                "p": 1,
+                "r": 8,
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f"
+        }
+    },
+    "description": "This is a test keystore that uses scrypt to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/3141592653/589793238",
+    "uuid": "1d85ae20-35c5-4611-98e8-aa14a633906f",
+    "version": 4
+}`
+
+	pbkdf2Vector = `{
+    "crypto": {
+        "kdf": {
+            "function": "pbkdf2",
+            "params": {
+                "dklen": 32,
+                "c": 262144,
+                "prf": "hmac-sha256",
+                "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"
+            },
+            "message": ""
+        },
+        "checksum": {
+            "function": "sha256",
+            "params": {},
+            "message": "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"
+        },
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {
+                "iv": "264daa3f303d7259501c93d997d84fe6"
+            },
+            "message": "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad"
+        }
+    },
+    "description": "This is a test keystore that uses PBKDF2 to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/0/0",
+    "uuid": "64625def-3331-4eea-ab6f-782f3ed16a83",
+    "version": 4
+}`
+)
+
+func parseKeystore(t *testing.T, vector string) *Keystore {
+	t.Helper()
+	var ks Keystore
+	if err := json.Unmarshal([]byte(vector), &ks); err != nil {
+		t.Fatal(err)
+	}
+	return &ks
+}
+
+func TestNormalizePassword(t *testing.T) {
+	if got := hex.EncodeToString(normalizePassword(vectorPassword)); got != "7465737470617373776f7264f09f9491" {
+		t.Errorf("normalized password %s", got)
+	}
+	if got := string(normalizePassword("pass\x7fword\u0085\n")); got != "password" {
+		t.Errorf("control codes kept: %q", got)
+	}
+}
+
+func TestDecryptVectors(t *testing.T) {
+	secret, err := hex.DecodeString(vectorSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, vector := range map[string]string{KdfScrypt: scryptVector, KdfPbkdf2: pbkdf2Vector} {
+		t.Run(name, func(t *testing.T) {
+			if testing.Short() && name == KdfScrypt {
+				t.Skip("scrypt takes 256 MiB")
+			}
+			ks := parseKeystore(t, vector)
+			got, err := ks.Decrypt(vectorPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Errorf("secret %x, want %s", got, vectorSecret)
+			}
+			if _, err := ks.Decrypt("testpassword"); !errors.Is(err, ErrWrongPassword) {
+				t.Errorf("wrong password: %v, want %v", err, ErrWrongPassword)
+			}
+		})
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	secret, err := hex.DecodeString(vectorSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := Encrypt(secret, []byte{1, 2, 3}, "password", KdfPbkdf2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ks.Decrypt("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("secret %x, want %s", got, vectorSecret)
+	}
+	if _, err := ks.Decrypt("Password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: %v, want %v", err, ErrWrongPassword)
+	}
+	if pubkey, err := ks.PubkeyBytes(); err != nil || !bytes.Equal(pubkey, []byte{1, 2, 3}) {
+		t.Errorf("pubkey %x %v", pubkey, err)
+	}
+}
+
+func TestDecryptCapsKdfParams(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		vector string
+		param  string
+		value  interface{}
+	}{
+		{"scrypt n", scryptVector, "n", float64(1 << 30)},
+		{"scrypt r", scryptVector, "r", float64(1 << 10)},
+		{"scrypt p", scryptVector, "p", float64(1 << 20)},
+		{"scrypt n zero", scryptVector, "n", float64(0)},
+		{"scrypt r negative", scryptVector, "r", float64(-8)},
+		{"scrypt n fraction", scryptVector, "n", 262144.5},
+		{"scrypt n huge", scryptVector, "n", 1e300},
+		{"pbkdf2 c", pbkdf2Vector, "c", float64(1 << 40)},
+		{"pbkdf2 c negative", pbkdf2Vector, "c", float64(-1)},
+		{"dklen", pbkdf2Vector, "dklen", float64(1 << 30)},
+		{"dklen short", pbkdf2Vector, "dklen", float64(16)},
+		{"missing", pbkdf2Vector, "c", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ks := parseKeystore(t, tc.vector)
+			if tc.value == nil {
+				delete(ks.Crypto.Kdf.Params, tc.param)
+			} else {
+				ks.Crypto.Kdf.Params[tc.param] = tc.value
+			}
+			_, err := ks.Decrypt(vectorPassword)
+			if err == nil || errors.Is(err, ErrWrongPassword) {
+				t.Errorf("%s = %v: %v, want it refused", tc.param, tc.value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package keystore
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// PasswordEnv holds the keystore password when no password file is given.
+const PasswordEnv = "AVS_BLS_PASSWORD"
+
+// ReadPassword returns the keystore password from passwordFile, then from
+// AVS_BLS_PASSWORD, then from a prompt on the terminal. With confirm the
+// prompt asks twice, for new keystores.
+func ReadPassword(passwordFile string, confirm bool) (string, error) {
+	if passwordFile != "" {
+		bz, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", fmt.Errorf("can not read password file: %v", err)
+		}
+		return strings.TrimRight(string(bz), "\r\n"), nil
+	}
+	if password, ok := os.LookupEnv(PasswordEnv); ok {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no keystore password, set %s, pass a password file or run in a terminal", PasswordEnv)
+	}
+	password, err := prompt(fd, "Keystore password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := prompt(fd, "Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if again != password {
+			return "", fmt.Errorf("passwords do not match")
+		}
+	}
+	return password, nil
+}
+
+func prompt(fd int, question string) (string, error) {
+	fmt.Fprint(os.Stderr, question)
+	bz, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("can not read password: %v", err)
+	}
+	return string(bz), nil
+}
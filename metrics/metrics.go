@@ -0,0 +1,68 @@
+// Package metrics serves the Prometheus metrics of the aggregator and the
+// operator, and holds the ones both of them report.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+const (
+	Namespace = "avs"
+	Path      = "/metrics"
+)
+
+var (
+	viewDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "view_call_duration_seconds",
+		Help:      "Latency of view calls by Move function.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"function"})
+	viewErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "view_call_errors_total",
+		Help:      "Failed view calls by Move function.",
+	}, []string{"function"})
+)
+
+// View calls a view function and records its latency and errors under
+// module::function.
+func View(client *aptos.Client, payload *aptos.ViewPayload) ([]any, error) {
+	function := payload.Module.Name + "::" + payload.Function
+	start := time.Now()
+	vals, err := client.View(payload)
+	viewDuration.WithLabelValues(function).Observe(time.Since(start).Seconds())
+	if err != nil {
+		viewErrors.WithLabelValues(function).Inc()
+	}
+	return vals, err
+}
+
+// Serve exposes the registered metrics on address until ctx is done.
+func Serve(ctx context.Context, logger *zap.Logger, address string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.Handler())
+	server := &http.Server{
+		Addr:    address,
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	logger.Info("Starting metrics server on address:", zap.String("address", address))
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
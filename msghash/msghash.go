@@ -0,0 +1,88 @@
+// Package msghash computes off-chain the message hashes service_manager
+// signs and checks, byte for byte the same as get_msg_hash and
+// get_msg_hashes in sources/oracle-service-manager.move.
+package msghash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// ServicePrefix is SERVICE_PREFIX in service_manager.
+var ServicePrefix = []byte("SERVICE_PREFIX")
+
+// TaskCreatorSeeds matches task_creator_store_seeds: SERVICE_PREFIX followed
+// by the BCS bytes of the creator address.
+func TaskCreatorSeeds(creator aptos.AccountAddress) []byte {
+	seeds := make([]byte, 0, len(ServicePrefix)+len(creator))
+	seeds = append(seeds, ServicePrefix...)
+	return append(seeds, creator[:]...)
+}
+
+// U64ToBytes matches u64_to_vector_u8, a big endian encoding.
+func U64ToBytes(value uint64) []byte {
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, value)
+	return bz
+}
+
+// U128ToBytes matches u128_to_vector_u8, a 16 byte big endian encoding.
+func U128ToBytes(value *big.Int) ([]byte, error) {
+	if value == nil || value.Sign() < 0 || value.BitLen() > 128 {
+		return nil, fmt.Errorf("%v does not fit in a u128", value)
+	}
+	return value.FillBytes(make([]byte, 16)), nil
+}
+
+// TaskData is the prefix shared by every hash of a task: the task id followed
+// by the creator seeds.
+func TaskData(taskId uint64, creator aptos.AccountAddress) []byte {
+	return append(U64ToBytes(taskId), TaskCreatorSeeds(creator)...)
+}
+
+// TaskIdentifier is the key of the task in tasks_state.
+func TaskIdentifier(taskId uint64, creator aptos.AccountAddress) []byte {
+	return ethcrypto.Keccak256(TaskData(taskId, creator))
+}
+
+// MsgHash is the message an operator signs when it responds to a task.
+func MsgHash(taskId uint64, creator aptos.AccountAddress, response *big.Int) ([]byte, error) {
+	responseBytes, err := U128ToBytes(response)
+	if err != nil {
+		return nil, err
+	}
+	return ethcrypto.Keccak256(TaskData(taskId, creator), responseBytes), nil
+}
+
+// MsgHashes hashes several responses to the same task, in order.
+func MsgHashes(taskId uint64, creator aptos.AccountAddress, responses []*big.Int) ([][]byte, error) {
+	taskData := TaskData(taskId, creator)
+	msgHashes := make([][]byte, 0, len(responses))
+	for _, response := range responses {
+		responseBytes, err := U128ToBytes(response)
+		if err != nil {
+			return nil, err
+		}
+		msgHashes = append(msgHashes, ethcrypto.Keccak256(taskData, responseBytes))
+	}
+	return msgHashes, nil
+}
+
+// TaskCreator reads the creator a task source attached to the task state.
+// It is false when the creator is unknown and the hash has to be computed
+// by the get_msg_hash view instead.
+func TaskCreator(task map[string]interface{}) (aptos.AccountAddress, bool) {
+	creatorStr, ok := task["creator"].(string)
+	if !ok {
+		return aptos.AccountAddress{}, false
+	}
+	creator := aptos.AccountAddress{}
+	if err := creator.ParseStringRelaxed(creatorStr); err != nil {
+		return aptos.AccountAddress{}, false
+	}
+	return creator, true
+}
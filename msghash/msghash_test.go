@@ -0,0 +1,198 @@
+package msghash
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+)
+
+// The golden hashes below are keccak256 over the bytes get_msg_hash builds in
+// sources/oracle-service-manager.move: u64_to_vector_u8(task_id),
+// task_creator_store_seeds(creator) and u128_to_vector_u8(response). They were
+// computed outside this package, from the Move code, not with it.
+
+const (
+	creatorShort = "0x1"
+	creatorLong  = "0xa11ce0000000000000000000000000000000000000000000000000000000b0b"
+	creatorMax   = "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+)
+
+var maxU128, _ = new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+
+func mustAddress(t *testing.T, s string) aptos.AccountAddress {
+	t.Helper()
+	address := aptos.AccountAddress{}
+	if err := address.ParseStringRelaxed(s); err != nil {
+		t.Fatalf("can not parse address %s: %v", s, err)
+	}
+	return address
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	bz, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex %s: %v", s, err)
+	}
+	return bz
+}
+
+func TestU64ToBytes(t *testing.T) {
+	tests := []struct {
+		value uint64
+		want  string
+	}{
+		{0, "0000000000000000"},
+		{1, "0000000000000001"},
+		{258, "0000000000000102"},
+		{1<<64 - 1, "ffffffffffffffff"},
+	}
+	for _, test := range tests {
+		if got := hex.EncodeToString(U64ToBytes(test.value)); got != test.want {
+			t.Errorf("U64ToBytes(%d) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestU128ToBytes(t *testing.T) {
+	tests := []struct {
+		value *big.Int
+		want  string
+	}{
+		{big.NewInt(0), "00000000000000000000000000000000"},
+		{big.NewInt(2450120000), "0000000000000000000000009209dd40"},
+		{maxU128, "ffffffffffffffffffffffffffffffff"},
+	}
+	for _, test := range tests {
+		got, err := U128ToBytes(test.value)
+		if err != nil {
+			t.Fatalf("U128ToBytes(%s): %v", test.value, err)
+		}
+		if hex.EncodeToString(got) != test.want {
+			t.Errorf("U128ToBytes(%s) = %x, want %s", test.value, got, test.want)
+		}
+	}
+
+	for _, value := range []*big.Int{nil, big.NewInt(-1), new(big.Int).Add(maxU128, big.NewInt(1))} {
+		if _, err := U128ToBytes(value); err == nil {
+			t.Errorf("U128ToBytes(%v) should fail", value)
+		}
+	}
+}
+
+func TestTaskCreatorSeeds(t *testing.T) {
+	tests := []struct {
+		creator string
+		want    string
+	}{
+		// BCS encodes an address as its 32 bytes, a short address zero padded
+		{creatorShort, "534552564943455f505245464958" + "0000000000000000000000000000000000000000000000000000000000000001"},
+		{creatorLong, "534552564943455f505245464958" + "0a11ce0000000000000000000000000000000000000000000000000000000b0b"},
+		{creatorMax, "534552564943455f505245464958" + "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+	}
+	for _, test := range tests {
+		if got := hex.EncodeToString(TaskCreatorSeeds(mustAddress(t, test.creator))); got != test.want {
+			t.Errorf("TaskCreatorSeeds(%s) = %s, want %s", test.creator, got, test.want)
+		}
+	}
+}
+
+var goldenHashes = []struct {
+	taskId     uint64
+	creator    string
+	response   *big.Int
+	identifier string
+	msgHash    string
+}{
+	{0, creatorShort, big.NewInt(0),
+		"e1d1cb881e9feb7fedee769b261acb9f5a648f482586259cfc00afdde2037eec",
+		"73dc78431007813d72258dff2145382dd187fad63985654b3f95e5e995169215"},
+	{1, creatorShort, big.NewInt(2450120000),
+		"65e7e243818c350fa4ea7f4e0d7145276341203853750b31d1064e6389f96fe4",
+		"94a332f4db458d743ef40ec8438c2043fa6843e943db33177892d527a4f2f3c1"},
+	{1<<64 - 1, creatorShort, maxU128,
+		"a5b87552a6498e2bce79502cb436b7f3cbfbee002d62207861414e490da2d663",
+		"1b149aa45cd87b38d9ea9b80ab88d944a620db88a72d93b1df23878e60520cc1"},
+	{0, creatorLong, maxU128,
+		"f2c4080f7f199ac9ecdde04223e32916995837fa37e7e5794866977b64c6e0d2",
+		"4dec59c7d40b4c44ebb0091f53088ac112e56c37c17c8e8346129b5dfb036abf"},
+	{42, creatorLong, big.NewInt(1),
+		"663aa01562df06332927aa9fe324a1fddf63cbd97fa6c933ad0b5dde83a1c280",
+		"e04910b1e907f10df39cb5527455f3a9e2b1f339166079dd4016c82b215a953c"},
+	{1<<64 - 1, creatorMax, big.NewInt(0),
+		"92767d487c224ad1cfd8396ac3f999b46eabd15b0c0955e4d7a101302f832b1a",
+		"f40c9aa8a11ea11e470ab32f68cdca7751bc4bae0116e83c31aa4ff93cf45d04"},
+}
+
+func TestTaskIdentifier(t *testing.T) {
+	for _, golden := range goldenHashes {
+		got := TaskIdentifier(golden.taskId, mustAddress(t, golden.creator))
+		if !bytes.Equal(got, mustHex(t, golden.identifier)) {
+			t.Errorf("TaskIdentifier(%d, %s) = %x, want %s", golden.taskId, golden.creator, got, golden.identifier)
+		}
+	}
+}
+
+func TestMsgHash(t *testing.T) {
+	for _, golden := range goldenHashes {
+		got, err := MsgHash(golden.taskId, mustAddress(t, golden.creator), golden.response)
+		if err != nil {
+			t.Fatalf("MsgHash(%d, %s, %s): %v", golden.taskId, golden.creator, golden.response, err)
+		}
+		if !bytes.Equal(got, mustHex(t, golden.msgHash)) {
+			t.Errorf("MsgHash(%d, %s, %s) = %x, want %s", golden.taskId, golden.creator, golden.response, got, golden.msgHash)
+		}
+	}
+
+	if _, err := MsgHash(1, mustAddress(t, creatorShort), new(big.Int).Add(maxU128, big.NewInt(1))); err == nil {
+		t.Error("MsgHash should reject a response above max u128")
+	}
+}
+
+func TestMsgHashes(t *testing.T) {
+	// get_msg_hashes hashes every response with the same task prefix
+	creator := mustAddress(t, creatorLong)
+	hashes, err := MsgHashes(0, creator, []*big.Int{maxU128, maxU128, big.NewInt(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"4dec59c7d40b4c44ebb0091f53088ac112e56c37c17c8e8346129b5dfb036abf",
+		"4dec59c7d40b4c44ebb0091f53088ac112e56c37c17c8e8346129b5dfb036abf",
+	}
+	for i, w := range want {
+		if !bytes.Equal(hashes[i], mustHex(t, w)) {
+			t.Errorf("MsgHashes[%d] = %x, want %s", i, hashes[i], w)
+		}
+	}
+	single, err := MsgHash(0, creator, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hashes[2], single) {
+		t.Errorf("MsgHashes[2] = %x, want MsgHash %x", hashes[2], single)
+	}
+
+	if _, err := MsgHashes(0, creator, []*big.Int{big.NewInt(-1)}); err == nil {
+		t.Error("MsgHashes should reject a negative response")
+	}
+}
+
+func TestTaskCreator(t *testing.T) {
+	creator, ok := TaskCreator(map[string]interface{}{"creator": creatorLong})
+	if !ok || creator != mustAddress(t, creatorLong) {
+		t.Errorf("TaskCreator = %s %t, want %s", creator.String(), ok, creatorLong)
+	}
+	for _, task := range []map[string]interface{}{
+		{},
+		{"creator": 1.0},
+		{"creator": "not an address"},
+	} {
+		if _, ok := TaskCreator(task); ok {
+			t.Errorf("TaskCreator(%v) should be unknown", task)
+		}
+	}
+}
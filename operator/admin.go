@@ -0,0 +1,137 @@
+package operator
+
+import (
+	"avs/admin"
+	"avs/aggregator"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// StatusRecentTasks is how many of the last tasks the operator answered
+	// are looked up at the aggregator for /status.
+	StatusRecentTasks = 10
+	// StatusRefreshInterval is how long the aggregator's view of the recent
+	// tasks is reused, so that /status requests do not use up the operator's
+	// rate limit at the aggregator.
+	StatusRefreshInterval = 30 * time.Second
+)
+
+type OperatorStatus struct {
+	AvsAddress          string           `json:"avs_address"`
+	Network             string           `json:"network"`
+	OperatorAddress     string           `json:"operator_address"`
+	TaskCount           *uint64          `json:"task_count,omitempty"`
+	QueuedTasks         int              `json:"queued_tasks"`
+	RecentTasks         []TaskProgress   `json:"recent_tasks"`
+	RecentTasksAt       time.Time        `json:"recent_tasks_at"`
+	AggregatorConnected bool             `json:"aggregator_connected"`
+	LastFetch           *time.Time       `json:"last_fetch,omitempty"`
+	LastError           *admin.LastError `json:"last_error,omitempty"`
+}
+
+// TaskProgress is the aggregator's view of a task the operator answered.
+type TaskProgress struct {
+	TaskId      uint64                `json:"task_id"`
+	Status      aggregator.TaskStatus `json:"status,omitempty"`
+	Responses   int                   `json:"responses"`
+	SignedStake uint64                `json:"signed_stake"`
+	TotalStake  uint64                `json:"total_stake"`
+	Error       string                `json:"error,omitempty"`
+}
+
+// ServeAdmin serves /healthz, /readyz and /status on AdminAddress.
+func (op *Operator) ServeAdmin(ctx context.Context, address string) error {
+	checks := []admin.Check{
+		{Name: "chain", Run: op.checkChain},
+		{Name: "account_funded", Run: op.checkFunded},
+		{Name: "operator_registered", Run: op.checkRegistered},
+		{Name: "aggregator", Run: op.checkAggregator},
+	}
+	server := admin.NewServer(op.logger, address, checks, func() interface{} {
+		return op.Status()
+	})
+	return server.ListenAndServe(ctx)
+}
+
+func (op *Operator) checkChain() error {
+	_, err := op.client.Info()
+	return err
+}
+
+func (op *Operator) checkFunded() error {
+	balance, err := op.client.AccountAPTBalance(op.account.Address)
+	if err != nil {
+		return err
+	}
+	if balance == 0 {
+		return fmt.Errorf("account %s has no APT to pay for transactions", op.account.Address.String())
+	}
+	return nil
+}
+
+func (op *Operator) checkRegistered() error {
+	status, err := GetOperatorStatus(op.client, op.avsAddress, op.account.Address)
+	if err != nil {
+		return err
+	}
+	if status != 1 {
+		return fmt.Errorf("operator %s is not registered", op.account.Address.String())
+	}
+	return nil
+}
+
+func (op *Operator) checkAggregator() error {
+	_, err := op.AggRpcClient.OperatorSet()
+	return err
+}
+
+func (op *Operator) Status() OperatorStatus {
+	status := OperatorStatus{
+		AvsAddress:          op.avsAddress.String(),
+		Network:             op.network.Name,
+		OperatorAddress:     op.account.Address.String(),
+		QueuedTasks:         len(op.TaskQueue),
+		AggregatorConnected: op.pushConnected.Load(),
+		LastError:           op.errors.Last(),
+	}
+	if count, err := LatestTaskCount(op.client, op.avsAddress); err == nil {
+		status.TaskCount = &count
+	}
+	status.RecentTasks, status.RecentTasksAt = op.recentTasks()
+	if lastFetch := op.lastFetch.Load(); lastFetch != 0 {
+		t := time.Unix(lastFetch, 0)
+		status.LastFetch = &t
+	}
+	return status
+}
+
+// recentTasks returns the aggregator's view of the last tasks answered and
+// when it was asked, at most once per StatusRefreshInterval.
+func (op *Operator) recentTasks() ([]TaskProgress, time.Time) {
+	view := &op.aggregatorView
+	view.mu.Lock()
+	defer view.mu.Unlock()
+	if time.Since(view.checkedAt) < StatusRefreshInterval {
+		return view.tasks, view.checkedAt
+	}
+
+	tasks := []TaskProgress{}
+	for _, taskId := range op.seenTasks.recent(StatusRecentTasks) {
+		progress := TaskProgress{TaskId: taskId}
+		result, err := op.AggRpcClient.TaskStatus(taskId)
+		if err != nil {
+			progress.Error = err.Error()
+		} else {
+			progress.Status = result.Status
+			progress.Responses = result.Responses
+			progress.SignedStake = result.SignedStake
+			progress.TotalStake = result.TotalStake
+		}
+		tasks = append(tasks, progress)
+	}
+	view.tasks = tasks
+	view.checkedAt = time.Now()
+	return view.tasks, view.checkedAt
+}
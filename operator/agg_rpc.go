@@ -2,48 +2,307 @@ package operator
 
 import (
 	"avs/aggregator"
+	"avs/signer"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/rpc"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/pkg/errors"
+	aptos "github.com/aptos-labs/aptos-go-sdk"
 )
 
-func NewAggregatorRpcClient(aggregatorIpPortAddr string) (*AggregatorRpcClient, error) {
-	client, err := rpc.DialHTTP("tcp", aggregatorIpPortAddr)
+// AggregatorRpcTimeout leaves room for a response that completes quorum,
+// which is answered once respond_to_task is committed.
+const AggregatorRpcTimeout = 5 * time.Minute
+
+// AggregatorTlsConfig connects to an aggregator serving TLS. CaFile verifies
+// the aggregator certificate instead of the system roots, CertFile and
+// KeyFile are the client certificate for aggregators requiring mTLS.
+type AggregatorTlsConfig struct {
+	CaFile     string `json:",omitempty"`
+	CertFile   string `json:",omitempty"`
+	KeyFile    string `json:",omitempty"`
+	ServerName string `json:",omitempty"`
+}
+
+func (c *AggregatorTlsConfig) clientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CaFile != "" {
+		pem, err := os.ReadFile(c.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("can not read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CaFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can not load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// AggregatorAuth answers the aggregator's login challenge. Sign returns the
+// public key and the signature of the message for Scheme, Address is the
+// operator account for the ed25519 scheme.
+type AggregatorAuth struct {
+	Scheme  string
+	Address string
+	Sign    func(message []byte) (pubkey []byte, signature []byte, err error)
+}
+
+// endpointUrl parses an address given with or without its scheme, http by
+// default. With secure set the scheme is https whatever the address says.
+func endpointUrl(address string, secure bool) (*url.URL, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	endpoint, err := url.Parse(address)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid address %q: scheme must be http or https", address)
+	}
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid address %q: no host", address)
 	}
+	if secure {
+		endpoint.Scheme = "https"
+	}
+	return endpoint, nil
+}
 
+func NewAggregatorRpcClient(aggregatorIpPortAddr string, tlsConfig *AggregatorTlsConfig, auth *AggregatorAuth) (*AggregatorRpcClient, error) {
+	endpoint, err := endpointUrl(aggregatorIpPortAddr, tlsConfig != nil)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		config, err := tlsConfig.clientConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = config
+	}
 	return &AggregatorRpcClient{
-		rpcClient:            client,
+		httpClient:           &http.Client{Timeout: AggregatorRpcTimeout, Transport: transport},
+		url:                  strings.TrimSuffix(endpoint.String(), "/") + aggregator.ApiPath,
 		aggregatorIpPortAddr: aggregatorIpPortAddr,
+		auth:                 auth,
 	}, nil
 }
 
+// call sends a v1 JSON-RPC request, an *aggregator.RpcError is returned when
+// the aggregator answered with an error. With auth set the client logs in
+// first, and again when its session expired.
+func (c *AggregatorRpcClient) call(method string, params interface{}, result interface{}) error {
+	if c.auth == nil {
+		return c.send(method, params, result, "")
+	}
+
+	token, err := c.session(false)
+	if err != nil {
+		return err
+	}
+	err = c.send(method, params, result, token)
+	var rpcErr *aggregator.RpcError
+	if errors.As(err, &rpcErr) && rpcErr.Code == aggregator.RpcUnauthorized {
+		token, err = c.session(true)
+		if err != nil {
+			return err
+		}
+		return c.send(method, params, result, token)
+	}
+	return err
+}
+
+// session returns a session token, logging in when there is none yet or
+// renew is set.
+func (c *AggregatorRpcClient) session(renew bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && !renew && time.Now().Before(c.tokenExpiresAt) {
+		return c.token, nil
+	}
+
+	var challenge aggregator.ChallengeResult
+	err := c.send(aggregator.MethodAuthChallenge, struct{}{}, &challenge, "")
+	if err != nil {
+		return "", fmt.Errorf("can not get login challenge: %v", err)
+	}
+	pubkey, signature, err := c.auth.Sign(aggregator.LoginMessage(challenge.Nonce))
+	if err != nil {
+		return "", fmt.Errorf("can not sign login challenge: %v", err)
+	}
+	var login aggregator.LoginResult
+	err = c.send(aggregator.MethodAuthLogin, aggregator.LoginParams{
+		Nonce:     challenge.Nonce,
+		Scheme:    c.auth.Scheme,
+		Pubkey:    pubkey,
+		Address:   c.auth.Address,
+		Signature: signature,
+	}, &login, "")
+	if err != nil {
+		return "", fmt.Errorf("can not log in to aggregator: %v", err)
+	}
+	c.token = login.Token
+	c.tokenExpiresAt = time.Unix(login.ExpiresAt, 0)
+	return c.token, nil
+}
+
+func (c *AggregatorRpcClient) send(method string, params interface{}, result interface{}, token string) error {
+	paramsBz, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	reqBz, err := json.Marshal(aggregator.RpcRequest{
+		JsonRpc: "2.0",
+		Id:      json.RawMessage("1"),
+		Method:  method,
+		Params:  paramsBz,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(reqBz))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("aggregator answered %s", httpResp.Status)
+	}
+
+	var resp aggregator.RpcResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("can not decode aggregator response: %v", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (c *AggregatorRpcClient) SubmitResponse(signedTaskResponse aggregator.SignedTaskResponse) (aggregator.ResponseStatus, error) {
+	var result aggregator.SubmitResponseResult
+	err := c.call(aggregator.MethodSubmitResponse, aggregator.SubmitResponseParams{
+		TaskId:    signedTaskResponse.TaskId,
+		Pubkey:    signedTaskResponse.Pubkey,
+		Signature: signedTaskResponse.Signature,
+		Response:  signedTaskResponse.Response.String(),
+	}, &result)
+	return result.Status, err
+}
+
+func (c *AggregatorRpcClient) TaskStatus(taskId uint64) (aggregator.TaskStatusResult, error) {
+	var result aggregator.TaskStatusResult
+	err := c.call(aggregator.MethodTaskStatus, aggregator.TaskStatusParams{TaskId: taskId}, &result)
+	return result, err
+}
+
+func (c *AggregatorRpcClient) OperatorSet() (aggregator.OperatorSetResult, error) {
+	var result aggregator.OperatorSetResult
+	err := c.call(aggregator.MethodOperatorSet, struct{}{}, &result)
+	return result, err
+}
+
+func (c *AggregatorRpcClient) SubscribeTasks(cursor uint64, waitSeconds uint64) (aggregator.SubscribeTasksResult, error) {
+	var result aggregator.SubscribeTasksResult
+	err := c.call(aggregator.MethodSubscribeTasks, aggregator.SubscribeTasksParams{
+		Cursor:      cursor,
+		WaitSeconds: waitSeconds,
+	}, &result)
+	return result, err
+}
+
+// UnknownTaskRetries is how many times a response is sent again when the
+// aggregator has not fetched its task yet.
+const UnknownTaskRetries = 5
+
 func (c *AggregatorRpcClient) SendSignedTaskResponseToAggregator(signedTaskResponse aggregator.SignedTaskResponse) {
-	var reply uint8
+	responsesSent.Inc()
+	unknownTaskRetries := 0
 	for retries := 0; retries < MaxRetries; retries++ {
-		err := c.rpcClient.Call("Aggregator.RespondTask", signedTaskResponse, &reply)
+		status, err := c.SubmitResponse(signedTaskResponse)
 		if err != nil {
-			fmt.Println("Received error from aggregator", "err :", err)
-			if errors.Is(err, rpc.ErrShutdown) {
-				fmt.Println("Aggregator is shutdown. Reconnecting...")
-				client, err := rpc.DialHTTP("tcp", c.aggregatorIpPortAddr)
-				if err != nil {
-					fmt.Println("Could not reconnect to aggregator", "err", err)
-					time.Sleep(RetryInterval)
-				} else {
-					c.rpcClient = client
-					fmt.Println("Reconnected to aggregator")
-				}
-			} else {
-				fmt.Println("Received error from aggregator:", err, ". Retrying RespondTask RPC call...")
-				time.Sleep(RetryInterval)
-			}
-		} else {
-			fmt.Println("Signed task response header accepted by aggregator.", "reply", reply)
+			// rate limited and busy aggregators are retried here as well
+			fmt.Println("Received error from aggregator:", err, ". Retrying submitResponse call...")
+			time.Sleep(RetryInterval)
+			continue
+		}
+		if status == aggregator.ResponseUnknownTask && unknownTaskRetries < UnknownTaskRetries {
+			unknownTaskRetries++
+			fmt.Println("Aggregator does not know the task yet. Retrying submitResponse call...")
+			time.Sleep(RetryInterval)
+			continue
+		}
+		if status != aggregator.ResponseAccepted {
+			responsesRejected.WithLabelValues(string(status)).Inc()
+			fmt.Println("Aggregator did not count the signed task response, not retrying", "status", status)
 			return
 		}
+		responsesAccepted.Inc()
+		fmt.Println("Signed task response accepted by aggregator.")
+		return
+	}
+	responsesRejected.WithLabelValues("error").Inc()
+}
+
+func newAggregatorAuth(scheme string, account *aptos.Account, blsSigner signer.BlsSigner) (*AggregatorAuth, error) {
+	switch scheme {
+	case "":
+		return nil, nil
+	case aggregator.AuthSchemeBls:
+		return &AggregatorAuth{
+			Scheme: scheme,
+			Sign: func(message []byte) ([]byte, []byte, error) {
+				signature, err := blsSigner.Sign(message)
+				if err != nil {
+					return nil, nil, err
+				}
+				return blsSigner.PublicKey(), signature, nil
+			},
+		}, nil
+	case aggregator.AuthSchemeEd25519:
+		return &AggregatorAuth{
+			Scheme:  scheme,
+			Address: account.Address.String(),
+			Sign: func(message []byte) ([]byte, []byte, error) {
+				signature, err := account.Sign(message)
+				if err != nil {
+					return nil, nil, err
+				}
+				return signature.PubKey().Bytes(), signature.Signature().Bytes(), nil
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown aggregator auth %q, choose %s or %s", scheme, aggregator.AuthSchemeBls, aggregator.AuthSchemeEd25519)
 	}
 }
@@ -0,0 +1,34 @@
+package operator
+
+import "testing"
+
+func TestEndpointUrl(t *testing.T) {
+	tests := []struct {
+		address string
+		secure  bool
+		want    string
+	}{
+		{"127.0.0.1:8090", false, "http://127.0.0.1:8090"},
+		{"127.0.0.1:8090", true, "https://127.0.0.1:8090"},
+		{"http://aggregator.example.com:8090", false, "http://aggregator.example.com:8090"},
+		{"http://aggregator.example.com:8090", true, "https://aggregator.example.com:8090"},
+		{"https://aggregator.example.com/", true, "https://aggregator.example.com/"},
+		{"https://aggregator.example.com", false, "https://aggregator.example.com"},
+	}
+	for _, test := range tests {
+		endpoint, err := endpointUrl(test.address, test.secure)
+		if err != nil {
+			t.Errorf("endpointUrl(%q, %t): %v", test.address, test.secure, err)
+			continue
+		}
+		if endpoint.String() != test.want {
+			t.Errorf("endpointUrl(%q, %t) = %s, want %s", test.address, test.secure, endpoint, test.want)
+		}
+	}
+
+	for _, address := range []string{"ftp://aggregator.example.com", "http://", "http://[::1"} {
+		if _, err := endpointUrl(address, false); err == nil {
+			t.Errorf("endpointUrl(%q) should fail", address)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const BinanceEndpoint = "https://api.binance.com"
+
+type BinancePriceSource struct {
+	client   *http.Client
+	endpoint string
+	symbols  map[string]string
+}
+
+func NewBinancePriceSource(client *http.Client, config PriceSourceConfig) *BinancePriceSource {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = BinanceEndpoint
+	}
+	return &BinancePriceSource{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		symbols:  config.Symbols,
+	}
+}
+
+func (s *BinancePriceSource) Name() string {
+	return PriceSourceBinance
+}
+
+// Price quotes the symbol against USDT unless Symbols maps it to another pair.
+func (s *BinancePriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	pair, ok := symbolFor(s.symbols, symbol)
+	if !ok {
+		pair = strings.ToUpper(symbol) + "USDT"
+	}
+
+	q := url.Values{}
+	q.Add("symbol", pair)
+
+	var res struct {
+		Symbol    string `json:"symbol"`
+		LastPrice string `json:"lastPrice"`
+		CloseTime int64  `json:"closeTime"`
+	}
+	err := getJSON(ctx, s.client, s.endpoint+"/api/v3/ticker/24hr?"+q.Encode(), nil, &res)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	price, err := strconv.ParseFloat(res.LastPrice, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("error parsing binance price %q: %v", res.LastPrice, err)
+	}
+	return price, time.UnixMilli(res.CloseTime), nil
+}
@@ -1,51 +1,79 @@
 package operator
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
+	"strings"
+	"time"
 )
 
-const API_KEY = "1547eae6-c29f-464a-ac29-e27fe94db841"
+const CMCEndpoint = "https://pro-api.coinmarketcap.com"
 
-func getCMCPrice(symbol string) float64 {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest", nil)
-	if err != nil {
-		log.Print(err)
-		os.Exit(1)
+type CMCPriceSource struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+type cmcQuotesResponse struct {
+	Status struct {
+		ErrorCode    int    `json:"error_code"`
+		ErrorMessage string `json:"error_message"`
+	} `json:"status"`
+	Data map[string][]struct {
+		Quote map[string]struct {
+			Price       float64   `json:"price"`
+			LastUpdated time.Time `json:"last_updated"`
+		} `json:"quote"`
+	} `json:"data"`
+}
+
+func NewCMCPriceSource(client *http.Client, config PriceSourceConfig) (*CMCPriceSource, error) {
+	if config.ApiKey == "" {
+		return nil, fmt.Errorf("the %s price source needs an ApiKey", PriceSourceCoinMarketCap)
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = CMCEndpoint
 	}
+	return &CMCPriceSource{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   config.ApiKey,
+	}, nil
+}
+
+func (s *CMCPriceSource) Name() string {
+	return PriceSourceCoinMarketCap
+}
+
+func (s *CMCPriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	symbol = strings.ToUpper(symbol)
 
 	q := url.Values{}
 	q.Add("symbol", symbol)
 	q.Add("convert", "USD")
 
-	req.Header.Set("Accepts", "application/json")
-	req.Header.Add("X-CMC_PRO_API_KEY", API_KEY)
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := client.Do(req)
+	var res cmcQuotesResponse
+	err := getJSON(ctx, s.client, s.endpoint+"/v2/cryptocurrency/quotes/latest?"+q.Encode(), map[string]string{
+		"X-CMC_PRO_API_KEY": s.apiKey,
+	}, &res)
 	if err != nil {
-		fmt.Println("Error sending request to server")
-		os.Exit(1)
+		return 0, time.Time{}, err
 	}
-	fmt.Println(resp.Status)
-	respBody, _ := io.ReadAll(resp.Body)
-	var res map[string]interface{}
-	err = json.Unmarshal(respBody, &res)
-	if err != nil {
-		fmt.Println("Error unmarshal price: ", err)
-		os.Exit(1)
+	if res.Status.ErrorCode != 0 {
+		return 0, time.Time{}, fmt.Errorf("coinmarketcap error %d: %s", res.Status.ErrorCode, res.Status.ErrorMessage)
 	}
-	fmt.Println("data: ", res)
 
-	data := res["data"].(map[string]interface{})
-	symbolData := data[symbol].([]interface{})
-
-	fmt.Println("Price of ", symbol, ":", symbolData[0].(map[string]interface{})["quote"].(map[string]interface{})["USD"].(map[string]interface{})["price"])
-	return symbolData[0].(map[string]interface{})["quote"].(map[string]interface{})["USD"].(map[string]interface{})["price"].(float64)
+	symbolData := res.Data[symbol]
+	if len(symbolData) == 0 {
+		return 0, time.Time{}, fmt.Errorf("coinmarketcap has no data for %s", symbol)
+	}
+	quote, ok := symbolData[0].Quote["USD"]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("coinmarketcap has no USD quote for %s", symbol)
+	}
+	return quote.Price, quote.LastUpdated, nil
 }
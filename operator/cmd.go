@@ -1,12 +1,13 @@
 package operator
 
 import (
+	"avs/keystore"
 	"context"
-	"encoding/json"
 	"fmt"
 	"math/big"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -32,6 +33,8 @@ func OperatorCommand(zLogger *zap.Logger) *cobra.Command {
 		},
 	}
 
+	operatorCmd.PersistentFlags().String(flagConfig, "", "unified YAML config of the operator, replaces the JSON config and the network and account flags")
+
 	// Add operator-specific subcommands here
 	operatorCmd.AddCommand(
 		Start(zLogger),                // Example: 'operator start'
@@ -39,6 +42,11 @@ func OperatorCommand(zLogger *zap.Logger) *cobra.Command {
 		Deregister(zLogger),           // Example: 'operator deregister'
 		InitializeQuorum(zLogger),     // Example: 'operator initialize-quorum'
 		QueryPrice(zLogger),           // Example: 'operator price')
+		ShowStatus(zLogger),           // Example: 'operator status --output json'
+		Register(zLogger),             // Example: 'operator register --quorums 1,3'
+		Keys(zLogger),                 // Example: 'operator keys show-pubkey'
+		SlashingProtection(zLogger),   // Example: 'operator slashing-protection export backup.json'
+		RotateBlsKey(zLogger),         // Example: 'operator rotate-bls-key --new-account-profile operator-2 --dry-run'
 	)
 
 	return operatorCmd
@@ -50,8 +58,36 @@ func QueryPrice(logger *zap.Logger) *cobra.Command {
 		Short: "price",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			symbol := args[0]
-			getCMCPrice(symbol)
+			configPath, err := cmd.Flags().GetString(flagConfig)
+			if err != nil {
+				return errors.Wrap(err, flagConfig)
+			}
+
+			var priceSourceConfigs []PriceSourceConfig
+			nodeConfig, err := loadNodeConfig(cmd)
+			switch {
+			case err != nil && configPath != "":
+				return err
+			case err != nil:
+				logger.Warn("Can not load operator config, using default price sources", zap.Error(err))
+			default:
+				priceSourceConfigs = nodeConfig.Operator.PriceSources
+			}
+
+			priceSources, err := NewPriceSources(priceSourceConfigs)
+			if err != nil {
+				return fmt.Errorf("can not create price sources: %s", err)
+			}
+
+			symbol := strings.ToUpper(args[0])
+			for _, source := range priceSources {
+				price, updatedAt, err := source.Price(cmd.Context(), symbol)
+				if err != nil {
+					fmt.Printf("%s: error: %s\n", source.Name(), err)
+					continue
+				}
+				fmt.Printf("%s: %s = %f (updated at %s)\n", source.Name(), symbol, price, updatedAt.Format(time.RFC3339))
+			}
 			return nil
 		},
 	}
@@ -69,10 +105,13 @@ func CreateOperatorConfig(logger *zap.Logger) *cobra.Command {
 			if err != nil {
 				return errors.Wrap(err, flagAvsOperatorConfig)
 			}
-
-			privKey, err := crypto.GenerateBlsPrivateKey()
+			keystorePath, err := cmd.Flags().GetString(flagBlsKeystore)
 			if err != nil {
-				return fmt.Errorf("unable to generate bls keys: %s", err)
+				return errors.Wrap(err, flagBlsKeystore)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
 			}
 
 			avsAddress := aptos.AccountAddress{}
@@ -80,30 +119,26 @@ func CreateOperatorConfig(logger *zap.Logger) *cobra.Command {
 				return fmt.Errorf("failed to parse avs address: %s", err)
 			}
 
+			privKey, err := crypto.GenerateBlsPrivateKey()
+			if err != nil {
+				return fmt.Errorf("unable to generate bls keys: %s", err)
+			}
+			if err := writeBlsKeystore(keystorePath, privKey.Inner.Marshal(), passwordFile, keystore.KdfScrypt); err != nil {
+				return err
+			}
+
 			portAddr := args[1]
 			operatorConfig := OperatorConfig{
-				BlsPrivateKey:        privKey.Inner.Marshal(),
+				BlsKeystore:          keystorePath,
 				AvsAddress:           avsAddress.String(),
 				AggregatorIpPortAddr: portAddr,
 			}
-			bz, err := json.Marshal(operatorConfig)
-			if err != nil {
-				return fmt.Errorf("failed to marshal operator config: %s", err)
-			}
-
-			f, err := os.Create(operatorConfigPath)
-			if err != nil {
-				return fmt.Errorf("failed to create file at %s: %s", operatorConfigPath, err)
-			}
-			_, err = f.WriteString(string(bz))
-			if err != nil {
-				return fmt.Errorf("failed to write to file at %s: %s", operatorConfigPath, err)
-			}
-
-			return nil
+			return saveOperatorConfig(operatorConfigPath, operatorConfig)
 		},
 	}
 	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().String(flagBlsKeystore, defaultBlsKeystore, "where the encrypted BLS key is written")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the BLS keystore password (default $AVS_BLS_PASSWORD or a prompt)")
 	return cmd
 }
 
@@ -113,31 +148,11 @@ func InitializeQuorum(logger *zap.Logger) *cobra.Command {
 		Short: "initialize-quorum",
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			aptosPath, err := cmd.Flags().GetString(flagAptosConfigPath)
+			nodeConfig, err := loadNodeConfig(cmd)
 			if err != nil {
-				return errors.Wrap(err, flagAptosConfigPath)
-			}
-			accountProfile, err := cmd.Flags().GetString(flagAccountProfile)
-			if err != nil {
-				return errors.Wrap(err, flagAccountProfile)
-			}
-			network, err := cmd.Flags().GetString(flagAptosNetwork)
-			if err != nil {
-				return errors.Wrap(err, flagAptosNetwork)
-			}
-			operatorConfigPath, err := cmd.Flags().GetString(flagAvsOperatorConfig)
-			if err != nil {
-				return errors.Wrap(err, flagAvsOperatorConfig)
-			}
-
-			networkConfig, err := extractNetwork(network)
-			if err != nil {
-				return fmt.Errorf("wrong config: %s", err)
-			}
-			operatorConfig, err := loadOperatorConfig(operatorConfigPath)
-			if err != nil {
-				return fmt.Errorf("can not load operator config: %s", err)
+				return err
 			}
+			operatorConfig := &nodeConfig.Operator
 
 			maxOperatorCount, err := strconv.ParseUint(args[0], 10, 32)
 			if err != nil {
@@ -151,12 +166,9 @@ func InitializeQuorum(logger *zap.Logger) *cobra.Command {
 			}
 
 			err = InitQuorum(
-				networkConfig,
+				nodeConfig.Network,
 				*operatorConfig,
-				AptosAccountConfig{
-					configPath: aptosPath,
-					profile:    accountProfile,
-				},
+				nodeConfig.Account,
 				uint32(maxOperatorCount),
 				*minimumStake,
 			)
@@ -176,31 +188,11 @@ func Deregister(logger *zap.Logger) *cobra.Command {
 		Short: "deregister",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			aptosPath, err := cmd.Flags().GetString(flagAptosConfigPath)
-			if err != nil {
-				return errors.Wrap(err, flagAptosConfigPath)
-			}
-			accountProfile, err := cmd.Flags().GetString(flagAccountProfile)
+			nodeConfig, err := loadNodeConfig(cmd)
 			if err != nil {
-				return errors.Wrap(err, flagAccountProfile)
-			}
-			network, err := cmd.Flags().GetString(flagAptosNetwork)
-			if err != nil {
-				return errors.Wrap(err, flagAptosNetwork)
-			}
-			operatorConfigPath, err := cmd.Flags().GetString(flagAvsOperatorConfig)
-			if err != nil {
-				return errors.Wrap(err, flagAvsOperatorConfig)
-			}
-
-			networkConfig, err := extractNetwork(network)
-			if err != nil {
-				return fmt.Errorf("wrong config: %s", err)
-			}
-			operatorConfig, err := loadOperatorConfig(operatorConfigPath)
-			if err != nil {
-				return fmt.Errorf("can not load operator config: %s", err)
+				return err
 			}
+			operatorConfig := &nodeConfig.Operator
 
 			quorum64, err := strconv.ParseUint(args[0], 10, 64) // base 10 and 64-bit size
 			if err != nil {
@@ -209,11 +201,11 @@ func Deregister(logger *zap.Logger) *cobra.Command {
 
 			quorum := uint8(quorum64)
 
-			operatorAccount, err := SignerFromConfig(aptosPath, accountProfile)
+			operatorAccount, err := LoadAccount(*operatorConfig, nodeConfig.Account)
 			if err != nil {
 				panic("Failed to create operator account:" + err.Error())
 			}
-			err = DeregisterFromQuorum(logger, networkConfig, *operatorConfig, operatorAccount, quorum)
+			err = DeregisterFromQuorum(logger, nodeConfig.Network, *operatorConfig, operatorAccount, quorum)
 			if err != nil {
 				panic("Failed to create deregistor operator :" + err.Error())
 			}
@@ -234,42 +226,42 @@ func Start(logger *zap.Logger) *cobra.Command {
 		Short: "start",
 		Args:  cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Get all the flags
-			aptosPath, err := cmd.Flags().GetString(flagAptosConfigPath)
+			nodeConfig, err := loadNodeConfig(cmd)
 			if err != nil {
-				return errors.Wrap(err, flagAptosConfigPath)
+				return err
 			}
-			accountProfile, err := cmd.Flags().GetString(flagAccountProfile)
+			operatorConfig := &nodeConfig.Operator
+			autoRegister, err := cmd.Flags().GetBool(flagAutoRegister)
 			if err != nil {
-				return errors.Wrap(err, flagAccountProfile)
+				return errors.Wrap(err, flagAutoRegister)
 			}
-			network, err := cmd.Flags().GetString(flagAptosNetwork)
+			quorumsFlag, err := cmd.Flags().GetString(flagQuorums)
 			if err != nil {
-				return errors.Wrap(err, flagAptosNetwork)
+				return errors.Wrap(err, flagQuorums)
 			}
-			operatorConfigPath, err := cmd.Flags().GetString(flagAvsOperatorConfig)
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
 			if err != nil {
-				return errors.Wrap(err, flagAvsOperatorConfig)
+				return errors.Wrap(err, flagBlsPasswordFile)
 			}
-
-			networkConfig, err := extractNetwork(network)
+			blsSigner, err := LoadBlsSigner(logger, *operatorConfig, passwordFile)
 			if err != nil {
-				return fmt.Errorf("wrong config: %s", err)
+				return err
 			}
-			operatorConfig, err := loadOperatorConfig(operatorConfigPath)
-			if err != nil {
-				return fmt.Errorf("can not load operator config: %s", err)
+			var autoRegisterQuorums []uint8
+			if autoRegister {
+				autoRegisterQuorums, err = autoRegisterQuorumList(nodeConfig.Network, operatorConfig.AvsAddress, quorumsFlag)
+				if err != nil {
+					return err
+				}
 			}
 
 			operator, err := NewOperator(
 				logger,
-				networkConfig,
+				nodeConfig.Network,
 				*operatorConfig,
-				AptosAccountConfig{
-					configPath: aptosPath,
-					profile:    accountProfile,
-				},
-				operatorConfig.BlsPrivateKey,
+				nodeConfig.Account,
+				blsSigner,
+				autoRegisterQuorums,
 			)
 			if err != nil {
 				return fmt.Errorf("can not create new operator: %s", err)
@@ -285,5 +277,8 @@ func Start(logger *zap.Logger) *cobra.Command {
 	cmd.Flags().String(flagAccountProfile, "default", "the account profile to use")
 	cmd.Flags().String(flagAptosNetwork, "devnet", "choose network to connect to: mainnet, testnet, devnet, localnet")
 	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().Bool(flagAutoRegister, false, "register the operator when it is not registered yet")
+	cmd.Flags().String(flagQuorums, "", "the quorum --auto-register registers in (default the highest quorum)")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the BLS keystore password (default $AVS_BLS_PASSWORD or a prompt)")
 	return cmd
 }
@@ -0,0 +1,87 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const CoinGeckoEndpoint = "https://api.coingecko.com/api/v3"
+
+// coinGeckoIds covers the symbols tasks usually request, Symbols in the
+// config can add to or override them.
+var coinGeckoIds = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"APT":  "aptos",
+	"SOL":  "solana",
+	"BNB":  "binancecoin",
+	"USDT": "tether",
+	"USDC": "usd-coin",
+}
+
+type CoinGeckoPriceSource struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	symbols  map[string]string
+}
+
+func NewCoinGeckoPriceSource(client *http.Client, config PriceSourceConfig) *CoinGeckoPriceSource {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = CoinGeckoEndpoint
+	}
+	symbols := make(map[string]string, len(coinGeckoIds)+len(config.Symbols))
+	for symbol, id := range coinGeckoIds {
+		symbols[symbol] = id
+	}
+	for symbol, id := range config.Symbols {
+		symbols[strings.ToUpper(symbol)] = id
+	}
+	return &CoinGeckoPriceSource{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   config.ApiKey,
+		symbols:  symbols,
+	}
+}
+
+func (s *CoinGeckoPriceSource) Name() string {
+	return PriceSourceCoinGecko
+}
+
+func (s *CoinGeckoPriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	id, ok := symbolFor(s.symbols, symbol)
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("no coingecko id configured for %s", symbol)
+	}
+
+	q := url.Values{}
+	q.Add("ids", id)
+	q.Add("vs_currencies", "usd")
+	q.Add("include_last_updated_at", "true")
+
+	headers := map[string]string{}
+	if s.apiKey != "" {
+		headers["x-cg-demo-api-key"] = s.apiKey
+	}
+
+	var res map[string]struct {
+		Usd           float64 `json:"usd"`
+		LastUpdatedAt int64   `json:"last_updated_at"`
+	}
+	err := getJSON(ctx, s.client, s.endpoint+"/simple/price?"+q.Encode(), headers, &res)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	quote, ok := res[id]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("coingecko has no data for %s", id)
+	}
+	return quote.Usd, time.Unix(quote.LastUpdatedAt, 0), nil
+}
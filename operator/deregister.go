@@ -1,6 +1,7 @@
 package operator
 
 import (
+	"avs/avsconfig"
 	"fmt"
 
 	aptos "github.com/aptos-labs/aptos-go-sdk"
@@ -9,7 +10,7 @@ import (
 )
 
 func DeregisterFromQuorum(logger *zap.Logger, networkConfig aptos.NetworkConfig, config OperatorConfig, operatorAccount *aptos.Account, quorum uint8) error {
-	client, err := aptos.NewClient(networkConfig)
+	client, err := avsconfig.NewClient(networkConfig)
 	if err != nil {
 		panic("Failed to create client:" + err.Error())
 	}
@@ -21,27 +22,9 @@ func DeregisterFromQuorum(logger *zap.Logger, networkConfig aptos.NetworkConfig,
 	}
 
 
-	quorumSerializer := &bcs.Serializer{}
-	bcs.SerializeSequence([]U8Struct{
-		{
-			Value: quorum,
-		},
-	}, quorumSerializer)
-
-	payload := aptos.EntryFunction{
-		Module: aptos.ModuleId{
-			Address: contract,
-			Name:    "registry_coordinator",
-		},
-		Function: "deregister_operator",
-		ArgTypes: []aptos.TypeTag{},
-		Args: [][]byte{
-			quorumSerializer.ToBytes(),
-		},
-	}
+	payload := DeregisterOperatorPayload(contract, []uint8{quorum})
 	// Build transaction
-	rawTxn, err := client.BuildTransaction(operatorAccount.AccountAddress(),
-		aptos.TransactionPayload{Payload: &payload})
+	rawTxn, err := client.BuildTransaction(operatorAccount.AccountAddress(), payload)
 	if err != nil {
 		panic("Failed to build transaction:" + err.Error())
 	}
@@ -73,3 +56,26 @@ func DeregisterFromQuorum(logger *zap.Logger, networkConfig aptos.NetworkConfig,
 	}
 	return nil
 }
+
+// DeregisterOperatorPayload removes the sender from quorumNumbers, which must
+// be in ascending order.
+func DeregisterOperatorPayload(contract aptos.AccountAddress, quorumNumbers []uint8) aptos.TransactionPayload {
+	quorums := make([]U8Struct, 0, len(quorumNumbers))
+	for _, quorum := range quorumNumbers {
+		quorums = append(quorums, U8Struct{Value: quorum})
+	}
+	quorumSerializer := &bcs.Serializer{}
+	bcs.SerializeSequence(quorums, quorumSerializer)
+
+	return aptos.TransactionPayload{Payload: &aptos.EntryFunction{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "registry_coordinator",
+		},
+		Function: "deregister_operator",
+		ArgTypes: []aptos.TypeTag{},
+		Args: [][]byte{
+			quorumSerializer.ToBytes(),
+		},
+	}}
+}
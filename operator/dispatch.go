@@ -0,0 +1,125 @@
+package operator
+
+import (
+	"avs/aggregator"
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// PushPollInterval is how often the chain is still polled while tasks
+	// are pushed by the aggregator, to catch the ones it missed.
+	PushPollInterval     = 1 * time.Minute
+	SubscribeWaitSeconds = 30
+	SeenTasksSize        = 10000
+)
+
+// SubscribeTasks long polls the aggregator for the tasks it observes. While
+// the aggregator can not be reached FetchTasks polls the chain at its usual
+// interval.
+func (op *Operator) SubscribeTasks(ctx context.Context) error {
+	var cursor uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		batch, err := op.AggRpcClient.SubscribeTasks(cursor, SubscribeWaitSeconds)
+		if err != nil {
+			if op.pushConnected.Load() {
+				op.logger.Warn("Task subscription to aggregator lost, polling the chain", zap.Error(err))
+			}
+			op.setPushConnected(false)
+			time.Sleep(RetryInterval)
+			continue
+		}
+		op.setPushConnected(true)
+		cursor = batch.Cursor
+
+		// a pushed task is only a hint, the task answered is read from the
+		// chain
+		tasks := make([]aggregator.Task, 0, len(batch.Tasks))
+		for _, task := range batch.Tasks {
+			if op.seenTasks.has(task.Id) {
+				continue
+			}
+			state, err := aggregator.LoadTaskById(op.client, op.avsAddress, task.Id)
+			if err != nil {
+				// FetchTasks picks it up from the chain later
+				op.logger.Warn("Can not load pushed task from chain", zap.Uint64("task id", task.Id), zap.Error(err))
+				continue
+			}
+			tasks = append(tasks, aggregator.Task{Id: task.Id, Task: state})
+		}
+		op.QueueTasks(tasks)
+	}
+}
+
+func (op *Operator) setPushConnected(connected bool) {
+	if op.pushConnected.Swap(connected) != connected {
+		op.logger.Info("Aggregator task push", zap.Bool("connected", connected))
+	}
+}
+
+// pollInterval is the chain polling interval of FetchTasks.
+func (op *Operator) pollInterval() time.Duration {
+	if op.pushConnected.Load() {
+		return PushPollInterval
+	}
+	return PollLatestBatchInterval
+}
+
+// seenTasks remembers the ids of the last SeenTasksSize tasks queued, so a task
+// arriving from both the aggregator and the chain is answered once.
+type seenTasks struct {
+	mu    sync.Mutex
+	ids   map[uint64]struct{}
+	order []uint64
+}
+
+func newSeenTasks() *seenTasks {
+	return &seenTasks{
+		ids: make(map[uint64]struct{}),
+	}
+}
+
+// add returns false when the task was already seen.
+func (s *seenTasks) add(taskId uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[taskId]; ok {
+		return false
+	}
+	s.ids[taskId] = struct{}{}
+	s.order = append(s.order, taskId)
+	if len(s.order) > SeenTasksSize {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+	return true
+}
+
+func (s *seenTasks) has(taskId uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.ids[taskId]
+	return ok
+}
+
+// recent returns up to n of the last tasks seen, newest first.
+func (s *seenTasks) recent(n int) []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taskIds := make([]uint64, 0, n)
+	for i := len(s.order) - 1; i >= 0 && len(taskIds) < n; i-- {
+		taskIds = append(taskIds, s.order[i])
+	}
+	return taskIds
+}
@@ -1,6 +1,7 @@
 package operator
 
 import (
+	"avs/metrics"
 	"encoding/json"
 	"fmt"
 
@@ -20,7 +21,7 @@ func GetMetadata(
 	}
 
 	var noTypeTags []aptos.TypeTag
-	viewResponse, err := client.View(&aptos.ViewPayload{
+	viewResponse, err := metrics.View(client, &aptos.ViewPayload{
 		Module: aptos.ModuleId{
 			Address: contractAcc,
 			Name:    "fungible_asset",
@@ -65,7 +66,7 @@ func FAMetdataClient(
 	}
 
 	var noTypeTags []aptos.TypeTag
-	viewResponse, err := client.View(&aptos.ViewPayload{
+	viewResponse, err := metrics.View(client, &aptos.ViewPayload{
 		Module: aptos.ModuleId{
 			Address: contractAcc,
 			Name:    "fungible_asset",
@@ -0,0 +1,314 @@
+package operator
+
+import (
+	"avs/keystore"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+const (
+	flagBlsKeystore     = "bls-keystore"
+	flagBlsPasswordFile = "bls-password-file"
+	flagKdf             = "kdf"
+	flagKeyFile         = "key-file"
+
+	defaultBlsKeystore = "config/bls-keystore.json"
+)
+
+func Keys(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "manage the encrypted BLS key of the operator",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		createKey(logger),
+		importKey(logger),
+		exportKey(logger),
+		showPubkey(logger),
+	)
+	return cmd
+}
+
+func createKey(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "generate a BLS key into a new keystore",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keystorePath, err := cmd.Flags().GetString(flagBlsKeystore)
+			if err != nil {
+				return errors.Wrap(err, flagBlsKeystore)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+			kdf, err := cmd.Flags().GetString(flagKdf)
+			if err != nil {
+				return errors.Wrap(err, flagKdf)
+			}
+
+			privKey, err := crypto.GenerateBlsPrivateKey()
+			if err != nil {
+				return fmt.Errorf("unable to generate bls keys: %s", err)
+			}
+			return writeBlsKeystore(keystorePath, privKey.Inner.Marshal(), passwordFile, kdf)
+		},
+	}
+	cmd.Flags().String(flagBlsKeystore, defaultBlsKeystore, "where the keystore is written")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	cmd.Flags().String(flagKdf, keystore.KdfScrypt, "key derivation function: scrypt or pbkdf2")
+	return cmd
+}
+
+func importKey(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "encrypt an existing BLS key, by default the plaintext key of the operator config",
+		Long: "Encrypts the hex or base64 BLS private key read from --key-file (- for stdin) into a " +
+			"keystore; the key is never taken from the command line, where it would end up in the shell " +
+			"history and the process list. Without --key-file the plaintext BlsPrivateKey of the operator " +
+			"config is imported and the config is rewritten to reference the keystore instead.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keystorePath, err := cmd.Flags().GetString(flagBlsKeystore)
+			if err != nil {
+				return errors.Wrap(err, flagBlsKeystore)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+			kdf, err := cmd.Flags().GetString(flagKdf)
+			if err != nil {
+				return errors.Wrap(err, flagKdf)
+			}
+			operatorConfigPath, err := cmd.Flags().GetString(flagAvsOperatorConfig)
+			if err != nil {
+				return errors.Wrap(err, flagAvsOperatorConfig)
+			}
+
+			keyFile, err := cmd.Flags().GetString(flagKeyFile)
+			if err != nil {
+				return errors.Wrap(err, flagKeyFile)
+			}
+
+			if keyFile != "" {
+				encoded, err := readBlsKeyFile(keyFile)
+				if err != nil {
+					return err
+				}
+				privateKey, err := parseBlsPrivateKey(encoded)
+				if err != nil {
+					return err
+				}
+				return writeBlsKeystore(keystorePath, privateKey, passwordFile, kdf)
+			}
+
+			configPath, err := cmd.Flags().GetString(flagConfig)
+			if err != nil {
+				return errors.Wrap(err, flagConfig)
+			}
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			if len(operatorConfig.BlsPrivateKey) == 0 {
+				return fmt.Errorf("the operator config holds no plaintext BLS key, pass the key to import with --%s", flagKeyFile)
+			}
+			if err := writeBlsKeystore(keystorePath, operatorConfig.BlsPrivateKey, passwordFile, kdf); err != nil {
+				return err
+			}
+			// the YAML config is not rewritten, it would lose its comments
+			if configPath != "" {
+				logger.Info("Wrote the BLS key of the config file to its keystore, replace Operator.BlsPrivateKey with Operator.BlsKeystore in it", zap.String("config", configPath), zap.String("keystore", keystorePath))
+				return nil
+			}
+			operatorConfig.BlsKeystore = keystorePath
+			operatorConfig.BlsPrivateKey = nil
+			if err := saveOperatorConfig(operatorConfigPath, *operatorConfig); err != nil {
+				return err
+			}
+			logger.Info("Moved the BLS key of the operator config to its keystore", zap.String("config", operatorConfigPath), zap.String("keystore", keystorePath))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagBlsKeystore, defaultBlsKeystore, "where the keystore is written")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	cmd.Flags().String(flagKdf, keystore.KdfScrypt, "key derivation function: scrypt or pbkdf2")
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().String(flagKeyFile, "", "file holding the hex or base64 private key to import, - reads it from stdin")
+	return cmd
+}
+
+func exportKey(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "print the decrypted BLS private key as hex",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keystorePath, err := cmd.Flags().GetString(flagBlsKeystore)
+			if err != nil {
+				return errors.Wrap(err, flagBlsKeystore)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+
+			privateKey, err := decryptBlsKeystore(keystorePath, passwordFile)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "Anyone holding this key can sign task responses as this operator.")
+			fmt.Printf("0x%s\n", hex.EncodeToString(privateKey))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagBlsKeystore, defaultBlsKeystore, "the keystore to export")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	return cmd
+}
+
+func showPubkey(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show-pubkey",
+		Short: "print the BLS public key of a keystore, no password needed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keystorePath, err := cmd.Flags().GetString(flagBlsKeystore)
+			if err != nil {
+				return errors.Wrap(err, flagBlsKeystore)
+			}
+
+			ks, err := keystore.Load(keystorePath)
+			if err != nil {
+				return fmt.Errorf("can not load keystore: %s", err)
+			}
+			pubkey, err := ks.PubkeyBytes()
+			if err != nil {
+				return fmt.Errorf("invalid keystore pubkey: %s", err)
+			}
+			fmt.Printf("0x%s\n", hex.EncodeToString(pubkey))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagBlsKeystore, defaultBlsKeystore, "the keystore to read")
+	return cmd
+}
+
+// LoadBlsPrivateKey returns the BLS key of the operator from its keystore.
+// Configs written by earlier releases still hold the key in plaintext, those
+// are used with a warning.
+func LoadBlsPrivateKey(logger *zap.Logger, config OperatorConfig, passwordFile string) ([]byte, error) {
+	if config.BlsKeystore != "" {
+		return decryptBlsKeystore(config.BlsKeystore, passwordFile)
+	}
+	if len(config.BlsPrivateKey) != 0 {
+		logger.Warn("The operator config holds the BLS key in plaintext, move it to a keystore with `avs operator keys import`")
+		return config.BlsPrivateKey, nil
+	}
+	return nil, fmt.Errorf("the operator config has no BlsKeystore")
+}
+
+func decryptBlsKeystore(path string, passwordFile string) ([]byte, error) {
+	ks, err := keystore.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("can not load keystore: %v", err)
+	}
+	password, err := keystore.ReadPassword(passwordFile, false)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := ks.Decrypt(password)
+	if err != nil {
+		return nil, fmt.Errorf("can not decrypt keystore %s: %v", path, err)
+	}
+	var priv crypto.BlsPrivateKey
+	if err := priv.FromBytes(privateKey); err != nil {
+		return nil, fmt.Errorf("invalid bls private key in %s: %v", path, err)
+	}
+	return privateKey, nil
+}
+
+// writeBlsKeystore encrypts privateKey into a new keystore at path and prints
+// its public key.
+func writeBlsKeystore(path string, privateKey []byte, passwordFile string, kdf string) error {
+	var priv crypto.BlsPrivateKey
+	if err := priv.FromBytes(privateKey); err != nil {
+		return fmt.Errorf("invalid bls private key: %v", err)
+	}
+	pubkey := priv.Inner.PublicKey().Marshal()
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("keystore %s already exists", path)
+	}
+	password, err := keystore.ReadPassword(passwordFile, true)
+	if err != nil {
+		return err
+	}
+	ks, err := keystore.Encrypt(privateKey, pubkey, password, kdf)
+	if err != nil {
+		return fmt.Errorf("can not encrypt bls key: %v", err)
+	}
+	if err := ks.Save(path); err != nil {
+		return fmt.Errorf("can not write keystore: %v", err)
+	}
+	fmt.Printf("Wrote keystore %s for BLS pubkey 0x%s\n", path, hex.EncodeToString(pubkey))
+	return nil
+}
+
+// readBlsKeyFile reads the key to import from path, or from stdin for "-"
+// without echoing it when stdin is a terminal.
+func readBlsKeyFile(path string) (string, error) {
+	if path != "-" {
+		bz, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("can not read key file: %v", err)
+		}
+		return string(bz), nil
+	}
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "BLS private key: ")
+		bz, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("can not read key: %v", err)
+		}
+		return string(bz), nil
+	}
+	bz, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("can not read key from stdin: %v", err)
+	}
+	return string(bz), nil
+}
+
+// parseBlsPrivateKey accepts the key as 0x hex or as the base64 earlier
+// operator configs stored.
+func parseBlsPrivateKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if bz, err := hex.DecodeString(strings.TrimPrefix(s, "0x")); err == nil {
+		return bz, nil
+	}
+	bz, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("private key must be hex or base64")
+	}
+	return bz, nil
+}
@@ -0,0 +1,36 @@
+package operator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadBlsKeyFile(t *testing.T) {
+	want := bytes.Repeat([]byte{0x2a}, 32)
+	for name, content := range map[string]string{
+		"hex":    "0x2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a2a\n",
+		"base64": "KioqKioqKioqKioqKioqKioqKioqKioqKioqKioqKio=\n",
+	} {
+		path := filepath.Join(t.TempDir(), "key.txt")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := readBlsKeyFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		key, err := parseBlsPrivateKey(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(key, want) {
+			t.Errorf("%s key %x, want %x", name, key, want)
+		}
+	}
+
+	if _, err := readBlsKeyFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("readBlsKeyFile should fail for a missing file")
+	}
+}
@@ -0,0 +1,62 @@
+package operator
+
+import (
+	"avs/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsSubsystem = "operator"
+
+var (
+	tasksSeen = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "tasks_seen_total",
+		Help:      "Tasks received from the chain or the aggregator, including repeats.",
+	})
+	tasksQueued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "tasks_queued_total",
+		Help:      "New tasks queued for a response.",
+	})
+	responsesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_sent_total",
+		Help:      "Signed task responses sent to the aggregator.",
+	})
+	responsesAccepted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_accepted_total",
+		Help:      "Signed task responses the aggregator counted.",
+	})
+	responsesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "responses_rejected_total",
+		Help:      "Signed task responses the aggregator did not count, by reason.",
+	}, []string{"reason"})
+	signaturesRefused = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "signatures_refused_total",
+		Help:      "Task responses slashing protection refused to sign because another response was signed for the task.",
+	})
+	priceSourceSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "price_source_duration_seconds",
+		Help:      "Latency of price source requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source"})
+	priceSourceFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "price_source_failures_total",
+		Help:      "Price source requests that failed or returned an unusable price.",
+	}, []string{"source"})
+)
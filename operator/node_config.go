@@ -0,0 +1,195 @@
+package operator
+
+import (
+	"avs/aggregator"
+	"avs/avsconfig"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const flagConfig = "config"
+
+// NodeConfig is everything an operator command runs with.
+type NodeConfig struct {
+	Network  aptos.NetworkConfig
+	Account  AptosAccountConfig
+	Operator OperatorConfig
+}
+
+// LoadNodeConfig reads and validates a unified config file of the operator
+// role, see avsconfig.File.
+func LoadNodeConfig(path string) (*NodeConfig, error) {
+	var operatorConfig OperatorConfig
+	file, err := avsconfig.Load(path, avsconfig.RoleOperator, &operatorConfig)
+	if err != nil {
+		return nil, err
+	}
+	profile := file.Account.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	config := &NodeConfig{
+		Account: AptosAccountConfig{
+			configPath: file.Account.AptosConfig,
+			profile:    profile,
+		},
+		Operator: operatorConfig,
+	}
+	problems := &avsconfig.ValidationError{}
+	problems.Merge("", file.Network.Validate())
+	problems.Merge("", config.validate("Operator"))
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
+	config.Network, err = file.Network.NetworkConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadNodeConfig loads the unified config file given with --config, or else
+// the JSON operator config and whichever network and account flags cmd has.
+func loadNodeConfig(cmd *cobra.Command) (*NodeConfig, error) {
+	configPath, err := cmd.Flags().GetString(flagConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, flagConfig)
+	}
+	if configPath != "" {
+		for _, flag := range []string{flagAptosNetwork, flagAptosConfigPath, flagAccountProfile, flagAvsOperatorConfig} {
+			if cmd.Flags().Changed(flag) {
+				return nil, fmt.Errorf("--%s can not be combined with --%s, set it in the config file", flag, flagConfig)
+			}
+		}
+		return LoadNodeConfig(configPath)
+	}
+
+	config := &NodeConfig{}
+	if cmd.Flags().Lookup(flagAptosNetwork) != nil {
+		network, err := cmd.Flags().GetString(flagAptosNetwork)
+		if err != nil {
+			return nil, errors.Wrap(err, flagAptosNetwork)
+		}
+		config.Network, err = extractNetwork(network)
+		if err != nil {
+			return nil, fmt.Errorf("wrong config: %s", err)
+		}
+	}
+	if cmd.Flags().Lookup(flagAptosConfigPath) != nil {
+		config.Account.configPath, err = cmd.Flags().GetString(flagAptosConfigPath)
+		if err != nil {
+			return nil, errors.Wrap(err, flagAptosConfigPath)
+		}
+		config.Account.profile, err = cmd.Flags().GetString(flagAccountProfile)
+		if err != nil {
+			return nil, errors.Wrap(err, flagAccountProfile)
+		}
+	}
+	operatorConfigPath, err := cmd.Flags().GetString(flagAvsOperatorConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, flagAvsOperatorConfig)
+	}
+	operatorConfig, err := loadOperatorConfig(operatorConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("can not load operator config: %s", err)
+	}
+	config.Operator = *operatorConfig
+	if err := config.validate(""); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *NodeConfig) validate(section string) error {
+	problems := &avsconfig.ValidationError{}
+	problems.Merge(section, c.Operator.Validate())
+	remoteAccount := c.Operator.RemoteSigner != nil && c.Operator.RemoteSigner.Ed25519Pubkey != ""
+	if c.Account.configPath != "" && !remoteAccount {
+		if err := avsconfig.CheckAptosProfile(c.Account.configPath, c.Account.profile); err != nil {
+			problems.Add("Account", "%v", err)
+		}
+	}
+	return problems.Err()
+}
+
+// Validate checks everything in the config that can be checked without the
+// chain, so a broken config fails at startup.
+func (c OperatorConfig) Validate() error {
+	problems := &avsconfig.ValidationError{}
+	if c.AvsAddress == "" {
+		problems.Add("AvsAddress", "is required")
+	} else if err := (&aptos.AccountAddress{}).ParseStringRelaxed(c.AvsAddress); err != nil {
+		problems.Add("AvsAddress", "invalid address %q: %v", c.AvsAddress, err)
+	}
+	if c.AggregatorIpPortAddr == "" {
+		problems.Add("AggregatorIpPortAddr", "is required")
+	}
+
+	remoteBls := c.RemoteSigner != nil && c.RemoteSigner.BlsPubkey != ""
+	switch {
+	case remoteBls:
+	case c.BlsKeystore != "":
+		if _, err := os.Stat(c.BlsKeystore); err != nil {
+			problems.Add("BlsKeystore", "%v", err)
+		}
+	case len(c.BlsPrivateKey) != 0:
+	default:
+		problems.Add("BlsKeystore", "is required unless RemoteSigner.BlsPubkey is set")
+	}
+	if c.RemoteSigner != nil {
+		if c.RemoteSigner.Url == "" {
+			problems.Add("RemoteSigner.Url", "is required")
+		}
+		if _, err := hex.DecodeString(strings.TrimPrefix(c.RemoteSigner.BlsPubkey, "0x")); err != nil {
+			problems.Add("RemoteSigner.BlsPubkey", "invalid hex key %q", c.RemoteSigner.BlsPubkey)
+		}
+		if _, err := hex.DecodeString(strings.TrimPrefix(c.RemoteSigner.Ed25519Pubkey, "0x")); err != nil {
+			problems.Add("RemoteSigner.Ed25519Pubkey", "invalid hex key %q", c.RemoteSigner.Ed25519Pubkey)
+		}
+		if c.RemoteSigner.Tls != nil {
+			if _, err := c.RemoteSigner.Tls.clientConfig(); err != nil {
+				problems.Add("RemoteSigner.Tls", "%v", err)
+			}
+		}
+	}
+	if c.AggregatorTls != nil {
+		if _, err := c.AggregatorTls.clientConfig(); err != nil {
+			problems.Add("AggregatorTls", "%v", err)
+		}
+	}
+	switch c.AggregatorAuth {
+	case "", aggregator.AuthSchemeBls, aggregator.AuthSchemeEd25519:
+	default:
+		problems.Add("AggregatorAuth", "unknown scheme %q, choose %s, %s or leave it empty", c.AggregatorAuth, aggregator.AuthSchemeBls, aggregator.AuthSchemeEd25519)
+	}
+	switch aggregator.TaskSourceMode(c.TaskSource) {
+	case aggregator.TaskSourcePolling, aggregator.TaskSourceEvents:
+	default:
+		problems.Add("TaskSource", "unknown task source %q, choose one of: %s, %s", c.TaskSource, aggregator.TaskSourcePolling, aggregator.TaskSourceEvents)
+	}
+
+	for i, source := range c.PriceSources {
+		if _, err := NewPriceSource(source); err != nil {
+			problems.Add(fmt.Sprintf("PriceSources[%d]", i), "%v", err)
+		}
+	}
+	sources := len(c.PriceSources)
+	if sources == 0 {
+		sources = len(DefaultPriceSources)
+	}
+	if c.PriceAggregation.MinSources < 0 {
+		problems.Add("PriceAggregation.MinSources", "can not be negative")
+	} else if c.PriceAggregation.MinSources > sources {
+		problems.Add("PriceAggregation.MinSources", "is %d but only %d price sources are configured", c.PriceAggregation.MinSources, sources)
+	}
+	if c.PriceAggregation.MaxDeviationPercent < 0 {
+		problems.Add("PriceAggregation.MaxDeviationPercent", "can not be negative")
+	}
+	return problems.Err()
+}
@@ -2,6 +2,10 @@ package operator
 
 import (
 	"avs/aggregator"
+	"avs/avsconfig"
+	"avs/metrics"
+	"avs/msghash"
+	"avs/slashing"
 	"context"
 	"encoding/hex"
 	"fmt"
@@ -15,7 +19,6 @@ import (
 
 	aptos "github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
-	"github.com/aptos-labs/aptos-go-sdk/crypto"
 	"go.uber.org/zap"
 )
 
@@ -33,6 +36,23 @@ func (op *Operator) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
+	if op.metricsAddress != "" {
+		go func() {
+			err := metrics.Serve(ctx, op.logger, op.metricsAddress)
+			if err != nil {
+				op.logger.Fatal("Error starting metrics server", zap.Any("err", err))
+			}
+		}()
+	}
+	if op.adminAddress != "" {
+		go func() {
+			err := op.ServeAdmin(ctx, op.adminAddress)
+			if err != nil {
+				op.logger.Fatal("Error starting admin server", zap.Any("err", err))
+			}
+		}()
+	}
+
 	// Fetching tasks
 	go func() {
 		op.logger.Info("Fetching tasks process started...")
@@ -42,6 +62,14 @@ func (op *Operator) Start(ctx context.Context) error {
 		}
 	}()
 
+	go func() {
+		op.logger.Info("Task subscription process started...")
+		err := op.SubscribeTasks(ctx)
+		if err != nil {
+			op.logger.Fatal("Error subscribing to tasks", zap.Any("err", err))
+		}
+	}()
+
 	go func() {
 		op.logger.Info("Respond tasks process started...")
 		err := op.RespondTask(ctx)
@@ -62,35 +90,39 @@ func (op *Operator) Start(ctx context.Context) error {
 }
 
 func (op *Operator) FetchTasks(ctx context.Context) error {
-	client, err := aptos.NewClient(op.network)
+	client, err := avsconfig.NewClient(op.network)
 	if err != nil {
 		return fmt.Errorf("failed to create aptos client: %v", err)
 	}
 
-	var taskCount uint64
+	taskSource, err := aggregator.NewTaskSource(op.taskSource, client, op.network.IndexerUrl, op.avsAddress, op.taskStartVersion)
+	if err != nil {
+		return err
+	}
+
 	// looping
 	for {
-		previousTaskCount := taskCount
-		newTaskCount, err := LatestTaskCount(client, op.avsAddress)
-		if err != nil {
-			op.logger.Warn("Failed to subscribe to new tasks", zap.Any("err", err))
-			time.Sleep(RetryInterval)
-			continue
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
 		}
-		taskCount = newTaskCount
 
-		if taskCount > previousTaskCount {
-			err := op.QueueTask(ctx, client, previousTaskCount, taskCount)
-			if err != nil {
-				return fmt.Errorf("error queuing task: %v", err)
-			}
+		tasks, err := taskSource.NewTasks(ctx)
+		if err != nil {
+			op.logger.Warn("Failed to fetch new tasks", zap.Any("err", err))
 		}
+		// a source may return the tasks it read before failing
+		op.QueueTasks(tasks)
+		op.lastFetch.Store(time.Now().Unix())
+
+		time.Sleep(op.pollInterval())
 	}
 }
 
 func (op *Operator) RespondTask(ctx context.Context) error {
 
-	client, err := aptos.NewClient(op.network)
+	client, err := avsconfig.NewClient(op.network)
 	if err != nil {
 		return fmt.Errorf("failed to create aptos client: %v", err)
 	}
@@ -100,42 +132,96 @@ func (op *Operator) RespondTask(ctx context.Context) error {
 		upperDenom := strings.ToUpper(denom)
 		taskId := task.Id
 
-		price := big.NewInt(int64(getCMCPrice(upperDenom) * 1000000))
+		// a task signed before a restart is answered with the recorded
+		// response, a fresh price would be refused by slashing protection
+		signed, err := op.slashingDb.Signed(op.avsAddress, taskId)
+		if err != nil {
+			op.logger.Error("Failed to read slashing protection database, skipping task", zap.Uint64("task id", taskId), zap.Error(err))
+			continue
+		}
+		if signed != nil {
+			price, ok := new(big.Int).SetString(signed.Response, 10)
+			if !ok {
+				op.logger.Error("Recorded response is not a number, skipping task", zap.Uint64("task id", taskId), zap.String("response", signed.Response))
+				continue
+			}
+			op.logger.Info("Task was signed before, sending the recorded response again", zap.Uint64("task id", taskId), zap.String("price", signed.Response))
+			op.signAndSend(taskId, signed.SigningRoot, price)
+			continue
+		}
 
-		msghHash, err := GetMsgHash(client, op.avsAddress, taskId, *price)
+		priceFloat, err := op.FetchPrice(ctx, upperDenom)
 		if err != nil {
-			return fmt.Errorf("failed to GetMsgHash: %v", err)
+			op.logger.Error("Failed to fetch price, skipping task", zap.Uint64("task id", taskId), zap.Error(err))
+			continue
 		}
+		price := big.NewInt(int64(priceFloat * 1000000))
 
-		trimmedMsgHash := strings.TrimPrefix(msghHash, "0x")
-		bytesMsgHash, err := hex.DecodeString(trimmedMsgHash)
+		bytesMsgHash, err := op.MsgHash(client, task, price)
 		if err != nil {
-			return fmt.Errorf("failed to decode hex to string: %v", err)
+			return err
 		}
 
-		var priv crypto.BlsPrivateKey
-		err = priv.FromBytes(op.BlsPrivateKey)
+		err = op.slashingDb.CheckAndRecord(op.avsAddress, taskId, slashing.SignedResponse{
+			SigningRoot: bytesMsgHash,
+			Response:    price.String(),
+			Pubkey:      op.BlsSigner.PublicKey(),
+			SignedAt:    time.Now().UTC(),
+		})
 		if err != nil {
-			panic("Failed to create bls priv key" + err.Error())
+			signaturesRefused.Inc()
+			op.logger.Error("Slashing protection refused to sign task response", zap.Uint64("task id", taskId), zap.String("price", price.String()), zap.Error(err))
+			continue
 		}
-		signature, err := priv.Sign(bytesMsgHash)
+		op.signAndSend(taskId, bytesMsgHash, price)
+	}
+	return nil
+}
+
+// signAndSend signs a response whose message passed slashing protection and
+// sends it to the aggregator.
+func (op *Operator) signAndSend(taskId uint64, bytesMsgHash []byte, price *big.Int) {
+	signature, err := op.BlsSigner.Sign(bytesMsgHash)
+	if err != nil {
+		op.logger.Error("Failed to sign task response, skipping task", zap.Uint64("task id", taskId), zap.Error(err))
+		return
+	}
+
+	// pubKey, err := priv.GeneratePubkey()
+	// if err != nil {
+	// 	panic("Failed to generate pubkey from privkey" + err.Error())
+	// }
+
+	op.AggRpcClient.SendSignedTaskResponseToAggregator(aggregator.SignedTaskResponse{
+		TaskId:    taskId,
+		Pubkey:    op.BlsSigner.PublicKey(),
+		Signature: signature,
+		Response:  price,
+	})
+}
+
+// MsgHash computes the message to sign for a response locally when the task
+// source provided the task creator, and asks the get_msg_hash view otherwise.
+func (op *Operator) MsgHash(client *aptos.Client, task Task, price *big.Int) ([]byte, error) {
+	if creator, ok := msghash.TaskCreator(task.Task); ok {
+		bytesMsgHash, err := msghash.MsgHash(task.Id, creator, price)
 		if err != nil {
-			panic("Failed to create signature" + err.Error())
+			return nil, fmt.Errorf("failed to compute msg hash: %v", err)
 		}
+		return bytesMsgHash, nil
+	}
 
-		// pubKey, err := priv.GeneratePubkey()
-		// if err != nil {
-		// 	panic("Failed to generate pubkey from privkey" + err.Error())
-		// }
+	msghHash, err := GetMsgHash(client, op.avsAddress, task.Id, *price)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetMsgHash: %v", err)
+	}
 
-		op.AggRpcClient.SendSignedTaskResponseToAggregator(aggregator.SignedTaskResponse{
-			TaskId:    taskId,
-			Pubkey:    signature.Auth.PublicKey().Bytes(),
-			Signature: signature.Auth.Signature().Bytes(),
-			Response:  price,
-		})
+	trimmedMsgHash := strings.TrimPrefix(msghHash, "0x")
+	bytesMsgHash, err := hex.DecodeString(trimmedMsgHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode hex to string: %v", err)
 	}
-	return nil
+	return bytesMsgHash, nil
 }
 
 func GetMsgHash(client *aptos.Client, contract aptos.AccountAddress, taskId uint64, response big.Int) (string, error) {
@@ -159,7 +245,7 @@ func GetMsgHash(client *aptos.Client, contract aptos.AccountAddress, taskId uint
 			taskIdBcs, responseBcs,
 		},
 	}
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return "", fmt.Errorf("can not get msg hash: %v", err)
 	}
@@ -167,25 +253,24 @@ func GetMsgHash(client *aptos.Client, contract aptos.AccountAddress, taskId uint
 	return task, nil
 }
 
-func (op *Operator) QueueTask(ctx context.Context, client *aptos.Client, start uint64, end uint64) error {
-	for i := start + 1; i <= end; i++ {
-		task, err := LoadTaskById(client, op.avsAddress, i)
-		if err != nil {
-			return fmt.Errorf("error loading task: %v", err)
-		}
-		responded := task["responded"].(bool)
+func (op *Operator) QueueTasks(tasks []aggregator.Task) {
+	for _, task := range tasks {
+		tasksSeen.Inc()
+		responded, _ := task.Task["responded"].(bool)
 		if responded {
 			continue
 		}
-		op.logger.Info("Loaded new task with id:", zap.Any("task id", i))
+		if !op.seenTasks.add(task.Id) {
+			continue
+		}
+		tasksQueued.Inc()
+		op.logger.Info("Loaded new task with id:", zap.Any("task id", task.Id))
 		op.TaskQueue <- Task{
-			Id:   i,
-			Task: task,
+			Id:   task.Id,
+			Task: task.Task,
 		}
-		op.logger.Info("Queued new task with id:", zap.Any("task id", i))
+		op.logger.Info("Queued new task with id:", zap.Any("task id", task.Id))
 	}
-
-	return nil
 }
 
 func LoadTaskById(client *aptos.Client, contract aptos.AccountAddress, taskId uint64) (map[string]interface{}, error) {
@@ -204,7 +289,7 @@ func LoadTaskById(client *aptos.Client, contract aptos.AccountAddress, taskId ui
 			taskIdBcs,
 		},
 	}
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return nil, fmt.Errorf("can not get task count: %v", err)
 	}
@@ -223,7 +308,7 @@ func LatestTaskCount(client *aptos.Client, contract aptos.AccountAddress) (uint6
 		Args:     [][]byte{},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		return 0, fmt.Errorf("can not get task count: %v", err)
 	}
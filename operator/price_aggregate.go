@@ -0,0 +1,171 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxPriceDeviation  = 2.0
+	DefaultMaxPriceAgeSeconds = 300
+	// MinOutlierSources is how many fresh answers it takes to tell an outlier
+	// from the others, two answers that disagree leave no price to trust.
+	MinOutlierSources = 3
+)
+
+// PriceAggregationConfig controls how the answers of several price sources
+// are combined into the single price the operator signs.
+// MaxDeviationPercent is measured against the median of all fresh answers.
+// MinSources defaults to a majority of the configured sources.
+type PriceAggregationConfig struct {
+	MinSources          int
+	MaxDeviationPercent float64
+	MaxPriceAgeSeconds  uint64
+}
+
+type sourcePrice struct {
+	source    string
+	price     float64
+	updatedAt time.Time
+	err       error
+}
+
+// DefaultMinPriceSources is a majority of sources, so that a single answer
+// left between two outliers is never signed.
+func DefaultMinPriceSources(sources int) int {
+	return sources/2 + 1
+}
+
+func (c PriceAggregationConfig) withDefaults(sources int) PriceAggregationConfig {
+	if c.MinSources <= 0 {
+		c.MinSources = DefaultMinPriceSources(sources)
+	}
+	if c.MaxDeviationPercent <= 0 {
+		c.MaxDeviationPercent = DefaultMaxPriceDeviation
+	}
+	if c.MaxPriceAgeSeconds == 0 {
+		c.MaxPriceAgeSeconds = DefaultMaxPriceAgeSeconds
+	}
+	return c
+}
+
+// FetchPrice queries every configured price source in parallel and returns
+// the median of the answers that are fresh and agree with each other. It
+// errors, and the task must not be signed, when fewer than MinSources agree.
+func (op *Operator) FetchPrice(ctx context.Context, symbol string) (float64, error) {
+	config := op.PriceAggregation.withDefaults(len(op.PriceSources))
+
+	ctx, cancel := context.WithTimeout(ctx, PriceSourceTimeout)
+	defer cancel()
+
+	results := make(chan sourcePrice, len(op.PriceSources))
+	for _, source := range op.PriceSources {
+		go func(source PriceSource) {
+			start := time.Now()
+			price, updatedAt, err := source.Price(ctx, symbol)
+			priceSourceSeconds.WithLabelValues(source.Name()).Observe(time.Since(start).Seconds())
+			results <- sourcePrice{
+				source:    source.Name(),
+				price:     price,
+				updatedAt: updatedAt,
+				err:       err,
+			}
+		}(source)
+	}
+
+	maxAge := time.Duration(config.MaxPriceAgeSeconds) * time.Second
+	var fresh []sourcePrice
+	var rejected []string
+	for range op.PriceSources {
+		result := <-results
+		usable := false
+		switch {
+		case result.err != nil:
+			rejected = append(rejected, fmt.Sprintf("%s: %v", result.source, result.err))
+		case result.price <= 0 || math.IsNaN(result.price) || math.IsInf(result.price, 0):
+			rejected = append(rejected, fmt.Sprintf("%s: invalid price %f", result.source, result.price))
+		case time.Since(result.updatedAt) > maxAge:
+			rejected = append(rejected, fmt.Sprintf("%s: stale price from %s", result.source, result.updatedAt.Format(time.RFC3339)))
+		default:
+			fresh = append(fresh, result)
+			usable = true
+		}
+		if !usable {
+			priceSourceFailures.WithLabelValues(result.source).Inc()
+		}
+	}
+
+	agreeing, outliers, err := rejectOutliers(fresh, config.MaxDeviationPercent)
+	if err != nil {
+		op.logger.Error("Refusing to sign price", zap.String("symbol", symbol), zap.Error(err))
+		return 0, fmt.Errorf("can not price %s: %v", symbol, err)
+	}
+	for _, outlier := range outliers {
+		rejected = append(rejected, fmt.Sprintf("%s: outlier price %f", outlier.source, outlier.price))
+	}
+
+	if len(rejected) > 0 {
+		op.logger.Warn("Dropped price sources", zap.String("symbol", symbol), zap.Strings("reasons", rejected))
+	}
+	if len(agreeing) < config.MinSources {
+		op.logger.Error("Refusing to sign price, not enough sources agree",
+			zap.String("symbol", symbol),
+			zap.Int("agreeing", len(agreeing)),
+			zap.Int("required", config.MinSources),
+		)
+		return 0, fmt.Errorf("only %d of %d required price sources agree on %s: %s",
+			len(agreeing), config.MinSources, symbol, strings.Join(rejected, "; "))
+	}
+
+	price := medianPrice(agreeing)
+	op.logger.Info("Aggregated price", zap.String("symbol", symbol), zap.Float64("price", price), zap.Int("sources", len(agreeing)))
+	return price, nil
+}
+
+// rejectOutliers splits prices into those within maxDeviationPercent of
+// their median and those beyond it. With fewer than MinOutlierSources prices
+// none of them can be told to be the outlier, it errors when they disagree.
+func rejectOutliers(prices []sourcePrice, maxDeviationPercent float64) ([]sourcePrice, []sourcePrice, error) {
+	if len(prices) == 0 {
+		return nil, nil, nil
+	}
+	median := medianPrice(prices)
+
+	var agreeing, outliers []sourcePrice
+	for _, p := range prices {
+		deviation := math.Abs(p.price-median) / median * 100
+		if deviation > maxDeviationPercent {
+			outliers = append(outliers, p)
+		} else {
+			agreeing = append(agreeing, p)
+		}
+	}
+	if len(outliers) > 0 && len(prices) < MinOutlierSources {
+		var answers []string
+		for _, p := range prices {
+			answers = append(answers, fmt.Sprintf("%s %f", p.source, p.price))
+		}
+		return nil, nil, fmt.Errorf("price sources disagree by more than %.2f%%: %s", maxDeviationPercent, strings.Join(answers, ", "))
+	}
+	return agreeing, outliers, nil
+}
+
+func medianPrice(prices []sourcePrice) float64 {
+	values := make([]float64, len(prices))
+	for i, p := range prices {
+		values[i] = p.price
+	}
+	sort.Float64s(values)
+
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
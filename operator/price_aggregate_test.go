@@ -0,0 +1,90 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRejectOutliers(t *testing.T) {
+	prices := func(values ...float64) []sourcePrice {
+		var result []sourcePrice
+		for i, value := range values {
+			result = append(result, sourcePrice{source: string(rune('a' + i)), price: value})
+		}
+		return result
+	}
+
+	tests := []struct {
+		prices   []sourcePrice
+		agreeing int
+		outliers int
+	}{
+		{prices(100), 1, 0},
+		{prices(100, 101), 2, 0},
+		{prices(100, 101, 150), 2, 1},
+		{prices(100, 100, 101, 50), 3, 1},
+	}
+	for _, test := range tests {
+		agreeing, outliers, err := rejectOutliers(test.prices, 2)
+		if err != nil {
+			t.Errorf("rejectOutliers(%v): %v", test.prices, err)
+			continue
+		}
+		if len(agreeing) != test.agreeing || len(outliers) != test.outliers {
+			t.Errorf("rejectOutliers(%v) = %d agreeing, %d outliers, want %d and %d", test.prices, len(agreeing), len(outliers), test.agreeing, test.outliers)
+		}
+	}
+
+	// two sources that disagree: neither can be told to be the outlier
+	if _, _, err := rejectOutliers(prices(100, 110), 2); err == nil {
+		t.Error("rejectOutliers should fail for two sources that disagree")
+	}
+}
+
+type fixedPriceSource struct {
+	name  string
+	price float64
+}
+
+func (s fixedPriceSource) Name() string {
+	return s.name
+}
+
+func (s fixedPriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	return s.price, time.Now(), nil
+}
+
+func TestFetchPriceMajority(t *testing.T) {
+	op := &Operator{logger: zap.NewNop()}
+	sources := func(values ...float64) []PriceSource {
+		var result []PriceSource
+		for i, value := range values {
+			result = append(result, fixedPriceSource{name: string(rune('a' + i)), price: value})
+		}
+		return result
+	}
+
+	// by default a majority of the sources has to agree
+	op.PriceSources = sources(100, 200, 300)
+	if price, err := op.FetchPrice(context.Background(), "ETH"); err == nil {
+		t.Errorf("signed %f agreed on by one of three sources", price)
+	}
+	op.PriceSources = sources(100, 200, 201, 300, 400)
+	if price, err := op.FetchPrice(context.Background(), "ETH"); err == nil {
+		t.Errorf("signed %f agreed on by two of five sources", price)
+	}
+	op.PriceSources = sources(100, 101, 300)
+	if price, err := op.FetchPrice(context.Background(), "ETH"); err != nil || price != 100.5 {
+		t.Errorf("price agreed on by two of three sources: %f %v, want 100.5", price, err)
+	}
+
+	// a lower MinSources is taken as configured
+	op.PriceSources = sources(100, 200, 300)
+	op.PriceAggregation.MinSources = 1
+	if price, err := op.FetchPrice(context.Background(), "ETH"); err != nil || price != 200 {
+		t.Errorf("price with MinSources 1: %f %v, want 200", price, err)
+	}
+}
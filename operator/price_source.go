@@ -0,0 +1,121 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	PriceSourceCoinMarketCap = "coinmarketcap"
+	PriceSourceCoinGecko     = "coingecko"
+	PriceSourceBinance       = "binance"
+	PriceSourcePyth          = "pyth"
+	PriceSourceStatic        = "static"
+
+	PriceSourceTimeout = 10 * time.Second
+)
+
+// PriceSource returns the USD price of a symbol together with the time the
+// vendor last updated it.
+type PriceSource interface {
+	Name() string
+	Price(ctx context.Context, symbol string) (float64, time.Time, error)
+}
+
+// PriceSourceConfig selects and configures one PriceSource.
+// Symbols maps an upper case task symbol (e.g. "ETH") to the identifier the
+// vendor expects: a CoinGecko coin id, a Binance pair or a Pyth feed id.
+// Prices is only used by the static source.
+type PriceSourceConfig struct {
+	Type     string
+	ApiKey   string             `json:",omitempty"`
+	Endpoint string             `json:",omitempty"`
+	Symbols  map[string]string  `json:",omitempty"`
+	Prices   map[string]float64 `json:",omitempty"`
+}
+
+// DefaultPriceSources is used when the operator config does not list any
+// source, like configs created before price sources were pluggable. It needs
+// a CoinMarketCap API key, so those configs have to list their sources now.
+var DefaultPriceSources = []PriceSourceConfig{
+	{Type: PriceSourceCoinMarketCap},
+}
+
+func NewPriceSource(config PriceSourceConfig) (PriceSource, error) {
+	httpClient := &http.Client{Timeout: PriceSourceTimeout}
+	switch strings.ToLower(config.Type) {
+	case PriceSourceCoinMarketCap:
+		return NewCMCPriceSource(httpClient, config)
+	case PriceSourceCoinGecko:
+		return NewCoinGeckoPriceSource(httpClient, config), nil
+	case PriceSourceBinance:
+		return NewBinancePriceSource(httpClient, config), nil
+	case PriceSourcePyth:
+		return NewPythPriceSource(httpClient, config), nil
+	case PriceSourceStatic:
+		return NewStaticPriceSource(config), nil
+	default:
+		return nil, fmt.Errorf("unknown price source %q, choose one of: %s, %s, %s, %s, %s", config.Type,
+			PriceSourceCoinMarketCap, PriceSourceCoinGecko, PriceSourceBinance, PriceSourcePyth, PriceSourceStatic)
+	}
+}
+
+func NewPriceSources(configs []PriceSourceConfig) ([]PriceSource, error) {
+	listed := len(configs) != 0
+	if !listed {
+		configs = DefaultPriceSources
+	}
+	sources := make([]PriceSource, 0, len(configs))
+	for _, config := range configs {
+		source, err := NewPriceSource(config)
+		if err != nil {
+			if !listed {
+				return nil, fmt.Errorf("no PriceSources configured, the default can not be used: %v; see config/example.json", err)
+			}
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func symbolFor(symbols map[string]string, symbol string) (string, bool) {
+	vendorSymbol, ok := symbols[strings.ToUpper(symbol)]
+	return vendorSymbol, ok
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("can not create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request to %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response from %s: %v", req.URL.Host, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s: %s", req.URL.Host, resp.Status, string(body))
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		return fmt.Errorf("error unmarshal response from %s: %v", req.URL.Host, err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package operator
+
+import "testing"
+
+func TestCoinMarketCapNeedsApiKey(t *testing.T) {
+	if _, err := NewPriceSource(PriceSourceConfig{Type: PriceSourceCoinMarketCap}); err == nil {
+		t.Error("the coinmarketcap source should require an ApiKey")
+	}
+	if _, err := NewPriceSources(nil); err == nil {
+		t.Error("the default sources should require an ApiKey")
+	}
+	if _, err := NewPriceSource(PriceSourceConfig{Type: PriceSourceCoinMarketCap, ApiKey: "key"}); err != nil {
+		t.Errorf("coinmarketcap source with an ApiKey: %v", err)
+	}
+}
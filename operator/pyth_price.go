@@ -0,0 +1,75 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const PythEndpoint = "https://hermes.pyth.network"
+
+// PythPriceSource reads prices from a Pyth Hermes HTTP endpoint. Symbols
+// must map every requested symbol to its Pyth price feed id.
+type PythPriceSource struct {
+	client   *http.Client
+	endpoint string
+	symbols  map[string]string
+}
+
+func NewPythPriceSource(client *http.Client, config PriceSourceConfig) *PythPriceSource {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = PythEndpoint
+	}
+	return &PythPriceSource{
+		client:   client,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		symbols:  config.Symbols,
+	}
+}
+
+func (s *PythPriceSource) Name() string {
+	return PriceSourcePyth
+}
+
+func (s *PythPriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	feedId, ok := symbolFor(s.symbols, symbol)
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("no pyth feed id configured for %s", symbol)
+	}
+
+	q := url.Values{}
+	q.Add("ids[]", feedId)
+	q.Add("parsed", "true")
+
+	var res struct {
+		Parsed []struct {
+			Id    string `json:"id"`
+			Price struct {
+				Price       string `json:"price"`
+				Expo        int    `json:"expo"`
+				PublishTime int64  `json:"publish_time"`
+			} `json:"price"`
+		} `json:"parsed"`
+	}
+	err := getJSON(ctx, s.client, s.endpoint+"/v2/updates/price/latest?"+q.Encode(), nil, &res)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(res.Parsed) == 0 {
+		return 0, time.Time{}, fmt.Errorf("pyth has no data for feed %s", feedId)
+	}
+
+	feed := res.Parsed[0]
+	mantissa, err := strconv.ParseInt(feed.Price.Price, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("error parsing pyth price %q: %v", feed.Price.Price, err)
+	}
+	price := float64(mantissa) * math.Pow10(feed.Price.Expo)
+	return price, time.Unix(feed.Price.PublishTime, 0), nil
+}
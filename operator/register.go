@@ -0,0 +1,255 @@
+package operator
+
+import (
+	"avs/aggregator"
+	"avs/avsconfig"
+	"avs/signer"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/api"
+	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	flagQuorums      = "quorums"
+	flagDryRun       = "dry-run"
+	flagYes          = "yes"
+	flagAutoRegister = "auto-register"
+)
+
+func Register(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "register",
+		Short: "register the operator with the AVS in the given quorums",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quorumsFlag, err := cmd.Flags().GetString(flagQuorums)
+			if err != nil {
+				return errors.Wrap(err, flagQuorums)
+			}
+			dryRun, err := cmd.Flags().GetBool(flagDryRun)
+			if err != nil {
+				return errors.Wrap(err, flagDryRun)
+			}
+			yes, err := cmd.Flags().GetBool(flagYes)
+			if err != nil {
+				return errors.Wrap(err, flagYes)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			blsSigner, err := LoadBlsSigner(logger, *operatorConfig, passwordFile)
+			if err != nil {
+				return err
+			}
+			operatorAccount, err := LoadAccount(*operatorConfig, nodeConfig.Account)
+			if err != nil {
+				return fmt.Errorf("can not load operator account: %s", err)
+			}
+			client, err := avsconfig.NewClient(nodeConfig.Network)
+			if err != nil {
+				return fmt.Errorf("failed to create aptos client: %s", err)
+			}
+			avsAddress := aptos.AccountAddress{}
+			if err := avsAddress.ParseStringRelaxed(operatorConfig.AvsAddress); err != nil {
+				return fmt.Errorf("failed to parse avs address: %s", err)
+			}
+
+			status, err := GetOperatorStatus(client, avsAddress, operatorAccount.Address)
+			if err != nil {
+				return fmt.Errorf("can not get operator status: %s", err)
+			}
+			if status != 0 {
+				return fmt.Errorf("operator %s is already registered", operatorAccount.Address.String())
+			}
+			quorumCount, err := quorumCount(client, avsAddress)
+			if err != nil {
+				return fmt.Errorf("can not get quorum count: %s", err)
+			}
+			quorums, err := ParseQuorums(quorumsFlag, quorumCount)
+			if err != nil {
+				return err
+			}
+
+			payload, err := registrationPayload(operatorAccount, avsAddress, quorums, blsSigner)
+			if err != nil {
+				return err
+			}
+			simulated, err := SimulatePayload(client, operatorAccount, payload)
+			if err != nil {
+				return fmt.Errorf("registration would fail: %s", err)
+			}
+			fmt.Printf("Registering operator %s with AVS %s in quorums %v\n", operatorAccount.Address.String(), avsAddress.String(), quorums)
+			fmt.Printf("Simulation succeeded, gas used %d at gas unit price %d\n", simulated.GasUsed, simulated.GasUnitPrice)
+			if dryRun {
+				return nil
+			}
+			if !yes && !confirm(os.Stdin, "Send the registration transaction?") {
+				return fmt.Errorf("registration cancelled")
+			}
+
+			txHash, err := RegisterWithBlsKey(client, operatorAccount, avsAddress, quorums, blsSigner)
+			if err != nil {
+				return fmt.Errorf("can not register operator: %s", err)
+			}
+			logger.Info("Registered operator", zap.String("tx hash", txHash), zap.Any("quorums", quorums))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAptosConfigPath, ".aptos/config.yaml", "the path to your operator priv and pub key")
+	cmd.Flags().String(flagAccountProfile, "default", "the account profile to use")
+	cmd.Flags().String(flagAptosNetwork, "devnet", "choose network to connect to: mainnet, testnet, devnet, localnet")
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().String(flagQuorums, "", "the quorum to register in (default the highest quorum)")
+	cmd.Flags().Bool(flagDryRun, false, "only simulate the registration")
+	cmd.Flags().BoolP(flagYes, "y", false, "do not ask for confirmation")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the BLS keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	return cmd
+}
+
+// ParseQuorums reads the quorum number to register in, between 1 and
+// quorumCount; an empty list selects the highest quorum like earlier
+// releases. registor_operator stores the quorum numbers as the bytes of its
+// bitmap while deregistration clears one bit per quorum, so an operator
+// registered in several quorums can not leave them: more than one quorum is
+// refused.
+func ParseQuorums(list string, quorumCount uint8) ([]uint8, error) {
+	if quorumCount == 0 {
+		return nil, fmt.Errorf("no quorum found, please initialize quorum first")
+	}
+	if strings.TrimSpace(list) == "" {
+		return []uint8{quorumCount}, nil
+	}
+
+	seen := make(map[uint8]bool)
+	var quorums []uint8
+	for _, field := range strings.Split(list, ",") {
+		quorum64, err := strconv.ParseUint(strings.TrimSpace(field), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quorum %q: %v", field, err)
+		}
+		quorum := uint8(quorum64)
+		if quorum == 0 || quorum > quorumCount {
+			return nil, fmt.Errorf("quorum %d does not exist, there are %d quorums", quorum, quorumCount)
+		}
+		if !seen[quorum] {
+			seen[quorum] = true
+			quorums = append(quorums, quorum)
+		}
+	}
+	if len(quorums) > 1 {
+		return nil, fmt.Errorf("can not register in quorums %v, the service manager only supports registering in one quorum", quorums)
+	}
+	return quorums, nil
+}
+
+// RegistrationSignature signs the pubkey registration message of the account
+// with the BLS key and returns the signature, the pubkey and the proof of
+// possession registor_operator takes.
+func RegistrationSignature(account aptos.AccountAddress, blsSigner signer.BlsSigner) ([]byte, []byte, []byte, error) {
+	bcsOperatorAccount, err := bcs.Serialize(&account)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to bcs serialize account: %v", err)
+	}
+	msg := append([]byte("PubkeyRegistration"), bcsOperatorAccount...)
+	signature, err := blsSigner.Sign(ethcrypto.Keccak256(msg))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create signature: %v", err)
+	}
+	pop, err := blsSigner.ProofOfPossession()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate bls proof of possession: %v", err)
+	}
+	return signature, blsSigner.PublicKey(), pop, nil
+}
+
+// RegisterWithBlsKey registers the account in quorums with the BLS key.
+func RegisterWithBlsKey(client *aptos.Client, account *aptos.Account, avsAddress aptos.AccountAddress, quorums []uint8, blsSigner signer.BlsSigner) (string, error) {
+	signature, pubkey, pop, err := RegistrationSignature(account.Address, blsSigner)
+	if err != nil {
+		return "", err
+	}
+	return RegisterOperator(client, account, avsAddress.String(), quorums, signature, pubkey, pop)
+}
+
+func registrationPayload(account *aptos.Account, avsAddress aptos.AccountAddress, quorums []uint8, blsSigner signer.BlsSigner) (aptos.TransactionPayload, error) {
+	signature, pubkey, pop, err := RegistrationSignature(account.Address, blsSigner)
+	if err != nil {
+		return aptos.TransactionPayload{}, err
+	}
+	return RegisterOperatorPayload(avsAddress.String(), quorums, signature, pubkey, pop)
+}
+
+// SimulatePayload runs a transaction from account against the current chain
+// state without sending it, the error is a *aggregator.MoveAbortError when
+// it would abort.
+func SimulatePayload(client *aptos.Client, account *aptos.Account, payload aptos.TransactionPayload) (*api.UserTransaction, error) {
+	rawTxn, err := client.BuildTransaction(account.AccountAddress(), payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transaction: %v", err)
+	}
+	simulated, err := client.SimulateTransaction(rawTxn, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate transaction: %v", err)
+	}
+	if len(simulated) == 0 {
+		return nil, fmt.Errorf("simulation returned no transaction")
+	}
+	if !simulated[0].Success {
+		return simulated[0], transactionError(simulated[0].VmStatus)
+	}
+	return simulated[0], nil
+}
+
+func transactionError(vmStatus string) error {
+	if abort := aggregator.ParseMoveAbort(vmStatus); abort != nil {
+		return abort
+	}
+	return fmt.Errorf("transaction failed: %s", vmStatus)
+}
+
+// confirm asks a yes/no question on stdout and reads the answer from in.
+func confirm(in io.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// autoRegisterQuorumList resolves the --quorums of `start --auto-register`
+// against the quorums the AVS has.
+func autoRegisterQuorumList(networkConfig aptos.NetworkConfig, avsAddr string, list string) ([]uint8, error) {
+	client, err := avsconfig.NewClient(networkConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aptos client: %v", err)
+	}
+	avsAddress := aptos.AccountAddress{}
+	if err := avsAddress.ParseStringRelaxed(avsAddr); err != nil {
+		return nil, fmt.Errorf("failed to parse avs address: %v", err)
+	}
+	quorumCount, err := quorumCount(client, avsAddress)
+	if err != nil {
+		return nil, fmt.Errorf("can not get quorum count: %v", err)
+	}
+	return ParseQuorums(list, quorumCount)
+}
@@ -0,0 +1,38 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuorums(t *testing.T) {
+	tests := []struct {
+		list        string
+		quorumCount uint8
+		want        []uint8
+	}{
+		{"", 1, []uint8{1}},
+		{" ", 3, []uint8{3}},
+		{"2", 3, []uint8{2}},
+		{"2,2", 3, []uint8{2}},
+	}
+	for _, test := range tests {
+		quorums, err := ParseQuorums(test.list, test.quorumCount)
+		if err != nil {
+			t.Errorf("ParseQuorums(%q, %d): %v", test.list, test.quorumCount, err)
+			continue
+		}
+		if !reflect.DeepEqual(quorums, test.want) {
+			t.Errorf("ParseQuorums(%q, %d) = %v, want %v", test.list, test.quorumCount, quorums, test.want)
+		}
+	}
+
+	for _, list := range []string{"1,3", "0", "4", "x"} {
+		if _, err := ParseQuorums(list, 3); err == nil {
+			t.Errorf("ParseQuorums(%q, 3) should fail", list)
+		}
+	}
+	if _, err := ParseQuorums("", 0); err == nil {
+		t.Error("ParseQuorums should fail without quorums")
+	}
+}
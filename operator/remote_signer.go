@@ -0,0 +1,69 @@
+package operator
+
+import (
+	"avs/signer"
+	"net/http"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"go.uber.org/zap"
+)
+
+// RemoteSignerConfig sends signing requests to a Web3Signer-style remote
+// signer. BlsPubkey replaces the BLS keystore and Ed25519Pubkey replaces the
+// key of .aptos/config.yaml, a key left empty is still used locally.
+type RemoteSignerConfig struct {
+	Url           string
+	BlsPubkey     string               `json:",omitempty"`
+	Ed25519Pubkey string               `json:",omitempty"`
+	Tls           *AggregatorTlsConfig `json:",omitempty"`
+}
+
+func (c *RemoteSignerConfig) httpClient() (*http.Client, string, error) {
+	endpoint, err := endpointUrl(c.Url, c.Tls != nil)
+	if err != nil {
+		return nil, "", err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Tls != nil {
+		config, err := c.Tls.clientConfig()
+		if err != nil {
+			return nil, "", err
+		}
+		transport.TLSClientConfig = config
+	}
+	return &http.Client{Transport: transport, Timeout: signer.RequestTimeout}, endpoint.String(), nil
+}
+
+// LoadBlsSigner returns the remote BLS signer when the config sets one, and
+// the key of the local keystore otherwise.
+func LoadBlsSigner(logger *zap.Logger, config OperatorConfig, passwordFile string) (signer.BlsSigner, error) {
+	if config.RemoteSigner != nil && config.RemoteSigner.BlsPubkey != "" {
+		httpClient, url, err := config.RemoteSigner.httpClient()
+		if err != nil {
+			return nil, err
+		}
+		return signer.NewRemoteBls(httpClient, url, config.RemoteSigner.BlsPubkey)
+	}
+	privateKey, err := LoadBlsPrivateKey(logger, config, passwordFile)
+	if err != nil {
+		return nil, err
+	}
+	return signer.NewLocalBls(privateKey)
+}
+
+// LoadAccount returns the Aptos account of the operator, signing through the
+// remote signer when the config sets an Ed25519 key for it.
+func LoadAccount(config OperatorConfig, accountConfig AptosAccountConfig) (*aptos.Account, error) {
+	if config.RemoteSigner != nil && config.RemoteSigner.Ed25519Pubkey != "" {
+		httpClient, url, err := config.RemoteSigner.httpClient()
+		if err != nil {
+			return nil, err
+		}
+		remote, err := signer.NewRemoteEd25519(httpClient, url, config.RemoteSigner.Ed25519Pubkey)
+		if err != nil {
+			return nil, err
+		}
+		return aptos.NewAccountFromSigner(remote)
+	}
+	return SignerFromConfig(accountConfig.configPath, accountConfig.profile)
+}
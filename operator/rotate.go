@@ -0,0 +1,251 @@
+package operator
+
+import (
+	"avs/avsconfig"
+	"avs/keystore"
+	"avs/signer"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const flagNewAccountProfile = "new-account-profile"
+
+// rotationTx is a transaction of the rotation, sent in order.
+type rotationTx struct {
+	name    string
+	account *aptos.Account
+	payload aptos.TransactionPayload
+}
+
+// RotateBlsKey moves the operator to a new BLS key. registry_coordinator keeps
+// the first BLS key an account registered and never frees its operator id, so
+// the new key is registered from a new Aptos account: it joins the quorums of
+// the current account first and the current account leaves them second, the
+// operator is never out of the quorums in between.
+func RotateBlsKey(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-bls-key",
+		Short: "register a new BLS key from a new account, then deregister the current one",
+		Long: "Generates a new BLS key into <keystore>.new, registers it from the account of " +
+			"--new-account-profile in the quorums of the current operator, deregisters the current " +
+			"operator and moves the new keystore into place, keeping the old one as <keystore>.old. " +
+			"A failed run keeps the staged keystore and can be run again. Restart the operator with " +
+			"the new account profile afterwards.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			newAccountProfile, err := cmd.Flags().GetString(flagNewAccountProfile)
+			if err != nil {
+				return errors.Wrap(err, flagNewAccountProfile)
+			}
+			dryRun, err := cmd.Flags().GetBool(flagDryRun)
+			if err != nil {
+				return errors.Wrap(err, flagDryRun)
+			}
+			yes, err := cmd.Flags().GetBool(flagYes)
+			if err != nil {
+				return errors.Wrap(err, flagYes)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+			kdf, err := cmd.Flags().GetString(flagKdf)
+			if err != nil {
+				return errors.Wrap(err, flagKdf)
+			}
+			if newAccountProfile == "" {
+				return fmt.Errorf("--%s is required, an account can not change its BLS key", flagNewAccountProfile)
+			}
+
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			if operatorConfig.RemoteSigner != nil && operatorConfig.RemoteSigner.BlsPubkey != "" {
+				return fmt.Errorf("the BLS key is held by the remote signer, rotate it there")
+			}
+			if operatorConfig.BlsKeystore == "" {
+				return fmt.Errorf("the operator config has no BlsKeystore, move the key to one with `avs operator keys import` first")
+			}
+			stagedKeystore := operatorConfig.BlsKeystore + ".new"
+			oldKeystore := operatorConfig.BlsKeystore + ".old"
+			if _, err := os.Stat(oldKeystore); err == nil {
+				return fmt.Errorf("%s already exists, move it away first", oldKeystore)
+			}
+
+			operatorAccount, err := LoadAccount(*operatorConfig, nodeConfig.Account)
+			if err != nil {
+				return fmt.Errorf("can not load operator account: %s", err)
+			}
+			newAccount, err := SignerFromConfig(nodeConfig.Account.configPath, newAccountProfile)
+			if err != nil {
+				return fmt.Errorf("can not load new operator account: %s", err)
+			}
+			if newAccount.Address == operatorAccount.Address {
+				return fmt.Errorf("profile %s is the current operator account %s", newAccountProfile, operatorAccount.Address.String())
+			}
+			client, err := avsconfig.NewClient(nodeConfig.Network)
+			if err != nil {
+				return fmt.Errorf("failed to create aptos client: %s", err)
+			}
+			avsAddress := aptos.AccountAddress{}
+			if err := avsAddress.ParseStringRelaxed(operatorConfig.AvsAddress); err != nil {
+				return fmt.Errorf("failed to parse avs address: %s", err)
+			}
+
+			quorums, err := registeredQuorums(client, avsAddress, operatorAccount.Address)
+			if err != nil {
+				return err
+			}
+			newStatus, err := GetOperatorStatus(client, avsAddress, newAccount.Address)
+			if err != nil {
+				return fmt.Errorf("can not get status of the new account: %s", err)
+			}
+
+			// a staged key is left by a run that stopped part way, it is
+			// reused so the key the new account registered stays the one
+			// moved into place
+			var blsSigner signer.BlsSigner
+			var newKey []byte
+			staged := false
+			if _, err := os.Stat(stagedKeystore); err == nil {
+				newKey, err = decryptBlsKeystore(stagedKeystore, passwordFile)
+				if err != nil {
+					return err
+				}
+				staged = true
+				fmt.Printf("Resuming with the staged key in %s\n", stagedKeystore)
+			} else {
+				if newStatus != 0 {
+					return fmt.Errorf("new account %s is already registered with the AVS", newAccount.Address.String())
+				}
+				privKey, err := crypto.GenerateBlsPrivateKey()
+				if err != nil {
+					return fmt.Errorf("unable to generate bls keys: %s", err)
+				}
+				newKey = privKey.Inner.Marshal()
+			}
+			blsSigner, err = signer.NewLocalBls(newKey)
+			if err != nil {
+				return err
+			}
+
+			var txs []rotationTx
+			if newStatus == 0 {
+				payload, err := registrationPayload(newAccount, avsAddress, quorums, blsSigner)
+				if err != nil {
+					return err
+				}
+				txs = append(txs, rotationTx{name: "register the new key", account: newAccount, payload: payload})
+			}
+			txs = append(txs, rotationTx{
+				name:    "deregister the current key",
+				account: operatorAccount,
+				payload: DeregisterOperatorPayload(avsAddress, quorums),
+			})
+
+			fmt.Printf("Rotating the BLS key of operator %s in quorums %v\n", operatorAccount.Address.String(), quorums)
+			fmt.Printf("New BLS pubkey 0x%s from account %s\n", hex.EncodeToString(blsSigner.PublicKey()), newAccount.Address.String())
+			for i, tx := range txs {
+				printRotationTx(i+1, tx)
+				// the transactions touch different operators, each is
+				// simulated against the current state
+				simulated, err := SimulatePayload(client, tx.account, tx.payload)
+				if err != nil {
+					return fmt.Errorf("%s would fail: %s", tx.name, err)
+				}
+				fmt.Printf("  simulation succeeded, gas used %d at gas unit price %d\n", simulated.GasUsed, simulated.GasUnitPrice)
+			}
+			if dryRun {
+				return nil
+			}
+			if !yes && !confirm(os.Stdin, fmt.Sprintf("Send the %d transactions?", len(txs))) {
+				return fmt.Errorf("rotation cancelled")
+			}
+
+			// the key is on disk before it is registered anywhere
+			if !staged {
+				if err := writeBlsKeystore(stagedKeystore, newKey, passwordFile, kdf); err != nil {
+					return err
+				}
+			}
+			for _, tx := range txs {
+				txHash, err := SubmitPayload(client, tx.account, tx.payload)
+				if err != nil {
+					return fmt.Errorf("can not %s: %s, the new key stays in %s, run the rotation again", tx.name, err, stagedKeystore)
+				}
+				logger.Info("Sent rotation transaction", zap.String("step", tx.name), zap.String("tx hash", txHash))
+			}
+
+			if err := os.Rename(operatorConfig.BlsKeystore, oldKeystore); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("can not move the old keystore: %s", err)
+			}
+			if err := os.Rename(stagedKeystore, operatorConfig.BlsKeystore); err != nil {
+				return fmt.Errorf("can not move %s to %s: %s", stagedKeystore, operatorConfig.BlsKeystore, err)
+			}
+			logger.Info("Rotated BLS key",
+				zap.String("keystore", operatorConfig.BlsKeystore),
+				zap.String("old keystore", oldKeystore),
+				zap.String("operator", newAccount.Address.String()),
+			)
+			fmt.Printf("Restart the operator with --%s %s\n", flagAccountProfile, newAccountProfile)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAptosConfigPath, ".aptos/config.yaml", "the path to your operator priv and pub key")
+	cmd.Flags().String(flagAccountProfile, "default", "the account profile to use")
+	cmd.Flags().String(flagNewAccountProfile, "", "the account profile that registers the new key")
+	cmd.Flags().String(flagAptosNetwork, "devnet", "choose network to connect to: mainnet, testnet, devnet, localnet")
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().Bool(flagDryRun, false, "print and simulate the transactions without sending them")
+	cmd.Flags().BoolP(flagYes, "y", false, "do not ask for confirmation")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the BLS keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	cmd.Flags().String(flagKdf, keystore.KdfScrypt, "key derivation function of the new keystore: scrypt or pbkdf2")
+	return cmd
+}
+
+// registeredQuorums returns the quorums the operator is registered in, in
+// ascending order.
+func registeredQuorums(client *aptos.Client, avsAddress aptos.AccountAddress, operator aptos.AccountAddress) ([]uint8, error) {
+	status, err := GetOperatorStatus(client, avsAddress, operator)
+	if err != nil {
+		return nil, fmt.Errorf("can not get operator status: %s", err)
+	}
+	if status != 1 {
+		return nil, fmt.Errorf("operator %s is not registered", operator.String())
+	}
+	operatorId, err := GetOperatorId(client, avsAddress, operator)
+	if err != nil {
+		return nil, fmt.Errorf("can not get operator id: %s", err)
+	}
+	bitmap, err := GetQuorumBitmap(client, avsAddress, operatorId)
+	if err != nil {
+		return nil, fmt.Errorf("can not get quorum bitmap: %s", err)
+	}
+	quorums := BitmapQuorums(bitmap)
+	if len(quorums) == 0 {
+		return nil, fmt.Errorf("operator %s is in no quorum", operator.String())
+	}
+	return quorums, nil
+}
+
+func printRotationTx(n int, tx rotationTx) {
+	fmt.Printf("Transaction %d: %s\n", n, tx.name)
+	fmt.Printf("  sender:   %s\n", tx.account.Address.String())
+	entry, ok := tx.payload.Payload.(*aptos.EntryFunction)
+	if !ok {
+		return
+	}
+	fmt.Printf("  function: %s::%s::%s\n", entry.Module.Address.String(), entry.Module.Name, entry.Function)
+	for i, arg := range entry.Args {
+		fmt.Printf("  arg %d:    0x%s\n", i, hex.EncodeToString(arg))
+	}
+}
@@ -0,0 +1,132 @@
+package operator
+
+import (
+	"avs/slashing"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const flagAllAvs = "all"
+
+func SlashingProtection(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "slashing-protection",
+		Short: "import and export the slashing protection database, stop the operator first",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		exportSlashingProtection(logger),
+		importSlashingProtection(logger),
+	)
+	return cmd
+}
+
+func exportSlashingProtection(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [file]",
+		Short: "write the signed responses of the configured AVS as interchange JSON, to stdout without a file",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, err := cmd.Flags().GetBool(flagAllAvs)
+			if err != nil {
+				return errors.Wrap(err, flagAllAvs)
+			}
+
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			var avsAddress *aptos.AccountAddress
+			if !all {
+				avsAddress = &aptos.AccountAddress{}
+				if err := avsAddress.ParseStringRelaxed(operatorConfig.AvsAddress); err != nil {
+					return fmt.Errorf("failed to parse avs address: %s", err)
+				}
+			}
+
+			store, err := slashing.OpenStore(operatorConfig.SlashingProtectionDbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			interchange, err := store.Export(avsAddress)
+			if err != nil {
+				return fmt.Errorf("can not export slashing protection: %s", err)
+			}
+
+			var out io.Writer = os.Stdout
+			if len(args) == 1 {
+				f, err := os.Create(args[0])
+				if err != nil {
+					return fmt.Errorf("failed to create file at %s: %s", args[0], err)
+				}
+				defer f.Close()
+				out = f
+			}
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(interchange); err != nil {
+				return err
+			}
+			logger.Info("Exported slashing protection", zap.Int("records", len(interchange.Data)))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().Bool(flagAllAvs, false, "export the records of every AVS, not only the configured one")
+	return cmd
+}
+
+func importSlashingProtection(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "merge interchange JSON into the slashing protection database",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			bz, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var interchange slashing.Interchange
+			if err := json.Unmarshal(bz, &interchange); err != nil {
+				return fmt.Errorf("can not parse %s: %s", args[0], err)
+			}
+
+			store, err := slashing.OpenStore(operatorConfig.SlashingProtectionDbPath)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			result, err := store.Import(&interchange)
+			if err != nil {
+				return fmt.Errorf("can not import slashing protection: %s", err)
+			}
+			for _, conflict := range result.Conflicts {
+				logger.Warn("Kept the stored response, the imported one signs a different message", zap.String("task", conflict))
+			}
+			logger.Info("Imported slashing protection",
+				zap.Int("imported", result.Imported),
+				zap.Int("already known", result.Known),
+				zap.Int("conflicts", len(result.Conflicts)),
+			)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	return cmd
+}
@@ -1,14 +1,17 @@
 package operator
 
 import (
+	"avs/admin"
+	"avs/avsconfig"
+	"avs/metrics"
+	"avs/signer"
+	"avs/slashing"
 	"fmt"
 	"log"
 	"math/big"
 
 	aptos "github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
-	"github.com/aptos-labs/aptos-go-sdk/crypto"
-	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"go.uber.org/zap"
 )
 
@@ -19,19 +22,24 @@ type AptosAccountConfig struct {
 
 func AptosClient(networkConfig aptos.NetworkConfig) *aptos.Client {
 	// Create a client for Aptos
-	client, err := aptos.NewClient(networkConfig)
+	client, err := avsconfig.NewClient(networkConfig)
 	if err != nil {
 		panic("Failed to create client:" + err.Error())
 	}
 	return client
 }
 
-func NewOperator(logger *zap.Logger, networkConfig aptos.NetworkConfig, config OperatorConfig, accountConfig AptosAccountConfig, blsPriv []byte) (*Operator, error) {
-	operatorAccount, err := SignerFromConfig(accountConfig.configPath, accountConfig.profile)
+// NewOperator refuses an account that is not registered with the AVS, unless
+// autoRegisterQuorums lists the quorums to register it in.
+func NewOperator(logger *zap.Logger, networkConfig aptos.NetworkConfig, config OperatorConfig, accountConfig AptosAccountConfig, blsSigner signer.BlsSigner, autoRegisterQuorums []uint8) (*Operator, error) {
+	errorRecorder := admin.NewErrorRecorder()
+	logger = errorRecorder.Wrap(logger)
+
+	operatorAccount, err := LoadAccount(config, accountConfig)
 	if err != nil {
-		panic("Failed to create operator account:" + err.Error())
+		return nil, fmt.Errorf("failed to create operator account: %v", err)
 	}
-	client, err := aptos.NewClient(networkConfig)
+	client, err := avsconfig.NewClient(networkConfig)
 	if err != nil {
 		panic("Failed to create client:" + err.Error())
 	}
@@ -42,72 +50,59 @@ func NewOperator(logger *zap.Logger, networkConfig aptos.NetworkConfig, config O
 		panic("Failed to parse avsAddress:" + err.Error())
 	}
 	registered := IsOperatorRegistered(client, avsAddress, operatorAccount.Address.String())
-
 	if !registered {
-		log.Println("Operator is not registered with A2D avs AVS, registering...")
-
-		quorumCount := QuorumCount(client, avsAddress)
-		if quorumCount == 0 {
-			panic("No quorum found, please initialize quorum first ")
-		}
-
-		quorumNumbers := quorumCount
-		fmt.Println("quorumNumbers:", quorumNumbers)
-		// Register Operator
-		// ignore error here because panic all the time
-		var priv crypto.BlsPrivateKey
-		msg := []byte("PubkeyRegistration")
-		bcsOperatorAccount, err := bcs.Serialize(&operatorAccount.Address)
-		if err != nil {
-			panic("Failed to bsc serialize account" + err.Error())
-		}
-
-		msg = append(msg, bcsOperatorAccount...)
-		keccakMsg := ethcrypto.Keccak256(msg)
-		err = priv.FromBytes(config.BlsPrivateKey)
-		if err != nil {
-			panic("Failed to create bls priv key" + err.Error())
-		}
-
-		signature, err := priv.Sign(keccakMsg)
-		if err != nil {
-			panic("Failed to create signature" + err.Error())
+		if autoRegisterQuorums == nil {
+			return nil, fmt.Errorf("operator %s is not registered with the AVS, run `avs operator register` or start with --%s", operatorAccount.Address.String(), flagAutoRegister)
 		}
-		pop, err := priv.GenerateBlsPop()
+		log.Println("Operator is not registered with A2D avs AVS, registering in quorums", autoRegisterQuorums)
+		txHash, err := RegisterWithBlsKey(client, operatorAccount, avsAddress, autoRegisterQuorums, blsSigner)
 		if err != nil {
-			panic("Failed to generate bls proof of possession" + err.Error())
+			return nil, fmt.Errorf("can not register operator: %v", err)
 		}
-		_ = RegisterOperator(
-			client,
-			operatorAccount,
-			avsAddress.String(),
-			quorumNumbers,
-			signature.Auth.Signature().Bytes(),
-			signature.PubKey().Bytes(),
-			pop.Bytes(),
-		)
+		logger.Info("Registered operator", zap.String("tx hash", txHash), zap.Any("quorums", autoRegisterQuorums))
 	}
 
 	// Get OperatorId
-	var privKey crypto.BlsPrivateKey
-	privKey.FromBytes(config.BlsPrivateKey)
-	operatorId := privKey.Inner.PublicKey().Marshal()
+	operatorId := blsSigner.PublicKey()
 
-	aggClient, err := NewAggregatorRpcClient(config.AggregatorIpPortAddr)
+	aggAuth, err := newAggregatorAuth(config.AggregatorAuth, operatorAccount, blsSigner)
+	if err != nil {
+		return nil, err
+	}
+	aggClient, err := NewAggregatorRpcClient(config.AggregatorIpPortAddr, config.AggregatorTls, aggAuth)
 	if err != nil {
 		return nil, fmt.Errorf("can not create new aggregator Rpc Client: %v", err)
 	}
 
+	priceSources, err := NewPriceSources(config.PriceSources)
+	if err != nil {
+		return nil, fmt.Errorf("can not create price sources: %v", err)
+	}
+	slashingDb, err := slashing.OpenStore(config.SlashingProtectionDbPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// return Operator
 	operator := Operator{
-		logger:        logger,
-		account:       operatorAccount,
-		operatorId:    operatorId,
-		avsAddress:    avsAddress,
-		BlsPrivateKey: blsPriv,
-		AggRpcClient:  *aggClient,
-		network:       networkConfig,
-		TaskQueue:     make(chan Task, 100),
+		logger:           logger,
+		account:          operatorAccount,
+		operatorId:       operatorId,
+		avsAddress:       avsAddress,
+		BlsSigner:        blsSigner,
+		slashingDb:       slashingDb,
+		AggRpcClient:     aggClient,
+		network:          networkConfig,
+		TaskQueue:        make(chan Task, 100),
+		PriceSources:     priceSources,
+		PriceAggregation: config.PriceAggregation,
+		taskSource:       config.TaskSource,
+		taskStartVersion: config.TaskStartVersion,
+		seenTasks:        newSeenTasks(),
+		client:           client,
+		adminAddress:     config.AdminAddress,
+		metricsAddress:   config.MetricsAddress,
+		errors:           errorRecorder,
 	}
 	return &operator, nil
 }
@@ -119,7 +114,7 @@ func InitQuorum(
 	maxOperatorCount uint32,
 	minimumStake big.Int,
 ) error {
-	client, err := aptos.NewClient(networkConfig)
+	client, err := avsconfig.NewClient(networkConfig)
 	if err != nil {
 		panic("Failed to create client:" + err.Error())
 	}
@@ -130,7 +125,7 @@ func InitQuorum(
 		panic("Failed to parse account address " + err.Error())
 	}
 
-	operatorAccount, err := SignerFromConfig(accountConfig.configPath, accountConfig.profile)
+	operatorAccount, err := LoadAccount(config, accountConfig)
 	if err != nil {
 		panic("Failed to create operator account:" + err.Error())
 	}
@@ -220,7 +215,7 @@ func QuorumCount(client *aptos.Client, contract aptos.AccountAddress) uint8 {
 		Args:     [][]byte{},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
 		panic("Could not get quorum count:" + err.Error())
 	}
@@ -234,9 +229,19 @@ func IsOperatorRegistered(client *aptos.Client, contract aptos.AccountAddress, o
 	if err != nil {
 		panic("Could not ParseStringRelaxed:" + err.Error())
 	}
+	status, err := GetOperatorStatus(client, contract, account)
+	if err != nil {
+		panic("Could not get operator status:" + err.Error())
+	}
+	return status != 0
+}
+
+// GetOperatorStatus is the registry_coordinator status of an operator, 0 when it
+// is not registered.
+func GetOperatorStatus(client *aptos.Client, contract aptos.AccountAddress, account aptos.AccountAddress) (uint8, error) {
 	operator, err := bcs.Serialize(&account)
 	if err != nil {
-		panic("Could not serialize operator address:" + err.Error())
+		return 0, fmt.Errorf("could not serialize operator address: %v", err)
 	}
 	payload := &aptos.ViewPayload{
 		Module: aptos.ModuleId{
@@ -250,76 +255,55 @@ func IsOperatorRegistered(client *aptos.Client, contract aptos.AccountAddress, o
 		},
 	}
 
-	vals, err := client.View(payload)
+	vals, err := metrics.View(client, payload)
 	if err != nil {
-		panic("Could not get operator status:" + err.Error())
+		return 0, err
 	}
-	status := vals[0].(float64)
-	return status != 0
+	status, ok := vals[0].(float64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected operator status %v", vals[0])
+	}
+	return uint8(status), nil
 }
 
+// RegisterOperator registers the operator in quorumNumbers, which must be in
+// ascending order. It returns the transaction hash once committed.
 func RegisterOperator(
 	client *aptos.Client,
 	operatorAccount *aptos.Account,
 	contractAddr string,
-	quorumNumbers uint8,
+	quorumNumbers []uint8,
 	signature []byte,
 	pubkey []byte,
 	proofPossession []byte,
-) error {
-	contract := aptos.AccountAddress{}
-	err := contract.ParseStringRelaxed(contractAddr)
+) (string, error) {
+	payload, err := RegisterOperatorPayload(contractAddr, quorumNumbers, signature, pubkey, proofPossession)
 	if err != nil {
-		panic("Failed to parse address:" + err.Error())
+		return "", err
 	}
-	quorumSerializer := &bcs.Serializer{}
-	bcs.SerializeSequence([]U8Struct{
-		{
-			Value: quorumNumbers,
-		},
-	}, quorumSerializer)
+	fmt.Printf("Submit register operator for %s\n", operatorAccount.AccountAddress())
+	return SubmitPayload(client, operatorAccount, payload)
+}
 
-	sig, err := bcs.SerializeBytes(signature)
-	if err != nil {
-		panic("Failed to bcs serialize signature:" + err.Error())
-	}
-	pk, err := bcs.SerializeBytes(pubkey)
-	if err != nil {
-		panic("Failed to bcs serialize pubkey:" + err.Error())
-	}
-	pop, err := bcs.SerializeBytes(proofPossession)
-	if err != nil {
-		panic("Failed to bcs serialize proof of possession:" + err.Error())
-	}
-	payload := aptos.EntryFunction{
-		Module: aptos.ModuleId{
-			Address: contract,
-			Name:    "registry_coordinator",
-		},
-		Function: "registor_operator",
-		ArgTypes: []aptos.TypeTag{},
-		Args: [][]byte{
-			quorumSerializer.ToBytes(), sig, pk, pop,
-		},
-	}
+// SubmitPayload sends a transaction from account and returns its hash once
+// committed.
+func SubmitPayload(client *aptos.Client, account *aptos.Account, payload aptos.TransactionPayload) (string, error) {
 	// Build transaction
-	rawTxn, err := client.BuildTransaction(operatorAccount.AccountAddress(),
-		aptos.TransactionPayload{Payload: &payload})
+	rawTxn, err := client.BuildTransaction(account.AccountAddress(), payload)
 	if err != nil {
-		panic("Failed to build transaction:" + err.Error())
+		return "", fmt.Errorf("failed to build transaction: %v", err)
 	}
 
 	// Sign transaction
-	signedTxn, err := rawTxn.SignedTransaction(operatorAccount)
+	signedTxn, err := rawTxn.SignedTransaction(account)
 	if err != nil {
-		panic("Failed to sign transaction:" + err.Error())
+		return "", fmt.Errorf("failed to sign transaction: %v", err)
 	}
-	fmt.Printf("Submit register operator for %s\n", operatorAccount.AccountAddress())
 
 	// Submit and wait for it to complete
 	submitResult, err := client.SubmitTransaction(signedTxn)
 	if err != nil {
-		panic("Failed to submit transaction:" + err.Error())
+		return "", fmt.Errorf("failed to submit transaction: %v", err)
 	}
 	txnHash := submitResult.Hash
 
@@ -327,12 +311,49 @@ func RegisterOperator(
 	fmt.Printf("And we wait for the transaction %s to complete...\n", txnHash)
 	userTxn, err := client.WaitForTransaction(txnHash)
 	if err != nil {
-		panic("Failed to wait for transaction:" + err.Error())
+		return txnHash, fmt.Errorf("failed to wait for transaction: %v", err)
 	}
 	fmt.Printf("The transaction completed with hash: %s and version %d\n", userTxn.Hash, userTxn.Version)
 	if !userTxn.Success {
-		// TODO: log something more
-		panic("Failed to register operator")
+		return userTxn.Hash, transactionError(userTxn.VmStatus)
 	}
-	return nil
+	return userTxn.Hash, nil
+}
+
+func RegisterOperatorPayload(contractAddr string, quorumNumbers []uint8, signature []byte, pubkey []byte, proofPossession []byte) (aptos.TransactionPayload, error) {
+	contract := aptos.AccountAddress{}
+	err := contract.ParseStringRelaxed(contractAddr)
+	if err != nil {
+		return aptos.TransactionPayload{}, fmt.Errorf("failed to parse address: %v", err)
+	}
+	quorums := make([]U8Struct, 0, len(quorumNumbers))
+	for _, quorum := range quorumNumbers {
+		quorums = append(quorums, U8Struct{Value: quorum})
+	}
+	quorumSerializer := &bcs.Serializer{}
+	bcs.SerializeSequence(quorums, quorumSerializer)
+
+	sig, err := bcs.SerializeBytes(signature)
+	if err != nil {
+		return aptos.TransactionPayload{}, fmt.Errorf("failed to bcs serialize signature: %v", err)
+	}
+	pk, err := bcs.SerializeBytes(pubkey)
+	if err != nil {
+		return aptos.TransactionPayload{}, fmt.Errorf("failed to bcs serialize pubkey: %v", err)
+	}
+	pop, err := bcs.SerializeBytes(proofPossession)
+	if err != nil {
+		return aptos.TransactionPayload{}, fmt.Errorf("failed to bcs serialize proof of possession: %v", err)
+	}
+	return aptos.TransactionPayload{Payload: &aptos.EntryFunction{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "registry_coordinator",
+		},
+		Function: "registor_operator",
+		ArgTypes: []aptos.TypeTag{},
+		Args: [][]byte{
+			quorumSerializer.ToBytes(), sig, pk, pop,
+		},
+	}}, nil
 }
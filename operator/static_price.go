@@ -0,0 +1,36 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StaticPriceSource serves fixed prices from the config. It is meant for
+// local networks and tests where no vendor should be queried.
+type StaticPriceSource struct {
+	prices map[string]float64
+}
+
+func NewStaticPriceSource(config PriceSourceConfig) *StaticPriceSource {
+	prices := make(map[string]float64, len(config.Prices))
+	for symbol, price := range config.Prices {
+		prices[strings.ToUpper(symbol)] = price
+	}
+	return &StaticPriceSource{
+		prices: prices,
+	}
+}
+
+func (s *StaticPriceSource) Name() string {
+	return PriceSourceStatic
+}
+
+func (s *StaticPriceSource) Price(ctx context.Context, symbol string) (float64, time.Time, error) {
+	price, ok := s.prices[strings.ToUpper(symbol)]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("no static price configured for %s", symbol)
+	}
+	return price, time.Now(), nil
+}
@@ -0,0 +1,502 @@
+package operator
+
+import (
+	"avs/avsconfig"
+	"avs/metrics"
+	"avs/signer"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/bcs"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	flagOutput      = "output"
+	flagPriceSymbol = "price-symbol"
+
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+// StatusReport is what `avs operator status` shows. Fields that could not be
+// read are left empty and their error is listed in Errors.
+type StatusReport struct {
+	OperatorAddress string              `json:"operator_address"`
+	AvsAddress      string              `json:"avs_address"`
+	Network         string              `json:"network"`
+	Registered      bool                `json:"registered"`
+	OperatorStatus  uint8               `json:"operator_status"`
+	OperatorId      string              `json:"operator_id,omitempty"`
+	BlsPubkey       string              `json:"bls_pubkey"`
+	QuorumBitmap    string              `json:"quorum_bitmap,omitempty"`
+	Quorums         []QuorumStake       `json:"quorums"`
+	Aggregator      AggregatorHealth    `json:"aggregator"`
+	PriceSources    []PriceSourceHealth `json:"price_sources"`
+	Errors          []string            `json:"errors,omitempty"`
+}
+
+type QuorumStake struct {
+	Quorum       uint8  `json:"quorum"`
+	InBitmap     *bool  `json:"in_bitmap"`
+	Stake        string `json:"stake"`
+	TotalStake   string `json:"total_stake"`
+	MinimumStake string `json:"minimum_stake"`
+}
+
+type AggregatorHealth struct {
+	Address       string `json:"address"`
+	Reachable     bool   `json:"reachable"`
+	InOperatorSet bool   `json:"in_operator_set"`
+	Error         string `json:"error,omitempty"`
+}
+
+type PriceSourceHealth struct {
+	Source    string  `json:"source"`
+	Healthy   bool    `json:"healthy"`
+	Price     float64 `json:"price,omitempty"`
+	UpdatedAt string  `json:"updated_at,omitempty"`
+	LatencyMs int64   `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func ShowStatus(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the registration, stake, aggregator and price source status of the operator",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString(flagOutput)
+			if err != nil {
+				return errors.Wrap(err, flagOutput)
+			}
+			if output != OutputText && output != OutputJson {
+				return fmt.Errorf("unknown output %q, choose %s or %s", output, OutputText, OutputJson)
+			}
+			symbol, err := cmd.Flags().GetString(flagPriceSymbol)
+			if err != nil {
+				return errors.Wrap(err, flagPriceSymbol)
+			}
+			passwordFile, err := cmd.Flags().GetString(flagBlsPasswordFile)
+			if err != nil {
+				return errors.Wrap(err, flagBlsPasswordFile)
+			}
+
+			nodeConfig, err := loadNodeConfig(cmd)
+			if err != nil {
+				return err
+			}
+			operatorConfig := &nodeConfig.Operator
+			operatorAccount, err := LoadAccount(*operatorConfig, nodeConfig.Account)
+			if err != nil {
+				return fmt.Errorf("can not load operator account: %s", err)
+			}
+
+			blsSigner, err := LoadBlsSigner(logger, *operatorConfig, passwordFile)
+			if err != nil {
+				return err
+			}
+
+			report, err := CollectStatus(cmd.Context(), nodeConfig.Network, *operatorConfig, operatorAccount, blsSigner, strings.ToUpper(symbol))
+			if err != nil {
+				return err
+			}
+			if output == OutputJson {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+			report.Print(os.Stdout)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAptosConfigPath, ".aptos/config.yaml", "the path to your operator priv and pub key")
+	cmd.Flags().String(flagAccountProfile, "default", "the account profile to use")
+	cmd.Flags().String(flagAptosNetwork, "devnet", "choose network to connect to: mainnet, testnet, devnet, localnet")
+	cmd.Flags().String(flagAvsOperatorConfig, "config/operator-config.json", "see the example at config/example.json")
+	cmd.Flags().StringP(flagOutput, "o", OutputText, "output format: text or json")
+	cmd.Flags().String(flagPriceSymbol, "ETH", "the symbol the price sources are checked with")
+	cmd.Flags().String(flagBlsPasswordFile, "", "file holding the BLS keystore password (default $AVS_BLS_PASSWORD or a prompt)")
+	return cmd
+}
+
+// CollectStatus gathers the status report. Only a configuration that can not
+// be used at all is an error, everything that fails against the chain, the
+// aggregator or a price source is reported.
+func CollectStatus(ctx context.Context, networkConfig aptos.NetworkConfig, config OperatorConfig, account *aptos.Account, blsSigner signer.BlsSigner, symbol string) (*StatusReport, error) {
+	client, err := avsconfig.NewClient(networkConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aptos client: %v", err)
+	}
+	avsAddress := aptos.AccountAddress{}
+	if err := avsAddress.ParseStringRelaxed(config.AvsAddress); err != nil {
+		return nil, fmt.Errorf("failed to parse avs address: %v", err)
+	}
+	blsPubkey := blsSigner.PublicKey()
+
+	report := &StatusReport{
+		OperatorAddress: account.Address.String(),
+		AvsAddress:      avsAddress.String(),
+		Network:         networkConfig.Name,
+		BlsPubkey:       "0x" + hex.EncodeToString(blsPubkey),
+		Quorums:         []QuorumStake{},
+		PriceSources:    []PriceSourceHealth{},
+	}
+	addError := func(what string, err error) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	report.OperatorStatus, err = GetOperatorStatus(client, avsAddress, account.Address)
+	if err != nil {
+		addError("operator status", err)
+	}
+	report.Registered = report.OperatorStatus == 1
+
+	var operatorId []byte
+	var quorumBitmap *big.Int
+	var bitmapQuorums []uint8
+	if report.Registered {
+		operatorId, err = GetOperatorId(client, avsAddress, account.Address)
+		if err != nil {
+			addError("operator id", err)
+		} else {
+			report.OperatorId = "0x" + hex.EncodeToString(operatorId)
+			quorumBitmap, err = GetQuorumBitmap(client, avsAddress, operatorId)
+			if err != nil {
+				addError("quorum bitmap", err)
+			} else {
+				report.QuorumBitmap = quorumBitmap.String()
+				bitmapQuorums = BitmapQuorums(quorumBitmap)
+			}
+		}
+	}
+
+	quorumCount, err := quorumCount(client, avsAddress)
+	if err != nil {
+		addError("quorum count", err)
+	}
+	now := uint64(time.Now().Unix())
+	for quorum := uint8(1); quorum <= quorumCount && quorum != 0; quorum++ {
+		quorumStake := QuorumStake{Quorum: quorum}
+		if quorumBitmap != nil {
+			inBitmap := false
+			for _, q := range bitmapQuorums {
+				inBitmap = inBitmap || q == quorum
+			}
+			quorumStake.InBitmap = &inBitmap
+		}
+		if operatorId != nil {
+			// aborts when the operator never had stake in the quorum
+			if stake, err := GetStakeAtTimestamp(client, avsAddress, quorum, now, operatorId); err == nil {
+				quorumStake.Stake = stake.String()
+			} else {
+				quorumStake.Stake = "0"
+			}
+		}
+		if total, err := TotalStakeAtTimestamp(client, avsAddress, quorum, now); err == nil {
+			quorumStake.TotalStake = total.String()
+		} else {
+			addError(fmt.Sprintf("total stake of quorum %d", quorum), err)
+		}
+		if minimum, err := MinimumStake(client, avsAddress, quorum); err == nil {
+			quorumStake.MinimumStake = minimum.String()
+		} else {
+			addError(fmt.Sprintf("minimum stake of quorum %d", quorum), err)
+		}
+		report.Quorums = append(report.Quorums, quorumStake)
+	}
+
+	report.Aggregator = checkAggregatorHealth(config, account, blsSigner)
+	report.PriceSources, err = checkPriceSources(ctx, config.PriceSources, symbol)
+	if err != nil {
+		addError("price sources", err)
+	}
+	return report, nil
+}
+
+func checkAggregatorHealth(config OperatorConfig, account *aptos.Account, blsSigner signer.BlsSigner) AggregatorHealth {
+	health := AggregatorHealth{Address: config.AggregatorIpPortAddr}
+	auth, err := newAggregatorAuth(config.AggregatorAuth, account, blsSigner)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	client, err := NewAggregatorRpcClient(config.AggregatorIpPortAddr, config.AggregatorTls, auth)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	client.httpClient.Timeout = PriceSourceTimeout
+	operatorSet, err := client.OperatorSet()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Reachable = true
+	for _, operator := range operatorSet.Operators {
+		if bytes.Equal(operator.Pubkey, blsSigner.PublicKey()) {
+			health.InOperatorSet = true
+		}
+	}
+	return health
+}
+
+func checkPriceSources(ctx context.Context, configs []PriceSourceConfig, symbol string) ([]PriceSourceHealth, error) {
+	sources, err := NewPriceSources(configs)
+	if err != nil {
+		return []PriceSourceHealth{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, PriceSourceTimeout)
+	defer cancel()
+
+	healths := make([]PriceSourceHealth, 0, len(sources))
+	for _, source := range sources {
+		health := PriceSourceHealth{Source: source.Name()}
+		start := time.Now()
+		price, updatedAt, err := source.Price(ctx, symbol)
+		health.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			health.Error = err.Error()
+		} else {
+			health.Healthy = true
+			health.Price = price
+			health.UpdatedAt = updatedAt.Format(time.RFC3339)
+		}
+		healths = append(healths, health)
+	}
+	return healths, nil
+}
+
+func (r *StatusReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Operator:        %s\n", r.OperatorAddress)
+	fmt.Fprintf(w, "AVS:             %s (%s)\n", r.AvsAddress, r.Network)
+	fmt.Fprintf(w, "Registered:      %t (status %d)\n", r.Registered, r.OperatorStatus)
+	fmt.Fprintf(w, "Operator id:     %s\n", valueOr(r.OperatorId, "-"))
+	fmt.Fprintf(w, "BLS pubkey:      %s\n", r.BlsPubkey)
+	fmt.Fprintf(w, "Quorum bitmap:   %s\n", valueOr(r.QuorumBitmap, "-"))
+	fmt.Fprintln(w, "Quorums:")
+	for _, quorum := range r.Quorums {
+		inBitmap := "-"
+		if quorum.InBitmap != nil {
+			inBitmap = fmt.Sprint(*quorum.InBitmap)
+		}
+		fmt.Fprintf(w, "  %d: in bitmap %s, stake %s of %s, minimum %s\n", quorum.Quorum, inBitmap,
+			valueOr(quorum.Stake, "-"), valueOr(quorum.TotalStake, "-"), valueOr(quorum.MinimumStake, "-"))
+	}
+	if r.Aggregator.Reachable {
+		fmt.Fprintf(w, "Aggregator:      %s reachable, in operator set %t\n", r.Aggregator.Address, r.Aggregator.InOperatorSet)
+	} else {
+		fmt.Fprintf(w, "Aggregator:      %s unreachable: %s\n", r.Aggregator.Address, r.Aggregator.Error)
+	}
+	fmt.Fprintln(w, "Price sources:")
+	for _, source := range r.PriceSources {
+		if source.Healthy {
+			fmt.Fprintf(w, "  %s: ok, %f updated at %s (%d ms)\n", source.Source, source.Price, source.UpdatedAt, source.LatencyMs)
+		} else {
+			fmt.Fprintf(w, "  %s: failing: %s (%d ms)\n", source.Source, source.Error, source.LatencyMs)
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w, "Errors:")
+		for _, err := range r.Errors {
+			fmt.Fprintf(w, "  %s\n", err)
+		}
+	}
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// quorumCount is QuorumCount returning the error instead of panicking.
+func quorumCount(client *aptos.Client, contract aptos.AccountAddress) (uint8, error) {
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "registry_coordinator",
+		},
+		Function: "quorum_count",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{},
+	})
+	if err != nil {
+		return 0, err
+	}
+	count, ok := vals[0].(float64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected quorum count %v", vals[0])
+	}
+	return uint8(count), nil
+}
+
+func GetOperatorId(client *aptos.Client, contract aptos.AccountAddress, operator aptos.AccountAddress) ([]byte, error) {
+	operatorBcs, err := bcs.Serialize(&operator)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "registry_coordinator",
+		},
+		Function: "get_operator_id",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{operatorBcs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	idHex, ok := vals[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected operator id %v", vals[0])
+	}
+	return hex.DecodeString(strings.TrimPrefix(idHex, "0x"))
+}
+
+// GetQuorumBitmap is the latest entry of the bitmap history of an operator.
+// get_current_quorum_bitmap can not be used, it reads operator_bitmap which
+// nothing writes.
+func GetQuorumBitmap(client *aptos.Client, contract aptos.AccountAddress, operatorId []byte) (*big.Int, error) {
+	operatorIdBcs, err := bcs.SerializeBytes(operatorId)
+	if err != nil {
+		return nil, err
+	}
+	// the latest entry is the last one updated before the end of time
+	timestampBcs, err := bcs.SerializeU64(math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "registry_coordinator",
+		},
+		Function: "get_quorum_bitmap_by_timestamp",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{operatorIdBcs, timestampBcs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseBigInt(vals[0])
+}
+
+// BitmapQuorums lists the quorums in a registry_coordinator bitmap, in
+// ascending order. Registration stores bytes32_to_u256(quorum_numbers), so
+// byte i of the bitmap, least significant first, holds a quorum number
+// rather than bit n standing for quorum n.
+func BitmapQuorums(bitmap *big.Int) []uint8 {
+	var quorums []uint8
+	seen := make(map[uint8]bool)
+	b := bitmap.Bytes()
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] != 0 && !seen[b[i]] {
+			seen[b[i]] = true
+			quorums = append(quorums, b[i])
+		}
+	}
+	sort.Slice(quorums, func(i, j int) bool { return quorums[i] < quorums[j] })
+	return quorums
+}
+
+func GetStakeAtTimestamp(client *aptos.Client, contract aptos.AccountAddress, quorum uint8, timestamp uint64, operatorId []byte) (*big.Int, error) {
+	quorumBcs, err := bcs.SerializeU8(quorum)
+	if err != nil {
+		return nil, err
+	}
+	timestampBcs, err := bcs.SerializeU64(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	operatorIdBcs, err := bcs.SerializeBytes(operatorId)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "stake_registry",
+		},
+		Function: "get_stake_at_timestamp",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{quorumBcs, timestampBcs, operatorIdBcs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseBigInt(vals[0])
+}
+
+func TotalStakeAtTimestamp(client *aptos.Client, contract aptos.AccountAddress, quorum uint8, timestamp uint64) (*big.Int, error) {
+	quorumBcs, err := bcs.SerializeU8(quorum)
+	if err != nil {
+		return nil, err
+	}
+	timestampBcs, err := bcs.SerializeU64(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "stake_registry",
+		},
+		Function: "total_stake_at_timestamp",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{quorumBcs, timestampBcs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseBigInt(vals[0])
+}
+
+func MinimumStake(client *aptos.Client, contract aptos.AccountAddress, quorum uint8) (*big.Int, error) {
+	quorumBcs, err := bcs.SerializeU8(quorum)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := metrics.View(client, &aptos.ViewPayload{
+		Module: aptos.ModuleId{
+			Address: contract,
+			Name:    "stake_registry",
+		},
+		Function: "minimum_stake",
+		ArgTypes: []aptos.TypeTag{},
+		Args:     [][]byte{quorumBcs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseBigInt(vals[0])
+}
+
+// parseBigInt reads a u128 or u256, which view functions return as decimal
+// strings.
+func parseBigInt(value interface{}) (*big.Int, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected integer %v", value)
+	}
+	n, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		return nil, fmt.Errorf("can not parse integer %q", str)
+	}
+	return n, nil
+}
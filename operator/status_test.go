@@ -0,0 +1,57 @@
+package operator
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// bytes32ToU256 is math_utils::bytes32_to_u256, which register_operator_internal
+// turns the quorum numbers of a registration into the bitmap with.
+func bytes32ToU256(quorumNumbers []uint8) *big.Int {
+	bitmap := new(big.Int)
+	for i, quorum := range quorumNumbers {
+		bitmap.Or(bitmap, new(big.Int).Lsh(big.NewInt(int64(quorum)), uint(i*8)))
+	}
+	return bitmap
+}
+
+func TestBitmapQuorums(t *testing.T) {
+	tests := []struct {
+		name       string
+		registered []uint8
+		bitmap     string
+		quorums    []uint8
+	}{
+		{"quorum 1", []uint8{1}, "1", []uint8{1}},
+		{"quorum 2", []uint8{2}, "2", []uint8{2}},
+		{"quorum 3", []uint8{3}, "3", []uint8{3}},
+		{"quorums 1 and 2", []uint8{1, 2}, "513", []uint8{1, 2}},
+		{"quorums 1 and 3", []uint8{1, 3}, "769", []uint8{1, 3}},
+		{"quorums 1, 2 and 3", []uint8{1, 2, 3}, "197121", []uint8{1, 2, 3}},
+		{"quorum 255", []uint8{255}, "255", []uint8{255}},
+		{"no quorum", nil, "0", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bitmap, ok := new(big.Int).SetString(test.bitmap, 10)
+			if !ok {
+				t.Fatalf("bad bitmap %q", test.bitmap)
+			}
+			if encoded := bytes32ToU256(test.registered); encoded.Cmp(bitmap) != 0 {
+				t.Fatalf("registering %v gives bitmap %s, want %s", test.registered, encoded, bitmap)
+			}
+			if quorums := BitmapQuorums(bitmap); !reflect.DeepEqual(quorums, test.quorums) {
+				t.Errorf("BitmapQuorums(%s) = %v, want %v", bitmap, quorums, test.quorums)
+			}
+		})
+	}
+}
+
+func TestBitmapQuorumsIsNotBitPerQuorum(t *testing.T) {
+	// bit 1 alone is what quorum 1 would be if bit n stood for quorum n, but
+	// the registration encoding makes it quorum 2
+	if quorums := BitmapQuorums(big.NewInt(2)); !reflect.DeepEqual(quorums, []uint8{2}) {
+		t.Errorf("BitmapQuorums(2) = %v, want [2]", quorums)
+	}
+}
@@ -1,8 +1,14 @@
 package operator
 
 import (
+	"avs/admin"
+	"avs/signer"
+	"avs/slashing"
 	"math/big"
-	"net/rpc"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	aptos "github.com/aptos-labs/aptos-go-sdk"
 	"github.com/aptos-labs/aptos-go-sdk/bcs"
@@ -19,12 +25,32 @@ type Operator struct {
 	logger  *zap.Logger
 	account *aptos.Account
 	// TODO: change this to aptos-sdk fork
-	operatorId   []byte
-	avsAddress   aptos.AccountAddress
-	BlsPrivateKey        []byte
-	AggRpcClient AggregatorRpcClient
-	network      aptos.NetworkConfig
-	TaskQueue    chan Task
+	operatorId       []byte
+	avsAddress       aptos.AccountAddress
+	BlsSigner        signer.BlsSigner
+	slashingDb       *slashing.Store
+	AggRpcClient     *AggregatorRpcClient
+	network          aptos.NetworkConfig
+	TaskQueue        chan Task
+	PriceSources     []PriceSource
+	PriceAggregation PriceAggregationConfig
+	taskSource       string
+	taskStartVersion uint64
+	seenTasks        *seenTasks
+	pushConnected    atomic.Bool
+	client           *aptos.Client
+	adminAddress     string
+	metricsAddress   string
+	errors           *admin.ErrorRecorder
+	// lastFetch is the unix time the chain was last polled for tasks
+	lastFetch atomic.Int64
+	// aggregatorView caches the aggregator's view of the recent tasks shown
+	// by /status
+	aggregatorView struct {
+		mu        sync.Mutex
+		tasks     []TaskProgress
+		checkedAt time.Time
+	}
 }
 
 type Task struct {
@@ -33,14 +59,40 @@ type Task struct {
 }
 
 type AggregatorRpcClient struct {
-	rpcClient            *rpc.Client
+	httpClient           *http.Client
+	url                  string
 	aggregatorIpPortAddr string
+	auth                 *AggregatorAuth
+
+	mu             sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
 }
 
 type OperatorConfig struct {
-	BlsPrivateKey        []byte
+	// BlsKeystore is the path of the EIP-2335 keystore holding the BLS key
+	BlsKeystore string `json:",omitempty"`
+	// BlsPrivateKey is the plaintext key of configs written by earlier
+	// releases, move it to a keystore with `avs operator keys import`
+	BlsPrivateKey        []byte `json:",omitempty"`
 	AvsAddress           string
 	AggregatorIpPortAddr string
+	PriceSources         []PriceSourceConfig `json:",omitempty"`
+	PriceAggregation     PriceAggregationConfig
+	TaskSource           string               `json:",omitempty"`
+	TaskStartVersion     uint64               `json:",omitempty"`
+	AggregatorTls        *AggregatorTlsConfig `json:",omitempty"`
+	// AggregatorAuth is the key used to log in to an aggregator requiring
+	// challenge auth: bls, ed25519 or empty
+	AggregatorAuth string `json:",omitempty"`
+	// AdminAddress serves /healthz, /readyz and /status when set
+	AdminAddress string `json:",omitempty"`
+	// MetricsAddress serves the Prometheus metrics on /metrics when set
+	MetricsAddress string `json:",omitempty"`
+	// RemoteSigner signs with keys held by a remote signer when set
+	RemoteSigner *RemoteSignerConfig `json:",omitempty"`
+	// SlashingProtectionDbPath defaults to data/slashing-protection.db
+	SlashingProtectionDbPath string `json:",omitempty"`
 	// OperatorId           eigentypes.OperatorId
 }
 
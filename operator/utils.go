@@ -57,6 +57,17 @@ func loadOperatorConfig(filename string) (*OperatorConfig, error) {
 	return &config, nil
 }
 
+func saveOperatorConfig(filename string, config OperatorConfig) error {
+	bz, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operator config: %v", err)
+	}
+	if err := os.WriteFile(filename, bz, 0o644); err != nil {
+		return fmt.Errorf("failed to write to file at %s: %v", filename, err)
+	}
+	return nil
+}
+
 func extractNetwork(network string) (aptos.NetworkConfig, error) {
 	switch network {
 	case "devnet":
@@ -0,0 +1,255 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+	cosmosbls "github.com/cosmos/crypto/curves/bls12381"
+)
+
+// The remote signer API follows Web3Signer: keys are listed on
+// <prefix>/publicKeys and used on <prefix>/sign/<pubkey>. BLS keys live under
+// the eth2 prefix, Aptos Ed25519 keys under the aptos prefix.
+const (
+	Eth2Prefix  = "/api/v1/eth2"
+	AptosPrefix = "/api/v1/aptos"
+	UpcheckPath = "/upcheck"
+
+	SignTypeMessage           = "MESSAGE"
+	SignTypeProofOfPossession = "PROOF_OF_POSSESSION"
+
+	RequestTimeout = 10 * time.Second
+)
+
+type SignRequest struct {
+	Type        string `json:"type"`
+	SigningRoot string `json:"signingRoot,omitempty"`
+}
+
+type SignResponse struct {
+	Signature string `json:"signature"`
+}
+
+type remoteClient struct {
+	httpClient *http.Client
+	url        string
+	prefix     string
+	pubkey     []byte
+}
+
+// newRemoteClient makes sure the signer holds pubkey before it is used.
+func newRemoteClient(httpClient *http.Client, url string, prefix string, pubkeyHex string) (*remoteClient, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: RequestTimeout}
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+	pubkey, err := hex.DecodeString(strings.TrimPrefix(pubkeyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote signer pubkey %q: %v", pubkeyHex, err)
+	}
+	c := &remoteClient{
+		httpClient: httpClient,
+		url:        strings.TrimSuffix(url, "/"),
+		prefix:     prefix,
+		pubkey:     pubkey,
+	}
+
+	keys, err := c.publicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("can not list remote signer keys: %v", err)
+	}
+	for _, key := range keys {
+		if bytes.Equal(key, pubkey) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("remote signer %s does not hold key 0x%x", c.url, pubkey)
+}
+
+func (c *remoteClient) publicKeys() ([][]byte, error) {
+	resp, err := c.httpClient.Get(c.url + c.prefix + "/publicKeys")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+	var keysHex []string
+	if err := json.NewDecoder(resp.Body).Decode(&keysHex); err != nil {
+		return nil, err
+	}
+	keys := make([][]byte, 0, len(keysHex))
+	for _, keyHex := range keysHex {
+		key, err := hex.DecodeString(strings.TrimPrefix(keyHex, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %v", keyHex, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (c *remoteClient) sign(signType string, msg []byte) ([]byte, error) {
+	request := SignRequest{Type: signType}
+	if msg != nil {
+		request.SigningRoot = "0x" + hex.EncodeToString(msg)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s%s/sign/0x%x", c.url, c.prefix, c.pubkey), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer: %v", statusError(resp))
+	}
+	var response SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	return hex.DecodeString(strings.TrimPrefix(response.Signature, "0x"))
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// RemoteBls signs with a BLS key held by a remote signer. Signatures are
+// verified against the key before they are used.
+type RemoteBls struct {
+	client *remoteClient
+	pubkey cosmosbls.PubKey
+}
+
+func NewRemoteBls(httpClient *http.Client, url string, pubkeyHex string) (*RemoteBls, error) {
+	client, err := newRemoteClient(httpClient, url, Eth2Prefix, pubkeyHex)
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := cosmosbls.PublicKeyFromBytes(client.pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bls pubkey: %v", err)
+	}
+	return &RemoteBls{client: client, pubkey: pubkey}, nil
+}
+
+func (s *RemoteBls) PublicKey() []byte {
+	return s.pubkey.Marshal()
+}
+
+func (s *RemoteBls) Sign(msg []byte) ([]byte, error) {
+	sigBytes, err := s.client.sign(SignTypeMessage, msg)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := cosmosbls.SignatureFromBytes(sigBytes)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer returned an invalid signature: %v", err)
+	}
+	if !signature.Verify(s.pubkey, msg) {
+		return nil, fmt.Errorf("remote signer returned a signature that does not verify")
+	}
+	return sigBytes, nil
+}
+
+func (s *RemoteBls) ProofOfPossession() ([]byte, error) {
+	pop, err := s.client.sign(SignTypeProofOfPossession, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(pop) != crypto.PopLength {
+		return nil, fmt.Errorf("remote signer returned a %d byte proof of possession", len(pop))
+	}
+	return pop, nil
+}
+
+// RemoteEd25519 is a crypto.Signer for an Aptos account whose Ed25519 key is
+// held by a remote signer, use it with aptos.NewAccountFromSigner.
+type RemoteEd25519 struct {
+	client *remoteClient
+	pubkey *crypto.Ed25519PublicKey
+}
+
+var _ crypto.Signer = (*RemoteEd25519)(nil)
+
+func NewRemoteEd25519(httpClient *http.Client, url string, pubkeyHex string) (*RemoteEd25519, error) {
+	client, err := newRemoteClient(httpClient, url, AptosPrefix, pubkeyHex)
+	if err != nil {
+		return nil, err
+	}
+	pubkey := &crypto.Ed25519PublicKey{}
+	if err := pubkey.FromBytes(client.pubkey); err != nil {
+		return nil, fmt.Errorf("invalid ed25519 pubkey: %v", err)
+	}
+	return &RemoteEd25519{client: client, pubkey: pubkey}, nil
+}
+
+func (s *RemoteEd25519) Sign(msg []byte) (*crypto.AccountAuthenticator, error) {
+	signature, err := s.SignMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.AccountAuthenticator{
+		Variant: crypto.AccountAuthenticatorEd25519,
+		Auth: &crypto.Ed25519Authenticator{
+			PubKey: s.pubkey,
+			Sig:    signature.(*crypto.Ed25519Signature),
+		},
+	}, nil
+}
+
+func (s *RemoteEd25519) SignMessage(msg []byte) (crypto.Signature, error) {
+	sigBytes, err := s.client.sign(SignTypeMessage, msg)
+	if err != nil {
+		return nil, err
+	}
+	signature := &crypto.Ed25519Signature{}
+	if err := signature.FromBytes(sigBytes); err != nil {
+		return nil, fmt.Errorf("remote signer returned an invalid signature: %v", err)
+	}
+	if !s.pubkey.Verify(msg, signature) {
+		return nil, fmt.Errorf("remote signer returned a signature that does not verify")
+	}
+	return signature, nil
+}
+
+func (s *RemoteEd25519) SimulationAuthenticator() *crypto.AccountAuthenticator {
+	return &crypto.AccountAuthenticator{
+		Variant: crypto.AccountAuthenticatorEd25519,
+		Auth: &crypto.Ed25519Authenticator{
+			PubKey: s.pubkey,
+			Sig:    &crypto.Ed25519Signature{},
+		},
+	}
+}
+
+func (s *RemoteEd25519) AuthKey() *crypto.AuthenticationKey {
+	authKey := &crypto.AuthenticationKey{}
+	authKey.FromPublicKey(s.pubkey)
+	return authKey
+}
+
+func (s *RemoteEd25519) PubKey() crypto.PublicKey {
+	return s.pubkey
+}
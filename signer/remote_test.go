@@ -0,0 +1,206 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aptos-labs/aptos-go-sdk"
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+func newLocalBls(t *testing.T) *LocalBls {
+	t.Helper()
+	key, err := crypto.GenerateBlsPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LocalBls{priv: *key}
+}
+
+func newEd25519(t *testing.T) *crypto.Ed25519PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateEd25519PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newSignerServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteBlsRoundTrip(t *testing.T) {
+	local := newLocalBls(t)
+	server := newSignerServer(t, NewServer(local, nil).Handler())
+
+	remote, err := NewRemoteBls(server.Client(), server.URL, "0x"+hex.EncodeToString(local.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(remote.PublicKey(), local.PublicKey()) {
+		t.Errorf("remote pubkey %x, want %x", remote.PublicKey(), local.PublicKey())
+	}
+
+	msg := []byte("task 1 response 2450120000")
+	signature, err := remote.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := local.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signature, want) {
+		t.Errorf("remote signature %x, want %x", signature, want)
+	}
+
+	pop, err := remote.ProofOfPossession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPop, err := local.ProofOfPossession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pop, wantPop) {
+		t.Errorf("remote proof of possession %x, want %x", pop, wantPop)
+	}
+}
+
+func TestRemoteBlsUnknownKey(t *testing.T) {
+	server := newSignerServer(t, NewServer(newLocalBls(t), nil).Handler())
+	other := newLocalBls(t)
+	if _, err := NewRemoteBls(server.Client(), server.URL, hex.EncodeToString(other.PublicKey())); err == nil {
+		t.Error("NewRemoteBls should fail for a key the signer does not hold")
+	}
+}
+
+func TestRemoteBlsRejectsBadSignature(t *testing.T) {
+	local := newLocalBls(t)
+	other := newLocalBls(t)
+	// lists the key of local but signs with other
+	mux := http.NewServeMux()
+	mux.Handle("GET "+Eth2Prefix+"/publicKeys", NewServer(local, nil).Handler())
+	mux.HandleFunc("POST "+Eth2Prefix+"/sign/{identifier}", func(w http.ResponseWriter, r *http.Request) {
+		var request SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		msg, _ := hex.DecodeString(request.SigningRoot[2:])
+		signature, err := other.Sign(msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, SignResponse{Signature: "0x" + hex.EncodeToString(signature)})
+	})
+	server := newSignerServer(t, mux)
+
+	remote, err := NewRemoteBls(server.Client(), server.URL, hex.EncodeToString(local.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Sign([]byte("message")); err == nil {
+		t.Error("Sign should reject a signature that does not verify")
+	}
+}
+
+func TestRemoteEd25519Account(t *testing.T) {
+	key := newEd25519(t)
+	server := newSignerServer(t, NewServer(nil, key).Handler())
+
+	remote, err := NewRemoteEd25519(server.Client(), server.URL, key.PubKey().ToHex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := aptos.NewAccountFromSigner(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localAccount, err := aptos.NewAccountFromSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Address != localAccount.Address {
+		t.Errorf("remote account %s, want %s", account.Address.String(), localAccount.Address.String())
+	}
+
+	msg := []byte("message")
+	signature, err := remote.SignMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PubKey().Verify(msg, signature) {
+		t.Error("remote signature does not verify")
+	}
+
+	rawTxn := &aptos.RawTransaction{
+		Sender:         account.Address,
+		SequenceNumber: 7,
+		Payload: aptos.TransactionPayload{Payload: &aptos.EntryFunction{
+			Module:   aptos.ModuleId{Address: aptos.AccountOne, Name: "aptos_account"},
+			Function: "transfer",
+			ArgTypes: []aptos.TypeTag{},
+			Args:     [][]byte{},
+		}},
+		MaxGasAmount:               1000,
+		GasUnitPrice:               100,
+		ExpirationTimestampSeconds: 1,
+		ChainId:                    4,
+	}
+	signedTxn, err := rawTxn.SignedTransaction(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signedTxn.Verify(); err != nil {
+		t.Errorf("transaction signed through the remote signer does not verify: %v", err)
+	}
+}
+
+func TestRemoteEd25519RejectsBadSignature(t *testing.T) {
+	key := newEd25519(t)
+	other := newEd25519(t)
+	mux := http.NewServeMux()
+	mux.Handle("GET "+AptosPrefix+"/publicKeys", NewServer(nil, key).Handler())
+	mux.HandleFunc("POST "+AptosPrefix+"/sign/{identifier}", func(w http.ResponseWriter, r *http.Request) {
+		signature, err := other.SignMessage([]byte("message"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, SignResponse{Signature: signature.ToHex()})
+	})
+	server := newSignerServer(t, mux)
+
+	remote, err := NewRemoteEd25519(server.Client(), server.URL, key.PubKey().ToHex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.SignMessage([]byte("message")); err == nil {
+		t.Error("SignMessage should reject a signature that does not verify")
+	}
+}
+
+func TestServerRejectsUnknownKey(t *testing.T) {
+	local := newLocalBls(t)
+	server := newSignerServer(t, NewServer(local, nil).Handler())
+	other := newLocalBls(t)
+	body, _ := json.Marshal(SignRequest{Type: SignTypeMessage, SigningRoot: "0x00"})
+	resp, err := server.Client().Post(server.URL+Eth2Prefix+"/sign/0x"+hex.EncodeToString(other.PublicKey()), "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("signing with a key the server does not hold: %s, want 404", resp.Status)
+	}
+}
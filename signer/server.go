@@ -0,0 +1,120 @@
+package signer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+// Server is a stand-in remote signer serving keys held in memory, for tests.
+// It signs anything for anyone and must not be exposed. Either key may be
+// nil.
+type Server struct {
+	bls     *LocalBls
+	ed25519 *crypto.Ed25519PrivateKey
+}
+
+func NewServer(bls *LocalBls, ed25519 *crypto.Ed25519PrivateKey) *Server {
+	return &Server{bls: bls, ed25519: ed25519}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+UpcheckPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET "+Eth2Prefix+"/publicKeys", func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{}
+		if s.bls != nil {
+			keys = append(keys, "0x"+hex.EncodeToString(s.bls.PublicKey()))
+		}
+		writeJson(w, http.StatusOK, keys)
+	})
+	mux.HandleFunc("GET "+AptosPrefix+"/publicKeys", func(w http.ResponseWriter, r *http.Request) {
+		keys := []string{}
+		if s.ed25519 != nil {
+			keys = append(keys, s.ed25519.PubKey().ToHex())
+		}
+		writeJson(w, http.StatusOK, keys)
+	})
+	mux.HandleFunc("POST "+Eth2Prefix+"/sign/{identifier}", s.signBls)
+	mux.HandleFunc("POST "+AptosPrefix+"/sign/{identifier}", s.signEd25519)
+	return mux
+}
+
+func (s *Server) signBls(w http.ResponseWriter, r *http.Request) {
+	if s.bls == nil || !samePubkey(r.PathValue("identifier"), s.bls.PublicKey()) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	request, msg, ok := readSignRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var signature []byte
+	var err error
+	switch request.Type {
+	case SignTypeMessage:
+		signature, err = s.bls.Sign(msg)
+	case SignTypeProofOfPossession:
+		signature, err = s.bls.ProofOfPossession()
+	default:
+		http.Error(w, "unknown sign type "+request.Type, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, http.StatusOK, SignResponse{Signature: "0x" + hex.EncodeToString(signature)})
+}
+
+func (s *Server) signEd25519(w http.ResponseWriter, r *http.Request) {
+	if s.ed25519 == nil || !samePubkey(r.PathValue("identifier"), s.ed25519.PubKey().Bytes()) {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	request, msg, ok := readSignRequest(w, r)
+	if !ok {
+		return
+	}
+	if request.Type != SignTypeMessage {
+		http.Error(w, "unknown sign type "+request.Type, http.StatusBadRequest)
+		return
+	}
+
+	signature, err := s.ed25519.SignMessage(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, http.StatusOK, SignResponse{Signature: signature.ToHex()})
+}
+
+func readSignRequest(w http.ResponseWriter, r *http.Request) (SignRequest, []byte, bool) {
+	var request SignRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return request, nil, false
+	}
+	msg, err := hex.DecodeString(strings.TrimPrefix(request.SigningRoot, "0x"))
+	if err != nil {
+		http.Error(w, "invalid signingRoot: "+err.Error(), http.StatusBadRequest)
+		return request, nil, false
+	}
+	return request, msg, true
+}
+
+func samePubkey(identifier string, pubkey []byte) bool {
+	return strings.EqualFold(strings.TrimPrefix(identifier, "0x"), hex.EncodeToString(pubkey))
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,52 @@
+package signer
+
+import (
+	"fmt"
+
+	"github.com/aptos-labs/aptos-go-sdk/crypto"
+)
+
+// BlsSigner signs with the BLS key of an operator, either held in memory or
+// by a remote signer.
+type BlsSigner interface {
+	// PublicKey returns the compressed 48 byte public key.
+	PublicKey() []byte
+	// Sign returns the 96 byte signature of msg.
+	Sign(msg []byte) ([]byte, error)
+	// ProofOfPossession returns the proof of possession registor_operator
+	// takes with the public key.
+	ProofOfPossession() ([]byte, error)
+}
+
+// LocalBls signs with a BLS private key held in memory.
+type LocalBls struct {
+	priv crypto.BlsPrivateKey
+}
+
+func NewLocalBls(privateKey []byte) (*LocalBls, error) {
+	signer := &LocalBls{}
+	if err := signer.priv.FromBytes(privateKey); err != nil {
+		return nil, fmt.Errorf("invalid bls private key: %v", err)
+	}
+	return signer, nil
+}
+
+func (s *LocalBls) PublicKey() []byte {
+	return s.priv.Inner.PublicKey().Marshal()
+}
+
+func (s *LocalBls) Sign(msg []byte) ([]byte, error) {
+	signature, err := s.priv.Sign(msg)
+	if err != nil {
+		return nil, err
+	}
+	return signature.Signature().Bytes(), nil
+}
+
+func (s *LocalBls) ProofOfPossession() ([]byte, error) {
+	pop, err := s.priv.GenerateBlsPop()
+	if err != nil {
+		return nil, err
+	}
+	return pop.Bytes(), nil
+}
@@ -0,0 +1,172 @@
+package slashing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	bolt "go.etcd.io/bbolt"
+)
+
+// InterchangeVersion is the version of the interchange format written by
+// Export and accepted by Import.
+const InterchangeVersion = "1"
+
+// Interchange is the JSON format slashing protection data is moved between
+// machines with, modeled on EIP-3076. Numbers are decimal strings and byte
+// strings 0x prefixed hex:
+//
+//	{
+//	  "metadata": {"interchange_format_version": "1", "exported_at": "2024-10-01T12:00:00Z"},
+//	  "data": [
+//	    {"avs_address": "0x...", "task_id": "12", "signing_root": "0x...",
+//	     "response": "2450120000", "pubkey": "0x...", "signed_at": "2024-10-01T11:59:00Z"}
+//	  ]
+//	}
+type Interchange struct {
+	Metadata InterchangeMetadata `json:"metadata"`
+	Data     []InterchangeRecord `json:"data"`
+}
+
+type InterchangeMetadata struct {
+	InterchangeFormatVersion string    `json:"interchange_format_version"`
+	ExportedAt               time.Time `json:"exported_at"`
+}
+
+type InterchangeRecord struct {
+	AvsAddress  string    `json:"avs_address"`
+	TaskId      string    `json:"task_id"`
+	SigningRoot string    `json:"signing_root"`
+	Response    string    `json:"response,omitempty"`
+	Pubkey      string    `json:"pubkey,omitempty"`
+	SignedAt    time.Time `json:"signed_at"`
+}
+
+// ImportResult counts what Import did with the records.
+type ImportResult struct {
+	Imported  int
+	Known     int
+	Conflicts []string
+}
+
+// Export returns every record, or the records of one AVS when avsAddress is
+// not nil.
+func (s *Store) Export(avsAddress *aptos.AccountAddress) (*Interchange, error) {
+	interchange := &Interchange{
+		Metadata: InterchangeMetadata{
+			InterchangeFormatVersion: InterchangeVersion,
+			ExportedAt:               time.Now().UTC(),
+		},
+		Data: []InterchangeRecord{},
+	}
+	err := s.forEach(func(avs aptos.AccountAddress, taskId uint64, signed SignedResponse) error {
+		if avsAddress != nil && avs != *avsAddress {
+			return nil
+		}
+		record := InterchangeRecord{
+			AvsAddress:  avs.String(),
+			TaskId:      strconv.FormatUint(taskId, 10),
+			SigningRoot: hexBytes(signed.SigningRoot),
+			Response:    signed.Response,
+			SignedAt:    signed.SignedAt,
+		}
+		if len(signed.Pubkey) != 0 {
+			record.Pubkey = hexBytes(signed.Pubkey)
+		}
+		interchange.Data = append(interchange.Data, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return interchange, nil
+}
+
+// Import merges the records into the store. A record for a task the store
+// already holds a different message for is a conflict: the stored message is
+// kept and the conflict reported, since signing either again is safe.
+func (s *Store) Import(interchange *Interchange) (ImportResult, error) {
+	var result ImportResult
+	if interchange.Metadata.InterchangeFormatVersion != InterchangeVersion {
+		return result, fmt.Errorf("unsupported interchange format version %q, expected %q", interchange.Metadata.InterchangeFormatVersion, InterchangeVersion)
+	}
+
+	type parsedRecord struct {
+		key    []byte
+		taskId uint64
+		signed SignedResponse
+	}
+	records := make([]parsedRecord, 0, len(interchange.Data))
+	for i, record := range interchange.Data {
+		avsAddress := aptos.AccountAddress{}
+		if err := avsAddress.ParseStringRelaxed(record.AvsAddress); err != nil {
+			return result, fmt.Errorf("record %d: invalid avs_address: %v", i, err)
+		}
+		taskId, err := strconv.ParseUint(record.TaskId, 10, 64)
+		if err != nil {
+			return result, fmt.Errorf("record %d: invalid task_id: %v", i, err)
+		}
+		signingRoot, err := parseHex(record.SigningRoot)
+		if err != nil || len(signingRoot) == 0 {
+			return result, fmt.Errorf("record %d: invalid signing_root %q", i, record.SigningRoot)
+		}
+		var pubkey []byte
+		if record.Pubkey != "" {
+			if pubkey, err = parseHex(record.Pubkey); err != nil {
+				return result, fmt.Errorf("record %d: invalid pubkey: %v", i, err)
+			}
+		}
+		records = append(records, parsedRecord{
+			key:    taskKey(avsAddress, taskId),
+			taskId: taskId,
+			signed: SignedResponse{
+				SigningRoot: signingRoot,
+				Response:    record.Response,
+				Pubkey:      pubkey,
+				SignedAt:    record.SignedAt,
+			},
+		})
+	}
+
+	// all records are imported in one transaction, a failure imports none
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(signedBucket)
+		for _, record := range records {
+			bz, err := marshalSigned(record.signed)
+			if err != nil {
+				return err
+			}
+			existing := bucket.Get(record.key)
+			if existing == nil {
+				if err := bucket.Put(record.key, bz); err != nil {
+					return err
+				}
+				result.Imported++
+				continue
+			}
+			existingSigned, err := unmarshalSigned(existing)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(existingSigned.SigningRoot, record.signed.SigningRoot) {
+				result.Known++
+				continue
+			}
+			avsAddress, _ := parseTaskKey(record.key)
+			result.Conflicts = append(result.Conflicts, fmt.Sprintf("%s task %d", avsAddress.String(), record.taskId))
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+func parseHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
@@ -0,0 +1,111 @@
+package slashing
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInterchangeRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := openTestStore(t, filepath.Join(dir, "source.db"))
+	avs, other := testAvs(t, "0x1"), testAvs(t, "0x2")
+	signedAt := time.Date(2024, 10, 1, 11, 59, 0, 0, time.UTC)
+	records := map[uint64]SignedResponse{
+		7: {SigningRoot: []byte{1, 2, 3}, Response: "1000", Pubkey: []byte{9, 9}, SignedAt: signedAt},
+		8: {SigningRoot: []byte{4, 5, 6}, Response: "2000", SignedAt: signedAt.Add(time.Minute)},
+	}
+	for taskId, signed := range records {
+		if err := source.CheckAndRecord(avs, taskId, signed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := source.CheckAndRecord(other, 7, SignedResponse{SigningRoot: []byte{7}}); err != nil {
+		t.Fatal(err)
+	}
+
+	interchange, err := source.Export(&avs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interchange.Data) != 2 {
+		t.Fatalf("exported %d records of 0x1, want 2", len(interchange.Data))
+	}
+	// the export is moved as JSON
+	bz, err := json.Marshal(interchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Interchange
+	if err := json.Unmarshal(bz, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	target := openTestStore(t, filepath.Join(dir, "target.db"))
+	result, err := target.Import(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 2 || result.Known != 0 || len(result.Conflicts) != 0 {
+		t.Errorf("import result %+v, want 2 imported", result)
+	}
+	for taskId, signed := range records {
+		stored, err := target.Signed(avs, taskId)
+		if err != nil || stored == nil {
+			t.Fatalf("task %d not imported: %v", taskId, err)
+		}
+		if !reflect.DeepEqual(*stored, signed) {
+			t.Errorf("task %d imported as %+v, want %+v", taskId, *stored, signed)
+		}
+	}
+	if stored, err := target.Signed(other, 7); err != nil || stored != nil {
+		t.Errorf("record of another avs imported: %+v %v", stored, err)
+	}
+
+	// importing the same records again changes nothing
+	result, err = target.Import(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 0 || result.Known != 2 {
+		t.Errorf("second import result %+v, want 2 known", result)
+	}
+}
+
+func TestImportConflict(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "slashing.db"))
+	avs := testAvs(t, "0x1")
+	if err := store.CheckAndRecord(avs, 7, SignedResponse{SigningRoot: []byte{1, 2, 3}, Response: "1000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	interchange := &Interchange{
+		Metadata: InterchangeMetadata{InterchangeFormatVersion: InterchangeVersion},
+		Data: []InterchangeRecord{
+			{AvsAddress: "0x1", TaskId: "7", SigningRoot: "0x040506", Response: "2000"},
+			{AvsAddress: "0x1", TaskId: "8", SigningRoot: "0x070809", Response: "3000"},
+		},
+	}
+	result, err := store.Import(interchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 1 || len(result.Conflicts) != 1 {
+		t.Fatalf("import result %+v, want 1 imported and 1 conflict", result)
+	}
+	stored, err := store.Signed(avs, 7)
+	if err != nil || stored == nil {
+		t.Fatalf("task 7 lost: %v", err)
+	}
+	if stored.Response != "1000" {
+		t.Errorf("conflicting record replaced the stored one: response %s, want 1000", stored.Response)
+	}
+
+	// an unknown format version imports nothing
+	interchange.Metadata.InterchangeFormatVersion = "0"
+	if _, err := store.Import(interchange); err == nil {
+		t.Error("import of an unknown format version accepted")
+	}
+}
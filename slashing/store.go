@@ -0,0 +1,158 @@
+package slashing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const DefaultDbPath = "data/slashing-protection.db"
+
+var signedBucket = []byte("signed_responses")
+
+var ErrConflictingSignature = errors.New("already signed a different response for this task")
+
+// SignedResponse is what the store keeps for every task the operator signed.
+type SignedResponse struct {
+	SigningRoot []byte
+	Response    string
+	Pubkey      []byte
+	SignedAt    time.Time
+}
+
+// Store is the slashing-protection database of an operator: it remembers the
+// message signed for every (AVS address, task id) and refuses to sign a
+// different one. The file is locked while open, so a second operator process
+// using the same database can not start.
+type Store struct {
+	db *bolt.DB
+}
+
+func OpenStore(path string) (*Store, error) {
+	if path == "" {
+		path = DefaultDbPath
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("can not create directory for %s: %v", path, err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("can not open slashing protection database at %s, is another operator using it? %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(signedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can not create buckets: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CheckAndRecord must be called before signingRoot is signed for the task. It
+// records the message and returns ErrConflictingSignature when a different
+// message was signed for the task before. Signing the same message again is
+// allowed.
+func (s *Store) CheckAndRecord(avsAddress aptos.AccountAddress, taskId uint64, signed SignedResponse) error {
+	key := taskKey(avsAddress, taskId)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(signedBucket)
+		if bz := bucket.Get(key); bz != nil {
+			existing, err := unmarshalSigned(bz)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(existing.SigningRoot, signed.SigningRoot) {
+				return nil
+			}
+			return errors.Wrapf(ErrConflictingSignature, "task %d signed with response %s at %s", taskId, existing.Response, existing.SignedAt.Format(time.RFC3339))
+		}
+		bz, err := marshalSigned(signed)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, bz)
+	})
+}
+
+// Signed returns the response recorded for the task, if any.
+func (s *Store) Signed(avsAddress aptos.AccountAddress, taskId uint64) (*SignedResponse, error) {
+	var signed *SignedResponse
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bz := tx.Bucket(signedBucket).Get(taskKey(avsAddress, taskId))
+		if bz == nil {
+			return nil
+		}
+		existing, err := unmarshalSigned(bz)
+		if err != nil {
+			return err
+		}
+		signed = &existing
+		return nil
+	})
+	return signed, err
+}
+
+func (s *Store) forEach(fn func(avsAddress aptos.AccountAddress, taskId uint64, signed SignedResponse) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(signedBucket).ForEach(func(k, v []byte) error {
+			avsAddress, taskId := parseTaskKey(k)
+			signed, err := unmarshalSigned(v)
+			if err != nil {
+				return fmt.Errorf("task %d: %v", taskId, err)
+			}
+			return fn(avsAddress, taskId, signed)
+		})
+	})
+}
+
+// taskKey is the AVS address followed by the big endian task id, so the
+// records of one AVS are stored in task order.
+func taskKey(avsAddress aptos.AccountAddress, taskId uint64) []byte {
+	key := make([]byte, len(avsAddress)+8)
+	copy(key, avsAddress[:])
+	binary.BigEndian.PutUint64(key[len(avsAddress):], taskId)
+	return key
+}
+
+func parseTaskKey(key []byte) (aptos.AccountAddress, uint64) {
+	var avsAddress aptos.AccountAddress
+	copy(avsAddress[:], key)
+	return avsAddress, binary.BigEndian.Uint64(key[len(avsAddress):])
+}
+
+func marshalSigned(signed SignedResponse) ([]byte, error) {
+	bz, err := json.Marshal(signed)
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal signed response: %v", err)
+	}
+	return bz, nil
+}
+
+func unmarshalSigned(bz []byte) (SignedResponse, error) {
+	var signed SignedResponse
+	if err := json.Unmarshal(bz, &signed); err != nil {
+		return SignedResponse{}, fmt.Errorf("can not unmarshal signed response: %v", err)
+	}
+	return signed, nil
+}
+
+func hexBytes(bz []byte) string {
+	return "0x" + hex.EncodeToString(bz)
+}
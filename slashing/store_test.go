@@ -0,0 +1,94 @@
+package slashing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	aptos "github.com/aptos-labs/aptos-go-sdk"
+	"github.com/pkg/errors"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func testAvs(t *testing.T, address string) aptos.AccountAddress {
+	t.Helper()
+	avs := aptos.AccountAddress{}
+	if err := avs.ParseStringRelaxed(address); err != nil {
+		t.Fatal(err)
+	}
+	return avs
+}
+
+func TestCheckAndRecord(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "slashing.db"))
+	avs := testAvs(t, "0x1")
+	signed := SignedResponse{SigningRoot: []byte{1, 2, 3}, Response: "1000", Pubkey: []byte{9}, SignedAt: time.Now().UTC()}
+
+	if err := store.CheckAndRecord(avs, 7, signed); err != nil {
+		t.Fatalf("first signature refused: %v", err)
+	}
+	// the same message may be signed again, e.g. to resend it
+	again := signed
+	again.SignedAt = signed.SignedAt.Add(time.Minute)
+	if err := store.CheckAndRecord(avs, 7, again); err != nil {
+		t.Errorf("same signing root refused: %v", err)
+	}
+
+	conflicting := signed
+	conflicting.SigningRoot = []byte{4, 5, 6}
+	conflicting.Response = "2000"
+	if err := store.CheckAndRecord(avs, 7, conflicting); !errors.Is(err, ErrConflictingSignature) {
+		t.Errorf("different signing root: %v, want %v", err, ErrConflictingSignature)
+	}
+	stored, err := store.Signed(avs, 7)
+	if err != nil || stored == nil {
+		t.Fatalf("task 7 not recorded: %v", err)
+	}
+	if string(stored.SigningRoot) != string(signed.SigningRoot) || stored.Response != "1000" {
+		t.Errorf("recorded %x %s, want the first signature", stored.SigningRoot, stored.Response)
+	}
+
+	// tasks and AVSs are recorded apart
+	if err := store.CheckAndRecord(avs, 8, conflicting); err != nil {
+		t.Errorf("other task refused: %v", err)
+	}
+	if err := store.CheckAndRecord(testAvs(t, "0x2"), 7, conflicting); err != nil {
+		t.Errorf("same task of another avs refused: %v", err)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slashing.db")
+	avs := testAvs(t, "0x1")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckAndRecord(avs, 7, SignedResponse{SigningRoot: []byte{1, 2, 3}, Response: "1000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openTestStore(t, path)
+	stored, err := store.Signed(avs, 7)
+	if err != nil || stored == nil {
+		t.Fatalf("task 7 lost after reopening: %v", err)
+	}
+	if stored.Response != "1000" {
+		t.Errorf("response %s after reopening, want 1000", stored.Response)
+	}
+	if err := store.CheckAndRecord(avs, 7, SignedResponse{SigningRoot: []byte{4, 5, 6}}); !errors.Is(err, ErrConflictingSignature) {
+		t.Errorf("different signing root after reopening: %v, want %v", err, ErrConflictingSignature)
+	}
+}